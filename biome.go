@@ -0,0 +1,86 @@
+package schem
+
+import (
+	"strings"
+
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/oriumgames/crocon"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+)
+
+// PlaceBiomes applies the biomes of the schematic, assuming the Structure is
+// built at pos. Java biome identifiers are mapped to Dragonfly's biome
+// registry, biomes that cannot be mapped fall back to
+// PlacementOptions.DefaultBiome or are left untouched if it is nil.
+func (s *Structure) PlaceBiomes(tx *world.Tx, pos cube.Pos) {
+	width, _, length := s.schematic.Dimensions()
+	s.placeBiomes(tx, pos, 0, 0, width, length)
+}
+
+// placeBiomes applies the biomes of the schematic within the columns of the
+// area passed.
+func (s *Structure) placeBiomes(tx *world.Tx, pos cube.Pos, x, z, width, length int) {
+	_, height, _ := s.schematic.Dimensions()
+	for lx := x; lx < x+width; lx++ {
+		for lz := z; lz < z+length; lz++ {
+			for y := range height {
+				if s.opts.Mask != nil && !s.opts.Mask(lx, y, lz) {
+					continue
+				}
+				name := s.schematic.Biome(lx, y, lz)
+				if name == "" {
+					continue
+				}
+				if b := s.biome(name); b != nil {
+					tx.SetBiome(pos.Add(cube.Pos{lx, y, lz}), b)
+				}
+			}
+		}
+	}
+}
+
+// biome maps a Java biome identifier to a world.Biome. Results are cached per
+// Structure, as schematics usually hold only a handful of biomes.
+func (s *Structure) biome(name string) world.Biome {
+	s.biomeMu.Lock()
+	defer s.biomeMu.Unlock()
+	if b, ok := s.biomes[name]; ok {
+		return b
+	}
+	if s.biomes == nil {
+		s.biomes = make(map[string]world.Biome)
+	}
+
+	b := s.convertBiome(name)
+	if b == nil {
+		b = s.opts.DefaultBiome
+	}
+	s.biomes[name] = b
+	return b
+}
+
+// convertBiome converts a Java biome identifier using crocon, falling back to
+// a lookup by name. It returns nil if the biome could not be mapped.
+func (s *Structure) convertBiome(name string) world.Biome {
+	if fromVersion := s.schematic.Version(); fromVersion != "" && s.converter != nil {
+		res, err := s.converter.ConvertBiome(crocon.BiomeRequest{
+			ConversionRequest: crocon.ConversionRequest{
+				FromVersion: fromVersion,
+				ToVersion:   protocol.CurrentVersion,
+				FromEdition: crocon.JavaEdition,
+				ToEdition:   crocon.BedrockEdition,
+			},
+			Data: map[string]any{"name": name},
+		})
+		if err == nil && res != nil {
+			if b, ok := world.BiomeByID(int(res.ID)); ok {
+				return b
+			}
+		}
+	}
+	if b, ok := world.BiomeByName(strings.TrimPrefix(name, "minecraft:")); ok {
+		return b
+	}
+	return nil
+}
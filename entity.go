@@ -0,0 +1,146 @@
+package schem
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/go-gl/mathgl/mgl64"
+	"github.com/oriumgames/crocon"
+	"github.com/oriumgames/schem/format"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+)
+
+// Place builds the Structure at pos and spawns its entities. If
+// PlacementOptions.Biomes is set, the biomes of the schematic are applied as
+// well. Entities that could not be placed are reported in the returned error,
+// the blocks of the Structure are placed regardless.
+func (s *Structure) Place(tx *world.Tx, pos cube.Pos) error {
+	if s.opts.Biomes {
+		s.PlaceBiomes(tx, pos)
+	}
+	tx.BuildStructure(pos, s)
+	return s.PlaceEntities(tx, pos)
+}
+
+// PlaceEntities spawns the entities of the schematic, assuming the Structure
+// is built at pos. Entities are converted to Bedrock using crocon and created
+// through the entity registry of the world. Entities of types that could not
+// be converted or are not registered are reported in the returned error.
+func (s *Structure) PlaceEntities(tx *world.Tx, pos cube.Pos) error {
+	width, _, length := s.schematic.Dimensions()
+	return s.placeEntities(tx, pos, 0, 0, width, length)
+}
+
+// placeEntities spawns the entities of the schematic within the columns of the
+// area passed. Entities outside the schematic are attributed to the nearest
+// column, so that every entity is placed by exactly one area.
+func (s *Structure) placeEntities(tx *world.Tx, pos cube.Pos, x, z, width, length int) error {
+	if s.opts.SkipEntities {
+		return nil
+	}
+	w, h, l := s.schematic.Dimensions()
+
+	var errs []error
+	for _, ent := range s.schematic.Entities() {
+		ex := clamp(int(math.Floor(ent.Pos[0])), 0, w-1)
+		ey := clamp(int(math.Floor(ent.Pos[1])), 0, h-1)
+		ez := clamp(int(math.Floor(ent.Pos[2])), 0, l-1)
+		if ex < x || ex >= x+width || ez < z || ez >= z+length {
+			continue
+		}
+		if s.opts.Mask != nil && !s.opts.Mask(ex, ey, ez) {
+			continue
+		}
+		if err := s.placeEntity(tx, pos, ent); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// placeEntity converts a single entity and adds it to the transaction.
+func (s *Structure) placeEntity(tx *world.Tx, pos cube.Pos, ent *format.Entity) error {
+	id, data, err := s.bedrockEntity(ent)
+	if err != nil {
+		return err
+	}
+	t, ok := tx.World().EntityRegistry().Lookup(id)
+	if !ok {
+		return fmt.Errorf("entity %s: unsupported entity type %q", ent.ID, id)
+	}
+
+	opts := world.EntitySpawnOpts{
+		Position: mgl64.Vec3{
+			float64(pos[0]) + ent.Pos[0],
+			float64(pos[1]) + ent.Pos[1],
+			float64(pos[2]) + ent.Pos[2],
+		},
+		Rotation: cube.Rotation{float64(ent.Rotation[0]), float64(ent.Rotation[1])},
+		Velocity: mgl64.Vec3{ent.Motion[0], ent.Motion[1], ent.Motion[2]},
+	}
+	opts.NameTag, _ = data["CustomName"].(string)
+	tx.AddEntity(opts.New(t, nbtEntityConfig{t: t, data: data}))
+	return nil
+}
+
+// bedrockEntity converts an entity to Bedrock using crocon. It returns the
+// Bedrock identifier of the entity and its Bedrock NBT.
+func (s *Structure) bedrockEntity(ent *format.Entity) (string, map[string]any, error) {
+	fromVersion := s.schematic.Version()
+	if fromVersion == "" {
+		return "", nil, fmt.Errorf("entity %s: unknown source version", ent.ID)
+	}
+
+	from := crocon.Entity(maps.Clone(ent.Data))
+	if from == nil {
+		from = crocon.Entity{}
+	}
+	from["id"] = ent.ID
+	from["Pos"] = []float64{ent.Pos[0], ent.Pos[1], ent.Pos[2]}
+	from["Rotation"] = []float32{ent.Rotation[0], ent.Rotation[1]}
+	from["Motion"] = []float64{ent.Motion[0], ent.Motion[1], ent.Motion[2]}
+
+	e, err := s.converter.ConvertEntity(crocon.EntityRequest{
+		ConversionRequest: crocon.ConversionRequest{
+			FromVersion: fromVersion,
+			ToVersion:   protocol.CurrentVersion,
+			FromEdition: crocon.JavaEdition,
+			ToEdition:   crocon.BedrockEdition,
+		},
+		Entity: from,
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("entity %s: %w", ent.ID, err)
+	}
+	if e == nil {
+		return "", nil, fmt.Errorf("entity %s: no conversion result", ent.ID)
+	}
+
+	data := map[string]any(*e)
+	id, _ := data["identifier"].(string)
+	if id == "" {
+		id, _ = data["id"].(string)
+	}
+	return id, data, nil
+}
+
+// nbtEntityConfig is a world.EntityConfig that configures an entity from its
+// Bedrock NBT representation.
+type nbtEntityConfig struct {
+	t    world.EntityType
+	data map[string]any
+}
+
+// Apply implements world.EntityConfig.
+func (conf nbtEntityConfig) Apply(data *world.EntityData) {
+	conf.t.DecodeNBT(conf.data, data)
+}
+
+// clamp limits v to the range [lo, hi].
+func clamp(v, lo, hi int) int {
+	return max(lo, min(v, hi))
+}
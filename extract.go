@@ -0,0 +1,329 @@
+package schem
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/df-mc/dragonfly/server/world/chunk"
+	"github.com/df-mc/goleveldb/leveldb"
+	"github.com/df-mc/goleveldb/leveldb/opt"
+	"github.com/oriumgames/crocon"
+	"github.com/oriumgames/schem/format"
+	"github.com/sandertv/gophertunnel/minecraft/nbt"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+)
+
+// extractDataVersion is the data version of the schematics returned by
+// Extract, which is that of the latest Java version crocon converts to.
+const extractDataVersion = 4665
+
+// ExtractOptions controls what Extract reads from a world. The zero value
+// reads blocks, block entities and biomes of the overworld.
+type ExtractOptions struct {
+	// Dimension is the dimension to read from. If nil, the overworld is read.
+	Dimension world.Dimension
+	// SkipBlockEntities reads blocks without the data of their block
+	// entities, such as the contents of chests or the text of signs.
+	SkipBlockEntities bool
+	// SkipBiomes leaves the biomes of the schematic unset.
+	SkipBiomes bool
+}
+
+// Extract reads the cuboid spanned by the corners passed, both inclusive, from
+// the Bedrock LevelDB world in dir. The chunks are read from the LevelDB
+// database directly and read-only, so the world, including its level.dat, is
+// left as it is. It must not be in use by a running server.
+//
+// Block states, block entities and biomes are converted to Java Edition using
+// crocon, so that the schematic can be written in any format. Blocks that
+// cannot be converted and positions in chunks that were never generated are
+// left without a block.
+func Extract(dir string, from, to cube.Pos, opts ExtractOptions) (format.Schematic, error) {
+	db, err := leveldb.OpenFile(filepath.Join(dir, "db"), &opt.Options{ReadOnly: true, ErrorIfMissing: true})
+	if err != nil {
+		return nil, fmt.Errorf("open world: %w", err)
+	}
+	defer db.Close()
+
+	dim := opts.Dimension
+	if dim == nil {
+		dim = world.Overworld
+	}
+	minPos := cube.Pos{min(from[0], to[0]), min(from[1], to[1]), min(from[2], to[2])}
+	maxPos := cube.Pos{max(from[0], to[0]), max(from[1], to[1]), max(from[2], to[2])}
+	r := dim.Range()
+	minPos[1], maxPos[1] = max(minPos[1], r.Min()), min(maxPos[1], r.Max())
+	if minPos[1] > maxPos[1] {
+		return nil, fmt.Errorf("cuboid lies outside the height range %v of %v", r, dim)
+	}
+
+	c, err := crocon.NewConverter()
+	if err != nil {
+		return nil, fmt.Errorf("create converter: %w", err)
+	}
+	e := &extractor{
+		converter: c,
+		opts:      opts,
+		schematic: format.New(maxPos[0]-minPos[0]+1, maxPos[1]-minPos[1]+1, maxPos[2]-minPos[2]+1, ""),
+		origin:    minPos,
+		states:    make(map[uint32]*format.BlockState),
+		biomes:    make(map[uint32]string),
+	}
+	e.schematic.SetDataVersion(extractDataVersion)
+	e.schematic.SetOffset(minPos[0], minPos[1], minPos[2])
+
+	for cx := minPos[0] >> 4; cx <= maxPos[0]>>4; cx++ {
+		for cz := minPos[2] >> 4; cz <= maxPos[2]>>4; cz++ {
+			col, err := loadColumn(db, world.ChunkPos{int32(cx), int32(cz)}, dim)
+			if errors.Is(err, leveldb.ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("load column %v (%v): %w", world.ChunkPos{int32(cx), int32(cz)}, dim, err)
+			}
+			e.column(col, cx, cz, maxPos)
+		}
+	}
+	return e.schematic, nil
+}
+
+// LevelDB keys of the chunk data read by loadColumn, appended to the index of
+// a chunk. See https://learn.microsoft.com/en-us/minecraft/creator/documents/actorstorage
+// and Dragonfly's mcdb package, which writes the same keys.
+const (
+	keySubChunkData  = '/'
+	keyVersion       = ','
+	keyVersionOld    = 'v'
+	keyBlockEntities = '1'
+	key3DData        = '+'
+)
+
+// loadColumn reads the blocks, biomes and block entities of a chunk from a
+// world database. Unlike Dragonfly's mcdb provider, it never writes to the
+// world. If the chunk was never generated, errors.Is(err, leveldb.ErrNotFound)
+// is true.
+func loadColumn(db *leveldb.DB, pos world.ChunkPos, dim world.Dimension) (*chunk.Column, error) {
+	index := chunkIndex(pos, dim)
+	key := func(p ...byte) []byte {
+		return append(slices.Clip(index), p...)
+	}
+	if _, err := db.Get(key(keyVersion), nil); errors.Is(err, leveldb.ErrNotFound) {
+		if _, err := db.Get(key(keyVersionOld), nil); err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	var data chunk.SerialisedData
+	// The 3D data starts with a heightmap of 256 int16s, followed by the
+	// biomes. Chunks of old worlds only hold 2D data, and are read without
+	// biomes.
+	if biomes, err := db.Get(key(key3DData), nil); err == nil && len(biomes) > 512 {
+		data.Biomes = biomes[512:]
+	} else if err != nil && !errors.Is(err, leveldb.ErrNotFound) {
+		return nil, fmt.Errorf("read biomes: %w", err)
+	}
+	r := dim.Range()
+	data.SubChunks = make([][]byte, (r.Height()>>4)+1)
+	for i := range data.SubChunks {
+		sub, err := db.Get(key(keySubChunkData, uint8(i+(r[0]>>4))), nil)
+		if err != nil && !errors.Is(err, leveldb.ErrNotFound) {
+			return nil, fmt.Errorf("read sub chunk %v: %w", int8(i), err)
+		}
+		data.SubChunks[i] = sub
+	}
+	c, err := chunk.DiskDecode(data, r)
+	if err != nil {
+		return nil, fmt.Errorf("decode chunk data: %w", err)
+	}
+	col := &chunk.Column{Chunk: c}
+
+	blockEntities, err := db.Get(key(keyBlockEntities), nil)
+	if err != nil && !errors.Is(err, leveldb.ErrNotFound) {
+		return nil, fmt.Errorf("read block entities: %w", err)
+	}
+	buf := bytes.NewBuffer(blockEntities)
+	dec := nbt.NewDecoderWithEncoding(buf, nbt.LittleEndian)
+	for buf.Len() != 0 {
+		var m map[string]any
+		if err := dec.Decode(&m); err != nil {
+			return nil, fmt.Errorf("decode block entity: %w", err)
+		}
+		x, _ := m["x"].(int32)
+		y, _ := m["y"].(int32)
+		z, _ := m["z"].(int32)
+		col.BlockEntities = append(col.BlockEntities, chunk.BlockEntity{Pos: cube.Pos{int(x), int(y), int(z)}, Data: m})
+	}
+	return col, nil
+}
+
+// chunkIndex returns the LevelDB key prefix of a chunk: its coordinates,
+// followed by the dimension ID outside the overworld.
+func chunkIndex(pos world.ChunkPos, dim world.Dimension) []byte {
+	id, _ := world.DimensionID(dim)
+	b := binary.LittleEndian.AppendUint32(nil, uint32(pos[0]))
+	b = binary.LittleEndian.AppendUint32(b, uint32(pos[1]))
+	if id != 0 {
+		b = binary.LittleEndian.AppendUint32(b, uint32(id))
+	}
+	return b
+}
+
+// extractor converts the columns of a world into a schematic.
+type extractor struct {
+	converter *crocon.Converter
+	opts      ExtractOptions
+	schematic format.Schematic
+	// origin is the world position of the origin of the schematic.
+	origin cube.Pos
+
+	// states and biomes cache converted runtime IDs and biome IDs.
+	states map[uint32]*format.BlockState
+	biomes map[uint32]string
+}
+
+// column copies the part of a column that lies within the cuboid ending at
+// maxPos into the schematic.
+func (e *extractor) column(col *chunk.Column, cx, cz int, maxPos cube.Pos) {
+	c := col.Chunk
+	for x := max(cx<<4, e.origin[0]); x <= min(cx<<4+15, maxPos[0]); x++ {
+		for z := max(cz<<4, e.origin[2]); z <= min(cz<<4+15, maxPos[2]); z++ {
+			for y := e.origin[1]; y <= maxPos[1]; y++ {
+				lx, ly, lz := uint8(x&15), int16(y), uint8(z&15)
+				sx, sy, sz := x-e.origin[0], y-e.origin[1], z-e.origin[2]
+
+				state := e.state(c.Block(lx, ly, lz, 0))
+				if state != nil {
+					state = e.waterlog(state, c.Block(lx, ly, lz, 1))
+					e.schematic.SetBlock(sx, sy, sz, state)
+				}
+				if !e.opts.SkipBiomes {
+					if biome := e.biome(c.Biome(lx, ly, lz)); biome != "" {
+						e.schematic.SetBiome(sx, sy, sz, biome)
+					}
+				}
+			}
+		}
+	}
+
+	if e.opts.SkipBlockEntities {
+		return
+	}
+	for _, be := range col.BlockEntities {
+		p := be.Pos
+		if p[0] < e.origin[0] || p[1] < e.origin[1] || p[2] < e.origin[2] ||
+			p[0] > maxPos[0] || p[1] > maxPos[1] || p[2] > maxPos[2] {
+			continue
+		}
+		if ent := e.blockEntity(be.Data); ent != nil {
+			e.schematic.SetBlockEntity(p[0]-e.origin[0], p[1]-e.origin[1], p[2]-e.origin[2], ent)
+		}
+	}
+}
+
+// request returns the crocon request converting Bedrock data to the Java
+// version of the schematic.
+func (e *extractor) request() crocon.ConversionRequest {
+	return crocon.ConversionRequest{
+		FromVersion: protocol.CurrentVersion,
+		ToVersion:   e.schematic.Version(),
+		FromEdition: crocon.BedrockEdition,
+		ToEdition:   crocon.JavaEdition,
+	}
+}
+
+// state converts a runtime ID to a Java block state. It returns nil if the
+// block could not be converted. Results are cached per runtime ID.
+func (e *extractor) state(rid uint32) *format.BlockState {
+	if state, ok := e.states[rid]; ok {
+		return cloneState(state)
+	}
+	var state *format.BlockState
+	if name, props, ok := chunk.RuntimeIDToState(rid); ok {
+		b, err := e.converter.ConvertBlock(crocon.BlockRequest{
+			ConversionRequest: e.request(),
+			Block:             crocon.Block{ID: name, States: props},
+		})
+		if err == nil && b != nil {
+			state = &format.BlockState{Name: b.ID, Properties: b.States}
+		}
+	}
+	e.states[rid] = state
+	return cloneState(state)
+}
+
+// waterlog marks a block as waterlogged if the liquid layer holds water and
+// the Java block has a waterlogged property.
+func (e *extractor) waterlog(state *format.BlockState, liquid uint32) *format.BlockState {
+	if _, ok := state.Properties["waterlogged"]; !ok {
+		return state
+	}
+	if name, _, ok := chunk.RuntimeIDToState(liquid); ok && strings.HasSuffix(name, "water") {
+		state.Properties["waterlogged"] = true
+	}
+	return state
+}
+
+// biome converts a Bedrock biome ID to a Java biome identifier, falling back
+// to Dragonfly's biome registry. It returns an empty string if the biome
+// could not be mapped. Results are cached per biome ID.
+func (e *extractor) biome(id uint32) string {
+	if name, ok := e.biomes[id]; ok {
+		return name
+	}
+	var name string
+	res, err := e.converter.ConvertBiome(crocon.BiomeRequest{
+		ConversionRequest: e.request(),
+		Data:              map[string]any{"id": int32(id)},
+	})
+	if err == nil && res != nil && res.Name != "" {
+		name = res.Name
+	} else if b, ok := world.BiomeByID(int(id)); ok {
+		name = "minecraft:" + b.String()
+	}
+	e.biomes[id] = name
+	return name
+}
+
+// blockEntity converts the NBT of a Bedrock block entity to a Java block
+// entity. It returns nil if the block entity could not be converted.
+func (e *extractor) blockEntity(data map[string]any) *format.BlockEntity {
+	be, err := e.converter.ConvertBlockEntity(crocon.BlockEntityRequest{
+		ConversionRequest: e.request(),
+		BlockEntity:       crocon.BlockEntity(maps.Clone(data)),
+	})
+	if err != nil || be == nil {
+		return nil
+	}
+	out := map[string]any(*be)
+	if tag, ok := out["tag"].(map[string]any); ok {
+		out = tag
+	}
+	id, _ := out["id"].(string)
+	if id == "" {
+		return nil
+	}
+	out = maps.Clone(out)
+	for _, key := range []string{"id", "x", "y", "z", "keepPacked"} {
+		delete(out, key)
+	}
+	return &format.BlockEntity{ID: id, Data: out}
+}
+
+// cloneState returns a copy of a block state, so that cached states are not
+// shared between positions.
+func cloneState(state *format.BlockState) *format.BlockState {
+	if state == nil {
+		return nil
+	}
+	return &format.BlockState{Name: state.Name, Properties: maps.Clone(state.Properties)}
+}
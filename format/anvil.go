@@ -0,0 +1,34 @@
+package format
+
+import (
+	"fmt"
+
+	"github.com/oriumgames/schem/format/internal/anvil"
+)
+
+// ReadAnvil reads the cuboid between the corners passed, both inclusive, from
+// a Java Edition world saved by Minecraft 1.18 or later. dir is the directory
+// of a dimension, which holds the region and entities directories, such as
+// the world directory for the overworld or its DIM-1 directory for the
+// nether. The offset of the schematic is set to the lower corner, and it has
+// no format identifier.
+func ReadAnvil(dir string, from, to [3]int) (Schematic, error) {
+	schem, err := anvil.Read(dir, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("read anvil: %w", err)
+	}
+	return schem, nil
+}
+
+// WriteAnvil writes the schematic into a Java Edition world saved by
+// Minecraft 1.18 or later, with its lower corner at the offset of the
+// schematic. dir is the directory of a dimension, as for ReadAnvil. The
+// schematic replaces every block, block entity and entity inside it, and
+// positions without a block become air. The chunks written must have been
+// generated. The world must not be open in a running game.
+func WriteAnvil(dir string, schem Schematic) error {
+	if err := anvil.Write(dir, schem); err != nil {
+		return fmt.Errorf("write anvil: %w", err)
+	}
+	return nil
+}
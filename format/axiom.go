@@ -0,0 +1,21 @@
+package format
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/oriumgames/schem/format/internal/axiom"
+)
+
+// AxiomOptions configures how WriteAxiom writes a blueprint.
+type AxiomOptions = axiom.Options
+
+// WriteAxiom writes the schematic as an Axiom blueprint according to the
+// options passed. WriteFormat writes the thumbnail a blueprint was read with,
+// while WriteAxiom can render one of the schematic.
+func WriteAxiom(w io.Writer, schem Schematic, opts AxiomOptions) error {
+	if err := axiom.WriteWithOptions(w, schem, opts); err != nil {
+		return fmt.Errorf("write axiom: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,334 @@
+package format
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/oriumgames/schem/format/internal/base"
+	"github.com/oriumgames/schem/format/internal/mcedit"
+)
+
+// BiomeSupport describes how a format stores biomes.
+type BiomeSupport int
+
+const (
+	// NoBiomes means the format does not store biomes.
+	NoBiomes BiomeSupport = iota
+	// Biomes2DOnly means the format stores a single biome per column.
+	Biomes2DOnly
+	// Biomes3DFull means the format stores a biome per block.
+	Biomes3DFull
+)
+
+// Capabilities describes what a schematic format can hold.
+type Capabilities struct {
+	// Read and Write report whether the format can be read and written.
+	Read, Write bool
+	// Biomes describes how biomes are stored.
+	Biomes BiomeSupport
+	// Entities and BlockEntities report whether entities and block entities
+	// are stored.
+	Entities, BlockEntities bool
+	// ScheduledTicks reports whether pending block and fluid ticks are stored.
+	ScheduledTicks bool
+	// Thumbnail reports whether a preview image is stored.
+	Thumbnail bool
+	// LegacyBlocks reports whether blocks are stored as pre-1.13 numeric IDs,
+	// which cannot express every block state.
+	LegacyBlocks bool
+	// BlockColors reports whether blocks are stored as colours, which are
+	// read back as the block of the nearest colour.
+	BlockColors bool
+	// MultipleRegions reports whether a file may hold several named regions.
+	// Schematics are read from the first region of such files, and written
+	// as a single region.
+	MultipleRegions bool
+	// MaxDimension is the largest width, height or length the format can hold.
+	MaxDimension int
+	// Info lists the Info fields the format stores.
+	Info []string
+	// Tool is the tool schematics read in the format are made with, such as
+	// "WorldEdit". Formats that do not store Tool keep only this one.
+	Tool string
+}
+
+var formatCapabilities = map[string]Capabilities{
+	"axiom": {
+		Read: true, Write: true,
+		Entities: true, BlockEntities: true, Thumbnail: true,
+		MaxDimension: math.MaxInt32,
+		Info:         []string{"Name", "Author", "Tags"},
+		Tool:         "Axiom",
+	},
+	"mcedit": {
+		Read: true, Write: true,
+		Entities: true, BlockEntities: true, LegacyBlocks: true,
+		MaxDimension: math.MaxInt16,
+	},
+	"sponge_v1": {
+		Read: true, Write: true,
+		BlockEntities: true,
+		MaxDimension:  math.MaxUint16,
+		Info:          []string{"Name", "Author", "Created", "RequiredMods"},
+		Tool:          "WorldEdit",
+	},
+	"sponge_v2": {
+		Read: true, Write: true,
+		Biomes: Biomes2DOnly, Entities: true, BlockEntities: true,
+		MaxDimension: math.MaxUint16,
+		Info:         []string{"Name", "Author", "Created", "RequiredMods"},
+		Tool:         "WorldEdit",
+	},
+	"sponge_v3": {
+		Read: true, Write: true,
+		Biomes: Biomes3DFull, Entities: true, BlockEntities: true,
+		MaxDimension: math.MaxUint16,
+		Info:         []string{"Name", "Author", "Description", "Created", "RequiredMods"},
+		Tool:         "WorldEdit",
+	},
+	"litematica_v4": {
+		Read:     true,
+		Entities: true, BlockEntities: true, ScheduledTicks: true, MultipleRegions: true,
+		MaxDimension: math.MaxInt32,
+		Info:         []string{"Name", "Author", "Description", "Created", "Modified"},
+		Tool:         "Litematica",
+	},
+	"litematica_v5": {
+		Read:     true,
+		Entities: true, BlockEntities: true, ScheduledTicks: true, MultipleRegions: true,
+		MaxDimension: math.MaxInt32,
+		Info:         []string{"Name", "Author", "Description", "Created", "Modified"},
+		Tool:         "Litematica",
+	},
+	"litematica_v6": {
+		Read: true, Write: true,
+		Entities: true, BlockEntities: true, ScheduledTicks: true, MultipleRegions: true,
+		MaxDimension: math.MaxInt32,
+		Info:         []string{"Name", "Author", "Description", "Created", "Modified"},
+		Tool:         "Litematica",
+	},
+	"litematica_v7": {
+		Read: true, Write: true,
+		Entities: true, BlockEntities: true, ScheduledTicks: true, MultipleRegions: true,
+		MaxDimension: math.MaxInt32,
+		Info:         []string{"Name", "Author", "Description", "Created", "Modified"},
+		Tool:         "Litematica",
+	},
+	"structure": {
+		Read: true, Write: true,
+		Entities: true, BlockEntities: true,
+		MaxDimension: math.MaxInt32,
+	},
+	"vox": {
+		Read: true, Write: true,
+		BlockColors:  true,
+		MaxDimension: math.MaxInt32,
+	},
+	"blueprint": {
+		Read: true, Write: true,
+		Entities: true, BlockEntities: true,
+		MaxDimension: math.MaxInt16,
+		Info:         []string{"Name", "Author", "RequiredMods"},
+		Tool:         "Structurize",
+	},
+	"create": {
+		Read: true, Write: true,
+		Entities: true, BlockEntities: true,
+		MaxDimension: math.MaxInt32,
+		Info:         []string{"RequiredMods"},
+		Tool:         "Create",
+	},
+	"building_gadgets": {
+		Read: true, Write: true,
+		MaxDimension: 128,
+		Info:         []string{"Name"},
+		Tool:         "Building Gadgets",
+	},
+	"json": {
+		Read: true, Write: true,
+		Biomes: Biomes3DFull, Entities: true, BlockEntities: true, ScheduledTicks: true, Thumbnail: true,
+		MaxDimension: math.MaxInt32,
+		Info:         []string{"Name", "Author", "Description", "Created", "Modified", "Tags", "RequiredMods", "Tool"},
+	},
+}
+
+// FormatCapabilities returns the capabilities of a format.
+func FormatCapabilities(formatID string) (Capabilities, bool) {
+	caps, ok := formatCapabilities[formatID]
+	return caps, ok
+}
+
+// Loss describes data that is dropped or degraded when a schematic is
+// written in a format.
+type Loss struct {
+	// Feature names what is lost, such as "biomes" or "entities".
+	Feature string
+	// Detail describes the loss.
+	Detail string
+}
+
+func (l Loss) String() string {
+	return l.Feature + ": " + l.Detail
+}
+
+// LossError is returned by WriteStrict when writing a schematic would lose
+// data.
+type LossError struct {
+	Format string
+	Losses []Loss
+}
+
+func (e *LossError) Error() string {
+	details := make([]string, len(e.Losses))
+	for i, l := range e.Losses {
+		details[i] = l.String()
+	}
+	return fmt.Sprintf("writing as %s loses data: %s", e.Format, strings.Join(details, "; "))
+}
+
+// Analyze lists what is lost when the schematic is written in the format
+// passed. It returns an error if the format cannot be written.
+func Analyze(schem Schematic, formatID string) ([]Loss, error) {
+	caps, ok := formatCapabilities[formatID]
+	if !ok || !caps.Write {
+		return nil, fmt.Errorf("unsupported format %q", formatID)
+	}
+
+	var losses []Loss
+	width, height, length := schem.Dimensions()
+	if max(width, height, length) > caps.MaxDimension {
+		losses = append(losses, Loss{
+			Feature: "dimensions",
+			Detail:  fmt.Sprintf("%dx%dx%d exceeds the maximum of %d", width, height, length, caps.MaxDimension),
+		})
+	}
+
+	if caps.LegacyBlocks {
+		if states := mcedit.UnmappedStates(schem); len(states) > 0 {
+			losses = append(losses, Loss{
+				Feature: "blocks",
+				Detail:  fmt.Sprintf("%d block states have no legacy ID and become air: %s", len(states), strings.Join(states, ", ")),
+			})
+		}
+	}
+
+	if caps.BlockColors {
+		losses = append(losses, Loss{Feature: "blocks", Detail: "blocks are stored as colours and read back as the block of the nearest colour"})
+	}
+
+	if hasBiomes, layered := biomeLayout(schem); hasBiomes {
+		switch {
+		case caps.Biomes == NoBiomes:
+			losses = append(losses, Loss{Feature: "biomes", Detail: "biomes are not stored"})
+		case caps.Biomes == Biomes2DOnly && layered:
+			losses = append(losses, Loss{Feature: "biomes", Detail: "columns with several biomes are reduced to one"})
+		}
+	}
+
+	if n := len(schem.Entities()); n > 0 && !caps.Entities {
+		losses = append(losses, Loss{Feature: "entities", Detail: fmt.Sprintf("%d entities are not stored", n)})
+	}
+	if n := countBlockEntities(schem); n > 0 && !caps.BlockEntities {
+		losses = append(losses, Loss{Feature: "block entities", Detail: fmt.Sprintf("%d block entities are not stored", n)})
+	}
+
+	meta := schem.Metadata()
+	if !caps.ScheduledTicks && (meta["PendingBlockTicks"] != nil || meta["PendingFluidTicks"] != nil) {
+		losses = append(losses, Loss{Feature: "ticks", Detail: "scheduled ticks are not stored"})
+	}
+	if thumbnail, _ := meta["Thumbnail"].([]byte); len(thumbnail) > 0 && !caps.Thumbnail {
+		losses = append(losses, Loss{Feature: "thumbnail", Detail: "the thumbnail is not stored"})
+	}
+
+	if fields := droppedInfo(schem.Info(), caps); len(fields) > 0 {
+		losses = append(losses, Loss{Feature: "info", Detail: strings.Join(fields, ", ") + " not stored"})
+	}
+
+	if schem.Format() != formatID {
+		for key := range meta {
+			if base.IsExtraKey(key) {
+				losses = append(losses, Loss{Feature: "unknown data", Detail: fmt.Sprintf("tags kept from %s are dropped", schem.Format())})
+				break
+			}
+		}
+	}
+	return losses, nil
+}
+
+// WriteStrict writes the schematic in the format passed, like WriteFormat,
+// but fails with a *LossError without writing anything if data would be lost.
+func WriteStrict(w io.Writer, formatID string, schem Schematic) error {
+	losses, err := Analyze(schem, formatID)
+	if err != nil {
+		return err
+	}
+	if len(losses) > 0 {
+		return &LossError{Format: formatID, Losses: losses}
+	}
+	return WriteFormat(w, formatID, schem)
+}
+
+// biomeLayout reports whether a schematic has biomes, and whether any of its
+// columns holds more than one biome.
+func biomeLayout(schem Schematic) (hasBiomes, layered bool) {
+	width, height, length := schem.Dimensions()
+	for z := range length {
+		for x := range width {
+			first := ""
+			for y := range height {
+				biome := schem.Biome(x, y, z)
+				if biome == "" {
+					continue
+				}
+				hasBiomes = true
+				if first == "" {
+					first = biome
+				} else if biome != first {
+					return true, true
+				}
+			}
+		}
+	}
+	return hasBiomes, false
+}
+
+// countBlockEntities returns the number of block entities in a schematic.
+func countBlockEntities(schem Schematic) int {
+	width, height, length := schem.Dimensions()
+	n := 0
+	for y := range height {
+		for z := range length {
+			for x := range width {
+				if schem.BlockEntity(x, y, z) != nil {
+					n++
+				}
+			}
+		}
+	}
+	return n
+}
+
+// droppedInfo returns the names of the Info fields that are set but not
+// stored by a format with the capabilities passed.
+func droppedInfo(info Info, caps Capabilities) []string {
+	set := map[string]bool{
+		"Name":         info.Name != "",
+		"Author":       info.Author != "",
+		"Description":  info.Description != "",
+		"Created":      !info.Created.IsZero(),
+		"Modified":     !info.Modified.IsZero(),
+		"Tags":         len(info.Tags) > 0,
+		"RequiredMods": len(info.RequiredMods) > 0,
+		"Tool":         info.Tool != "" && (caps.Tool == "" || !strings.HasPrefix(info.Tool, caps.Tool)),
+	}
+	var dropped []string
+	for _, field := range []string{"Name", "Author", "Description", "Created", "Modified", "Tags", "RequiredMods", "Tool"} {
+		if set[field] && !slices.Contains(caps.Info, field) {
+			dropped = append(dropped, field)
+		}
+	}
+	return dropped
+}
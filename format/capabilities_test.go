@@ -0,0 +1,126 @@
+package format
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// features returns the features of the losses passed.
+func features(losses []Loss) []string {
+	names := make([]string, len(losses))
+	for i, l := range losses {
+		names[i] = l.Feature
+	}
+	return names
+}
+
+// modBlocks returns a schematic holding one more mod block than MCEdit
+// schematics have IDs for above the vanilla ones.
+func modBlocks() Schematic {
+	s := New(3841, 1, 1, "")
+	for x := range 3841 {
+		s.SetBlock(x, 0, 0, &BlockState{Name: fmt.Sprintf("mod:block_%04d", x)})
+	}
+	return s
+}
+
+func TestAnalyze(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		schem  func() Schematic
+		want   []string
+	}{
+		{"lossless", "sponge_v3", testSchematic, nil},
+		{"json", "json", testSchematic, nil},
+		{"legacy", "mcedit", testSchematic, []string{"biomes", "info"}},
+		{"colours", "vox", testSchematic, []string{"blocks", "biomes", "entities", "block entities", "info"}},
+		{"unmapped", "mcedit", func() Schematic {
+			s := New(1, 1, 1, "")
+			s.SetBlock(0, 0, 0, ParseBlockState("minecraft:oak_stairs[facing=up,half=bottom,shape=straight,waterlogged=false]"))
+			return s
+		}, []string{"blocks"}},
+		{"mod block IDs", "mcedit", modBlocks, []string{"blocks"}},
+		{"dimensions", "mcedit", func() Schematic {
+			return New(40000, 1, 1, "")
+		}, []string{"dimensions"}},
+		{"own tool", "sponge_v3", func() Schematic {
+			s := New(1, 1, 1, "")
+			s.SetInfo(Info{Tool: "WorldEdit 7.3.0"})
+			return s
+		}, nil},
+		{"other tool", "axiom", func() Schematic {
+			s := New(1, 1, 1, "")
+			s.SetInfo(Info{Tool: "WorldEdit 7.3.0"})
+			return s
+		}, []string{"info"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			losses, err := Analyze(tt.schem(), tt.format)
+			if err != nil {
+				t.Fatalf("analyze: %v", err)
+			}
+			got := features(losses)
+			if len(got) != len(tt.want) {
+				t.Fatalf("losses %v, want features %v", losses, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("losses %v, want features %v", losses, tt.want)
+				}
+			}
+		})
+	}
+
+	if _, err := Analyze(testSchematic(), "litematica_v4"); err == nil {
+		t.Error("analyze for a format that cannot be written: no error")
+	}
+}
+
+func TestWriteStrict(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteStrict(&buf, "mcedit", testSchematic())
+	var loss *LossError
+	if !errors.As(err, &loss) {
+		t.Fatalf("strict mcedit write: got %v, want a *LossError", err)
+	}
+	if loss.Format != "mcedit" || len(loss.Losses) == 0 {
+		t.Errorf("loss error %+v", loss)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("strict write failing with a loss wrote %d bytes", buf.Len())
+	}
+
+	if err := WriteStrict(&buf, "sponge_v3", testSchematic()); err != nil {
+		t.Fatalf("strict sponge_v3 write: %v", err)
+	}
+	var want bytes.Buffer
+	if err := WriteFormat(&want, "sponge_v3", testSchematic()); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), want.Bytes()) {
+		t.Error("strict write differs from WriteFormat")
+	}
+}
+
+func TestWriteOversizedMCEdit(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteFormat(&buf, "mcedit", New(40000, 1, 1, "")); err == nil {
+		t.Error("mcedit write of a schematic wider than 32767 blocks: no error")
+	}
+}
+
+func TestUnmappedModBlocks(t *testing.T) {
+	s := modBlocks()
+	if got := UnmappedStates(s); len(got) != 1 || got[0] != "mod:block_3840" {
+		t.Errorf("unmapped states %v, want [mod:block_3840]", got)
+	}
+	var buf bytes.Buffer
+	var loss *LossError
+	if err := WriteStrict(&buf, "mcedit", s); !errors.As(err, &loss) {
+		t.Errorf("strict mcedit write: got %v, want a *LossError", err)
+	}
+}
@@ -0,0 +1,15 @@
+package format
+
+import "github.com/oriumgames/schem/format/internal/base"
+
+// ColorTable maps blocks to colours. Keys are block names such as
+// "minecraft:stone", or block state strings such as
+// "minecraft:oak_log[axis=x]" for colours that apply to a single state.
+type ColorTable = base.ColorTable
+
+// DefaultColors returns a copy of the default colour table, which holds the
+// average texture colour of the full blocks of the game. The copy may be
+// modified and passed wherever a ColorTable is accepted.
+func DefaultColors() ColorTable {
+	return base.DefaultColors()
+}
@@ -0,0 +1,35 @@
+package format
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/oriumgames/schem/format/internal/datapack"
+)
+
+// DatapackOptions configures a data pack written by WriteDatapack.
+type DatapackOptions = datapack.Options
+
+// DatapackStructure is a schematic generated in the world as a jigsaw
+// structure of a data pack.
+type DatapackStructure = datapack.Structure
+
+// WriteDatapack writes a data pack generating the structures passed into the
+// directory dir. Every structure is saved as structure files of at most 48
+// blocks on each side, connected by jigsaw blocks, along with their template
+// pools, the structure and its structure set.
+func WriteDatapack(dir string, structures []DatapackStructure, opts DatapackOptions) error {
+	if err := datapack.Write(dir, structures, opts); err != nil {
+		return fmt.Errorf("write datapack: %w", err)
+	}
+	return nil
+}
+
+// WriteDatapackZip writes the data pack written by WriteDatapack as a zip
+// archive, which can be dropped into the datapacks directory of a world.
+func WriteDatapackZip(w io.Writer, structures []DatapackStructure, opts DatapackOptions) error {
+	if err := datapack.WriteZip(w, structures, opts); err != nil {
+		return fmt.Errorf("write datapack: %w", err)
+	}
+	return nil
+}
@@ -3,14 +3,21 @@ package format
 import (
 	"bytes"
 	"compress/gzip"
+	"encoding/base64"
 	"encoding/binary"
+	"encoding/json"
 	"fmt"
 	"io"
 
 	"github.com/oriumgames/nbt"
+	"github.com/oriumgames/schem/format/internal/base"
+	"github.com/oriumgames/schem/format/internal/structure"
 )
 
-const axiomMagic uint32 = 0x0AE5BB36
+const (
+	axiomMagic uint32 = 0x0AE5BB36
+	voxMagic          = "VOX "
+)
 
 // Detect attempts to detect the schematic format from file data.
 func Detect(data []byte) (string, error) {
@@ -23,11 +30,41 @@ func Detect(data []byte) (string, error) {
 		return "axiom", nil
 	}
 
-	// Check for gzip magic (Sponge, Litematica, MCEdit)
+	// Check for MagicaVoxel magic
+	if string(data[:4]) == voxMagic {
+		return "vox", nil
+	}
+
+	// Check for gzip magic (Sponge, Litematica, MCEdit, structure files,
+	// blueprints, Building Gadgets templates)
 	if len(data) >= 2 && data[0] == 0x1F && data[1] == 0x8B {
 		return detectGzipFormat(data)
 	}
 
+	// Check for text schematics (JSON object with "version", "size", "palette"
+	// and "layers")
+	if trimmed := bytes.TrimLeft(data, " \t\r\n"); len(trimmed) > 0 && trimmed[0] == '{' {
+		var root map[string]json.RawMessage
+		if err := json.Unmarshal(data, &root); err == nil {
+			_, hasVersion := root["version"]
+			_, hasSize := root["size"]
+			_, hasPalette := root["palette"]
+			_, hasLayers := root["layers"]
+			if hasVersion && hasSize && hasPalette && hasLayers {
+				return "json", nil
+			}
+		}
+	}
+
+	// Check for base64 encoded Building Gadgets templates, as copied from the
+	// template manager
+	if decoded, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(data))); err == nil &&
+		len(decoded) >= 2 && decoded[0] == 0x1F && decoded[1] == 0x8B {
+		if formatID, err := detectGzipFormat(decoded); err == nil && formatID == "building_gadgets" {
+			return formatID, nil
+		}
+	}
+
 	return "", fmt.Errorf("unknown format")
 }
 
@@ -43,6 +80,14 @@ func detectGzipFormat(data []byte) (string, error) {
 		return "", fmt.Errorf("read gzip data: %w", err)
 	}
 
+	// The blocks and entities of structure files, and the tile entities of
+	// blueprints, are left out, as the NBT decoder fails on long lists of them.
+	for _, list := range []string{"blocks", "entities", "tile_entities"} {
+		if nbtData, _, err = base.SplitList(nbtData, list); err != nil {
+			return "", fmt.Errorf("decode nbt: %w", err)
+		}
+	}
+
 	// Try to decode as NBT and check root structure
 	decoder := nbt.NewDecoderWithEncoding(bytes.NewReader(nbtData), nbt.BigEndian)
 	var root map[string]any
@@ -54,6 +99,10 @@ func detectGzipFormat(data []byte) (string, error) {
 	if version, ok := root["Version"].(int32); ok {
 		if _, hasRegions := root["Regions"]; hasRegions {
 			switch version {
+			case 4:
+				return "litematica_v4", nil
+			case 5:
+				return "litematica_v5", nil
 			case 6:
 				return "litematica_v6", nil
 			case 7:
@@ -64,15 +113,23 @@ func detectGzipFormat(data []byte) (string, error) {
 		}
 	}
 
-	// Check for Sponge Schematic (has "Version" at root)
+	// Check for Sponge Schematic v3 (has "Version" in a "Schematic" compound)
+	if schematic, ok := root["Schematic"].(map[string]any); ok {
+		if version, ok := schematic["Version"].(int32); ok {
+			if version == 3 {
+				return "sponge_v3", nil
+			}
+			return "", fmt.Errorf("unknown Sponge schematic version: %d", version)
+		}
+	}
+
+	// Check for Sponge Schematic v1/v2 (has "Version" at root)
 	if version, ok := root["Version"].(int32); ok {
 		switch version {
 		case 1:
 			return "sponge_v1", nil
 		case 2:
 			return "sponge_v2", nil
-		case 3:
-			return "sponge_v3", nil
 		default:
 			return "", fmt.Errorf("unknown Sponge schematic version: %d", version)
 		}
@@ -87,5 +144,48 @@ func detectGzipFormat(data []byte) (string, error) {
 		}
 	}
 
+	// Check for Structurize blueprints (has "size_x", "size_y", "size_z",
+	// "palette" and "blocks" at root)
+	_, hasSizeX := root["size_x"]
+	_, hasSizeY := root["size_y"]
+	_, hasSizeZ := root["size_z"]
+	if hasSizeX && hasSizeY && hasSizeZ {
+		_, hasPalette := root["palette"]
+		_, hasBlocks := root["blocks"]
+		if hasPalette && hasBlocks {
+			return "blueprint", nil
+		}
+	}
+
+	// Check for vanilla structure files (has "size", "blocks" and one or
+	// more palettes at root)
+	if _, hasSize := root["size"]; hasSize {
+		if _, hasBlocks := root["blocks"]; hasBlocks {
+			palette, hasPalette := root["palette"].([]any)
+			palettes, hasPalettes := root["palettes"].([]any)
+			if hasPalette || hasPalettes {
+				// Create schematics are structure files marked by WriteCreate
+				// or holding Create blocks
+				if len(palettes) > 0 {
+					palette, _ = palettes[0].([]any)
+				}
+				if structure.IsCreate(root, palette) {
+					return "create", nil
+				}
+				return "structure", nil
+			}
+		}
+	}
+
+	// Check for Building Gadgets templates (has "stateIntArray",
+	// "posIntArray" and "mapIntState" at root)
+	if _, hasStates := root["stateIntArray"]; hasStates {
+		_, hasPositions := root["posIntArray"]
+		_, hasMap := root["mapIntState"]
+		if hasPositions && hasMap {
+			return "building_gadgets", nil
+		}
+	}
+
 	return "", fmt.Errorf("unknown gzip NBT format")
 }
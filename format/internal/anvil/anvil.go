@@ -0,0 +1,418 @@
+package anvil
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"path/filepath"
+	"strings"
+
+	"github.com/oriumgames/schem/format/internal/base"
+)
+
+// Read reads the blocks, biomes, block entities and entities between the
+// corners passed, both inclusive, from the Java Edition dimension directory
+// passed, which holds the region and entities directories. The offset of the
+// schematic is set to the lower corner. Chunks that were never generated
+// read as empty.
+func Read(dir string, from, to [3]int) (base.Schematic, error) {
+	minX, minY, minZ := min(from[0], to[0]), min(from[1], to[1]), min(from[2], to[2])
+	maxX, maxY, maxZ := max(from[0], to[0]), max(from[1], to[1]), max(from[2], to[2])
+	width, height, length := maxX-minX+1, maxY-minY+1, maxZ-minZ+1
+
+	s := base.New(width, height, length, "")
+	s.SetOffset(minX, minY, minZ)
+
+	regions := newRegionCache(filepath.Join(dir, "region"))
+	dataVersion := 0
+	for chunkX := minX >> 4; chunkX <= maxX>>4; chunkX++ {
+		for chunkZ := minZ >> 4; chunkZ <= maxZ>>4; chunkZ++ {
+			chunk, ok, err := readChunk(regions, chunkX, chunkZ)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+			dataVersion = max(dataVersion, int(chunk.DataVersion))
+
+			for i := range chunk.Sections {
+				section := &chunk.Sections[i]
+				if len(section.BlockStates.Palette) == 0 {
+					continue
+				}
+				baseY := section.sectionY() * sectionSize
+				if baseY > maxY || baseY+sectionSize <= minY {
+					continue
+				}
+				indices, palette := section.blocks()
+				biomes := section.biomes()
+
+				for ly := range sectionSize {
+					for lz := range sectionSize {
+						for lx := range sectionSize {
+							x, y, z := chunkX*sectionSize+lx-minX, baseY+ly-minY, chunkZ*sectionSize+lz-minZ
+							if x < 0 || x >= width || y < 0 || y >= height || z < 0 || z >= length {
+								continue
+							}
+							if idx := indices[blockIndex(lx, ly, lz)]; idx < len(palette) {
+								s.SetBlock(x, y, z, palette[idx].Clone())
+							}
+							if biome := biomes[biomeIndex(lx, ly, lz)]; biome != "" {
+								s.SetBiome(x, y, z, biome)
+							}
+						}
+					}
+				}
+			}
+
+			for _, raw := range chunk.BlockEntities {
+				bx, okX := intValue(raw["x"])
+				by, okY := intValue(raw["y"])
+				bz, okZ := intValue(raw["z"])
+				if !okX || !okY || !okZ {
+					continue
+				}
+				x, y, z := bx-minX, by-minY, bz-minZ
+				if x < 0 || x >= width || y < 0 || y >= height || z < 0 || z >= length {
+					continue
+				}
+				be := &base.BlockEntity{Data: make(map[string]any)}
+				be.ID, _ = raw["id"].(string)
+				for k, v := range raw {
+					switch k {
+					case "x", "y", "z", "id", "keepPacked":
+						continue
+					default:
+						be.Data[k] = v
+					}
+				}
+				s.SetBlockEntity(x, y, z, be)
+			}
+		}
+	}
+	s.SetDataVersion(dataVersion)
+
+	entities := newRegionCache(filepath.Join(dir, "entities"))
+	for chunkX := minX >> 4; chunkX <= maxX>>4; chunkX++ {
+		for chunkZ := minZ >> 4; chunkZ <= maxZ>>4; chunkZ++ {
+			chunk, ok, err := readEntityChunk(entities, chunkX, chunkZ)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+			for _, raw := range chunk.Entities {
+				pos := base.Float64List(raw["Pos"])
+				if len(pos) < 3 || !inBox(pos, minX, minY, minZ, maxX, maxY, maxZ) {
+					continue
+				}
+				ent := &base.Entity{Data: make(map[string]any)}
+				ent.ID, _ = raw["id"].(string)
+				ent.Pos = [3]float64{pos[0] - float64(minX), pos[1] - float64(minY), pos[2] - float64(minZ)}
+				if rot := base.Float32List(raw["Rotation"]); len(rot) >= 2 {
+					ent.Rotation = [2]float32{rot[0], rot[1]}
+				}
+				if motion := base.Float64List(raw["Motion"]); len(motion) >= 3 {
+					ent.Motion = [3]float64{motion[0], motion[1], motion[2]}
+				}
+				if uuid, ok := raw["UUID"].([4]int32); ok {
+					ent.UUID = &uuid
+				}
+				for k, v := range raw {
+					switch k {
+					case "id", "Pos", "Rotation", "Motion", "UUID":
+						continue
+					default:
+						ent.Data[k] = v
+					}
+				}
+				s.AddEntity(ent)
+			}
+		}
+	}
+	return s, nil
+}
+
+// Write writes a schematic into the Java Edition dimension directory passed,
+// with its lower corner at its offset. Every block of the schematic replaces
+// the block in the world, with positions without a block becoming air, and
+// block entities and entities inside the schematic are replaced by those of
+// the schematic. Heightmaps and light of the chunks written are dropped so
+// that the game recomputes them. The chunks written must have been generated.
+func Write(dir string, s base.Schematic) error {
+	width, height, length := s.Dimensions()
+	if width == 0 || height == 0 || length == 0 {
+		return nil
+	}
+	offsetX, offsetY, offsetZ := s.Offset()
+	minX, minY, minZ := offsetX, offsetY, offsetZ
+	maxX, maxY, maxZ := offsetX+width-1, offsetY+height-1, offsetZ+length-1
+
+	regions := newRegionCache(filepath.Join(dir, "region"))
+	air := &base.BlockState{Name: "minecraft:air"}
+	dataVersion := 0
+
+	for chunkX := minX >> 4; chunkX <= maxX>>4; chunkX++ {
+		for chunkZ := minZ >> 4; chunkZ <= maxZ>>4; chunkZ++ {
+			chunk, ok, err := readChunk(regions, chunkX, chunkZ)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return fmt.Errorf("chunk %d,%d is not generated", chunkX, chunkZ)
+			}
+			dataVersion = max(dataVersion, int(chunk.DataVersion))
+
+			for sectionY := minY >> 4; sectionY <= maxY>>4; sectionY++ {
+				section := chunk.section(sectionY)
+				if section == nil {
+					return fmt.Errorf("y %d is outside the world height of chunk %d,%d", sectionY*sectionSize, chunkX, chunkZ)
+				}
+
+				indices, palette := section.blocks()
+				states := make([]*base.BlockState, sectionVolume)
+				for i, idx := range indices {
+					if idx < len(palette) {
+						states[i] = palette[idx]
+					} else {
+						states[i] = air
+					}
+				}
+				biomes := section.biomes()
+
+				for ly := range sectionSize {
+					for lz := range sectionSize {
+						for lx := range sectionSize {
+							x, y, z := chunkX*sectionSize+lx-offsetX, sectionY*sectionSize+ly-offsetY, chunkZ*sectionSize+lz-offsetZ
+							if x < 0 || x >= width || y < 0 || y >= height || z < 0 || z >= length {
+								continue
+							}
+							state := s.Block(x, y, z)
+							if state == nil {
+								state = air
+							}
+							states[blockIndex(lx, ly, lz)] = state
+							if biome := s.Biome(x, y, z); biome != "" {
+								biomes[biomeIndex(lx, ly, lz)] = biome
+							}
+						}
+					}
+				}
+				for i, biome := range biomes {
+					if biome == "" {
+						biomes[i] = defaultBiome
+					}
+				}
+
+				section.setBlocks(states)
+				section.setBiomes(biomes)
+				delete(section.Extra, "BlockLight")
+				delete(section.Extra, "SkyLight")
+			}
+
+			// Replace the block entities inside the schematic.
+			blockEntities := chunk.BlockEntities[:0]
+			for _, raw := range chunk.BlockEntities {
+				bx, _ := intValue(raw["x"])
+				by, _ := intValue(raw["y"])
+				bz, _ := intValue(raw["z"])
+				if bx < minX || bx > maxX || by < minY || by > maxY || bz < minZ || bz > maxZ {
+					blockEntities = append(blockEntities, raw)
+				}
+			}
+			for ly := minY; ly <= maxY; ly++ {
+				for lz := max(minZ, chunkZ*sectionSize); lz <= min(maxZ, chunkZ*sectionSize+sectionSize-1); lz++ {
+					for lx := max(minX, chunkX*sectionSize); lx <= min(maxX, chunkX*sectionSize+sectionSize-1); lx++ {
+						be := s.BlockEntity(lx-offsetX, ly-offsetY, lz-offsetZ)
+						if be == nil {
+							continue
+						}
+						raw := make(map[string]any, len(be.Data)+5)
+						maps.Copy(raw, be.Data)
+						raw["id"] = be.ID
+						raw["x"] = int32(lx)
+						raw["y"] = int32(ly)
+						raw["z"] = int32(lz)
+						raw["keepPacked"] = byte(0)
+						blockEntities = append(blockEntities, raw)
+					}
+				}
+			}
+			chunk.BlockEntities = blockEntities
+
+			// Have the game recompute heightmaps and light on load.
+			if chunk.Extra == nil {
+				chunk.Extra = make(map[string]any)
+			}
+			delete(chunk.Extra, "Heightmaps")
+			chunk.Extra["isLightOn"] = byte(0)
+
+			if err := writeChunk(regions, chunkX, chunkZ, chunk); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := writeEntities(filepath.Join(dir, "entities"), s, dataVersion); err != nil {
+		return err
+	}
+	return regions.flush()
+}
+
+// writeEntities replaces the entities inside the schematic with those of the
+// schematic.
+func writeEntities(dir string, s base.Schematic, dataVersion int) error {
+	width, height, length := s.Dimensions()
+	offsetX, offsetY, offsetZ := s.Offset()
+	minX, minY, minZ := offsetX, offsetY, offsetZ
+	maxX, maxY, maxZ := offsetX+width-1, offsetY+height-1, offsetZ+length-1
+
+	placed := make(map[[2]int][]map[string]any)
+	for _, ent := range s.Entities() {
+		pos := []float64{ent.Pos[0] + float64(offsetX), ent.Pos[1] + float64(offsetY), ent.Pos[2] + float64(offsetZ)}
+		raw := make(map[string]any, len(ent.Data)+5)
+		maps.Copy(raw, ent.Data)
+		raw["id"] = ent.ID
+		raw["Pos"] = pos
+		raw["Rotation"] = []float32{ent.Rotation[0], ent.Rotation[1]}
+		raw["Motion"] = []float64{ent.Motion[0], ent.Motion[1], ent.Motion[2]}
+		if ent.UUID != nil {
+			raw["UUID"] = *ent.UUID
+		}
+		key := [2]int{int(math.Floor(pos[0])) >> 4, int(math.Floor(pos[2])) >> 4}
+		placed[key] = append(placed[key], raw)
+	}
+
+	entities := newRegionCache(dir)
+	visit := func(chunkX, chunkZ int) error {
+		chunk, ok, err := readEntityChunk(entities, chunkX, chunkZ)
+		if err != nil {
+			return err
+		}
+		added := placed[[2]int{chunkX, chunkZ}]
+		if !ok {
+			if len(added) == 0 {
+				return nil
+			}
+			chunk = entityChunkNBT{DataVersion: int32(dataVersion), Position: []int32{int32(chunkX), int32(chunkZ)}}
+		}
+
+		kept := chunk.Entities[:0]
+		for _, raw := range chunk.Entities {
+			if pos := base.Float64List(raw["Pos"]); len(pos) < 3 || !inBox(pos, minX, minY, minZ, maxX, maxY, maxZ) {
+				kept = append(kept, raw)
+			}
+		}
+		chunk.Entities = append(kept, added...)
+
+		data, err := base.MarshalNBT(chunk)
+		if err != nil {
+			return fmt.Errorf("encode entity chunk %d,%d: %w", chunkX, chunkZ, err)
+		}
+		r, err := entities.region(chunkX, chunkZ)
+		if err != nil {
+			return err
+		}
+		return r.setChunk(chunkX&31, chunkZ&31, data)
+	}
+
+	visited := make(map[[2]int]bool)
+	for chunkX := minX >> 4; chunkX <= maxX>>4; chunkX++ {
+		for chunkZ := minZ >> 4; chunkZ <= maxZ>>4; chunkZ++ {
+			visited[[2]int{chunkX, chunkZ}] = true
+			if err := visit(chunkX, chunkZ); err != nil {
+				return err
+			}
+		}
+	}
+	// Entities may stand slightly outside the blocks of the schematic.
+	for key := range placed {
+		if !visited[key] {
+			if err := visit(key[0], key[1]); err != nil {
+				return err
+			}
+		}
+	}
+	return entities.flush()
+}
+
+// readChunk reads and decodes a chunk. It reports false if the chunk does
+// not exist.
+func readChunk(regions *regionCache, chunkX, chunkZ int) (chunkNBT, bool, error) {
+	var chunk chunkNBT
+	r, err := regions.region(chunkX, chunkZ)
+	if err != nil {
+		return chunk, false, err
+	}
+	data, err := r.chunk(chunkX&31, chunkZ&31)
+	if err != nil {
+		return chunk, false, fmt.Errorf("chunk %d,%d: %w", chunkX, chunkZ, err)
+	}
+	if data == nil {
+		return chunk, false, nil
+	}
+	if err := decodeNBT(data, &chunk); err != nil {
+		return chunk, false, fmt.Errorf("chunk %d,%d: %w", chunkX, chunkZ, err)
+	}
+	if chunk.DataVersion < minDataVersion {
+		return chunk, false, fmt.Errorf("chunk %d,%d has data version %d; chunks before 1.18 (%d) are not supported", chunkX, chunkZ, chunk.DataVersion, minDataVersion)
+	}
+	if status, _ := chunk.Extra["Status"].(string); status != "" && strings.TrimPrefix(status, "minecraft:") != "full" {
+		// Chunks still being generated hold no usable blocks.
+		return chunk, false, nil
+	}
+	return chunk, true, nil
+}
+
+// writeChunk encodes a chunk and stores it in its region.
+func writeChunk(regions *regionCache, chunkX, chunkZ int, chunk chunkNBT) error {
+	data, err := base.MarshalNBT(chunk)
+	if err != nil {
+		return fmt.Errorf("encode chunk %d,%d: %w", chunkX, chunkZ, err)
+	}
+	r, err := regions.region(chunkX, chunkZ)
+	if err != nil {
+		return err
+	}
+	return r.setChunk(chunkX&31, chunkZ&31, data)
+}
+
+// readEntityChunk reads and decodes a chunk of an entities region. It reports
+// false if the chunk does not exist.
+func readEntityChunk(regions *regionCache, chunkX, chunkZ int) (entityChunkNBT, bool, error) {
+	var chunk entityChunkNBT
+	r, err := regions.region(chunkX, chunkZ)
+	if err != nil {
+		return chunk, false, err
+	}
+	data, err := r.chunk(chunkX&31, chunkZ&31)
+	if err != nil {
+		return chunk, false, fmt.Errorf("entity chunk %d,%d: %w", chunkX, chunkZ, err)
+	}
+	if data == nil {
+		return chunk, false, nil
+	}
+	if err := decodeNBT(data, &chunk); err != nil {
+		return chunk, false, fmt.Errorf("entity chunk %d,%d: %w", chunkX, chunkZ, err)
+	}
+	return chunk, true, nil
+}
+
+// section returns the section at the section index passed, or nil if the
+// chunk holds no blocks there.
+func (c *chunkNBT) section(y int) *sectionNBT {
+	for i := range c.Sections {
+		if c.Sections[i].sectionY() == y && len(c.Sections[i].BlockStates.Palette) > 0 {
+			return &c.Sections[i]
+		}
+	}
+	return nil
+}
+
+// inBox reports whether a position lies in the block box passed.
+func inBox(pos []float64, minX, minY, minZ, maxX, maxY, maxZ int) bool {
+	x, y, z := int(math.Floor(pos[0])), int(math.Floor(pos[1])), int(math.Floor(pos[2]))
+	return x >= minX && x <= maxX && y >= minY && y <= maxY && z >= minZ && z <= maxZ
+}
@@ -0,0 +1,171 @@
+package anvil
+
+import (
+	"bytes"
+	"fmt"
+	"math/bits"
+
+	"github.com/oriumgames/nbt"
+	"github.com/oriumgames/schem/format/internal/base"
+)
+
+const (
+	// minDataVersion is the data version of Minecraft 1.18, which introduced
+	// the chunk layout this package reads and writes.
+	minDataVersion = 2860
+
+	sectionSize   = 16
+	sectionVolume = sectionSize * sectionSize * sectionSize
+	biomeCells    = 4 * 4 * 4
+	defaultBiome  = "minecraft:plains"
+)
+
+// chunkNBT is the NBT structure of a chunk in a region file.
+type chunkNBT struct {
+	DataVersion   int32            `nbt:"DataVersion"`
+	XPos          int32            `nbt:"xPos"`
+	YPos          int32            `nbt:"yPos"`
+	ZPos          int32            `nbt:"zPos"`
+	Sections      []sectionNBT     `nbt:"sections"`
+	BlockEntities []map[string]any `nbt:"block_entities"`
+	Extra         map[string]any   `nbt:"*"`
+}
+
+type sectionNBT struct {
+	Y           byte           `nbt:"Y"`
+	BlockStates blockStatesNBT `nbt:"block_states,omitempty"`
+	Biomes      biomesNBT      `nbt:"biomes,omitempty"`
+	Extra       map[string]any `nbt:"*"`
+}
+
+type blockStatesNBT struct {
+	Palette []paletteEntryNBT `nbt:"palette"`
+	Data    []int64           `nbt:"data,array,omitempty"`
+}
+
+type paletteEntryNBT struct {
+	Name       string         `nbt:"Name"`
+	Properties map[string]any `nbt:"Properties,omitempty"`
+}
+
+type biomesNBT struct {
+	Palette []string `nbt:"palette"`
+	Data    []int64  `nbt:"data,array,omitempty"`
+}
+
+// entityChunkNBT is the NBT structure of a chunk in an entities region file.
+type entityChunkNBT struct {
+	DataVersion int32            `nbt:"DataVersion"`
+	Position    []int32          `nbt:"Position,array"`
+	Entities    []map[string]any `nbt:"Entities"`
+	Extra       map[string]any   `nbt:"*"`
+}
+
+// decodeNBT decodes uncompressed chunk NBT into v.
+func decodeNBT(data []byte, v any) error {
+	if err := nbt.NewDecoderWithEncoding(bytes.NewReader(data), nbt.BigEndian).Decode(v); err != nil {
+		return fmt.Errorf("decode nbt: %w", err)
+	}
+	return nil
+}
+
+// sectionY returns the section index of a section, which is stored as a
+// signed byte.
+func (s *sectionNBT) sectionY() int {
+	return int(int8(s.Y))
+}
+
+// blocks returns the palette index of every block of the section, along
+// with its palette.
+func (s *sectionNBT) blocks() ([]int, []*base.BlockState) {
+	palette := make([]*base.BlockState, len(s.BlockStates.Palette))
+	for i, entry := range s.BlockStates.Palette {
+		palette[i] = &base.BlockState{Name: entry.Name, Properties: entry.Properties}
+	}
+	if len(palette) <= 1 {
+		return make([]int, sectionVolume), palette
+	}
+	bitsPerEntry := max(bits.Len(uint(len(palette)-1)), 4)
+	return base.UnpackLongArray(s.BlockStates.Data, bitsPerEntry, sectionVolume), palette
+}
+
+// setBlocks replaces the blocks of the section.
+func (s *sectionNBT) setBlocks(states []*base.BlockState) {
+	palette := base.NewPalette()
+	indices := make([]int, sectionVolume)
+	for i, state := range states {
+		indices[i] = palette.Add(*state)
+	}
+
+	s.BlockStates.Palette = make([]paletteEntryNBT, palette.Size())
+	for i, block := range palette.Blocks() {
+		s.BlockStates.Palette[i] = paletteEntryNBT{Name: block.Name, Properties: block.NBTProperties()}
+	}
+	s.BlockStates.Data = nil
+	if palette.Size() > 1 {
+		s.BlockStates.Data = base.PackLongArray(indices, max(bits.Len(uint(palette.Size()-1)), 4))
+	}
+}
+
+// biomes returns the biome of every 4x4x4 cell of the section.
+func (s *sectionNBT) biomes() []string {
+	cells := make([]string, biomeCells)
+	if len(s.Biomes.Palette) == 0 {
+		return cells
+	}
+	indices := make([]int, biomeCells)
+	if len(s.Biomes.Palette) > 1 {
+		indices = base.UnpackLongArray(s.Biomes.Data, bits.Len(uint(len(s.Biomes.Palette)-1)), biomeCells)
+	}
+	for i, idx := range indices {
+		if idx < len(s.Biomes.Palette) {
+			cells[i] = s.Biomes.Palette[idx]
+		}
+	}
+	return cells
+}
+
+// setBiomes replaces the biomes of the section.
+func (s *sectionNBT) setBiomes(cells []string) {
+	palette := base.NewPalette()
+	indices := make([]int, biomeCells)
+	for i, biome := range cells {
+		indices[i] = palette.Add(base.BlockState{Name: biome})
+	}
+
+	s.Biomes.Palette = make([]string, palette.Size())
+	for i, biome := range palette.Blocks() {
+		s.Biomes.Palette[i] = biome.Name
+	}
+	s.Biomes.Data = nil
+	if palette.Size() > 1 {
+		s.Biomes.Data = base.PackLongArray(indices, bits.Len(uint(palette.Size()-1)))
+	}
+}
+
+// blockIndex returns the index of a block in a section.
+func blockIndex(x, y, z int) int {
+	return y*sectionSize*sectionSize + z*sectionSize + x
+}
+
+// biomeIndex returns the index of the biome cell holding a block of a
+// section.
+func biomeIndex(x, y, z int) int {
+	return (y>>2)*16 + (z>>2)*4 + x>>2
+}
+
+// intValue returns the value of an integer tag.
+func intValue(v any) (int, bool) {
+	switch n := v.(type) {
+	case int32:
+		return int(n), true
+	case int16:
+		return int(n), true
+	case byte:
+		return int(n), true
+	case int64:
+		return int(n), true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,242 @@
+package anvil
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	sectorSize    = 4096
+	regionChunks  = 32 * 32
+	maxSectors    = 255
+	headerSectors = 2
+
+	compressionGzip     byte = 1
+	compressionZlib     byte = 2
+	compressionNone     byte = 3
+	compressionLZ4      byte = 4
+	compressionExternal byte = 128
+)
+
+// regionChunk is the stored form of a chunk in a region file.
+type regionChunk struct {
+	compression byte
+	data        []byte
+}
+
+// region is an Anvil region file holding up to 32x32 chunks.
+type region struct {
+	path       string
+	x, z       int
+	chunks     [regionChunks]*regionChunk
+	timestamps [regionChunks]uint32
+	modified   bool
+}
+
+// regionPath returns the path of the region file holding the chunk passed.
+func regionPath(dir string, chunkX, chunkZ int) (string, int, int) {
+	x, z := chunkX>>5, chunkZ>>5
+	return filepath.Join(dir, fmt.Sprintf("r.%d.%d.mca", x, z)), x, z
+}
+
+// readRegion reads the region file at the path passed. A missing file reads
+// as an empty region.
+func readRegion(path string, x, z int) (*region, error) {
+	r := &region{path: path, x: x, z: z}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read region: %w", err)
+	}
+	if len(data) < headerSectors*sectorSize {
+		// Files shorter than the header hold no chunks.
+		return r, nil
+	}
+
+	for i := range regionChunks {
+		location := binary.BigEndian.Uint32(data[i*4:])
+		r.timestamps[i] = binary.BigEndian.Uint32(data[sectorSize+i*4:])
+		offset, sectors := int(location>>8), int(location&0xFF)
+		if offset == 0 || sectors == 0 {
+			continue
+		}
+		start := offset * sectorSize
+		if start+5 > len(data) {
+			return nil, fmt.Errorf("chunk %d of %s lies outside the file", i, filepath.Base(path))
+		}
+		length := int(binary.BigEndian.Uint32(data[start:]))
+		if length < 1 || start+4+length > len(data) {
+			return nil, fmt.Errorf("chunk %d of %s has invalid length %d", i, filepath.Base(path), length)
+		}
+		r.chunks[i] = &regionChunk{
+			compression: data[start+4],
+			data:        data[start+5 : start+4+length],
+		}
+	}
+	return r, nil
+}
+
+// chunk returns the uncompressed NBT of the chunk at the region-local
+// coordinates passed, or nil if the chunk does not exist.
+func (r *region) chunk(localX, localZ int) ([]byte, error) {
+	c := r.chunks[localX+localZ*32]
+	if c == nil {
+		return nil, nil
+	}
+	compression, payload := c.compression, c.data
+	if compression&compressionExternal != 0 {
+		// Chunks too large for the region file are stored next to it.
+		data, err := os.ReadFile(r.externalPath(localX, localZ))
+		if err != nil {
+			return nil, fmt.Errorf("read external chunk: %w", err)
+		}
+		compression, payload = compression&^compressionExternal, data
+	}
+
+	var rd io.Reader
+	switch compression {
+	case compressionGzip:
+		gz, err := gzip.NewReader(bytes.NewReader(payload))
+		if err != nil {
+			return nil, fmt.Errorf("gzip decompress: %w", err)
+		}
+		defer gz.Close()
+		rd = gz
+	case compressionZlib:
+		zr, err := zlib.NewReader(bytes.NewReader(payload))
+		if err != nil {
+			return nil, fmt.Errorf("zlib decompress: %w", err)
+		}
+		defer zr.Close()
+		rd = zr
+	case compressionNone:
+		return payload, nil
+	case compressionLZ4:
+		return nil, fmt.Errorf("lz4 compressed chunks are not supported")
+	default:
+		return nil, fmt.Errorf("unknown chunk compression %d", compression)
+	}
+	data, err := io.ReadAll(rd)
+	if err != nil {
+		return nil, fmt.Errorf("decompress chunk: %w", err)
+	}
+	return data, nil
+}
+
+// setChunk stores the uncompressed NBT of the chunk at the region-local
+// coordinates passed.
+func (r *region) setChunk(localX, localZ int, data []byte) error {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return fmt.Errorf("zlib compress: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("zlib compress: %w", err)
+	}
+	i := localX + localZ*32
+	r.chunks[i] = &regionChunk{compression: compressionZlib, data: buf.Bytes()}
+	r.timestamps[i] = uint32(time.Now().Unix())
+	r.modified = true
+	return nil
+}
+
+// externalPath returns the path of the file holding a chunk too large for
+// the region file.
+func (r *region) externalPath(localX, localZ int) string {
+	return filepath.Join(filepath.Dir(r.path), fmt.Sprintf("c.%d.%d.mcc", r.x*32+localX, r.z*32+localZ))
+}
+
+// write writes the region back to its file, laying out its chunks one after
+// another.
+func (r *region) write() error {
+	header := make([]byte, headerSectors*sectorSize)
+	var body bytes.Buffer
+	sector := headerSectors
+
+	for i, c := range r.chunks {
+		if c == nil {
+			continue
+		}
+		compression, payload := c.compression, c.data
+		if 5+len(payload) > maxSectors*sectorSize {
+			localX, localZ := i%32, i/32
+			if err := os.WriteFile(r.externalPath(localX, localZ), payload, 0o644); err != nil {
+				return fmt.Errorf("write external chunk: %w", err)
+			}
+			compression, payload = compression|compressionExternal, nil
+		}
+
+		var entry [5]byte
+		binary.BigEndian.PutUint32(entry[:], uint32(len(payload)+1))
+		entry[4] = compression
+		body.Write(entry[:])
+		body.Write(payload)
+
+		sectors := (5 + len(payload) + sectorSize - 1) / sectorSize
+		body.Write(make([]byte, sectors*sectorSize-5-len(payload)))
+
+		binary.BigEndian.PutUint32(header[i*4:], uint32(sector)<<8|uint32(sectors))
+		binary.BigEndian.PutUint32(header[sectorSize+i*4:], r.timestamps[i])
+		sector += sectors
+	}
+
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return fmt.Errorf("create region directory: %w", err)
+	}
+	tmp := r.path + ".tmp"
+	if err := os.WriteFile(tmp, append(header, body.Bytes()...), 0o644); err != nil {
+		return fmt.Errorf("write region: %w", err)
+	}
+	if err := os.Rename(tmp, r.path); err != nil {
+		return fmt.Errorf("replace region: %w", err)
+	}
+	return nil
+}
+
+// regionCache loads each region file of a directory once.
+type regionCache struct {
+	dir     string
+	regions map[[2]int]*region
+}
+
+func newRegionCache(dir string) *regionCache {
+	return &regionCache{dir: dir, regions: make(map[[2]int]*region)}
+}
+
+// region returns the region holding the chunk passed.
+func (c *regionCache) region(chunkX, chunkZ int) (*region, error) {
+	path, x, z := regionPath(c.dir, chunkX, chunkZ)
+	if r, ok := c.regions[[2]int{x, z}]; ok {
+		return r, nil
+	}
+	r, err := readRegion(path, x, z)
+	if err != nil {
+		return nil, err
+	}
+	c.regions[[2]int{x, z}] = r
+	return r, nil
+}
+
+// flush writes back every region that was modified.
+func (c *regionCache) flush() error {
+	for _, r := range c.regions {
+		if !r.modified {
+			continue
+		}
+		if err := r.write(); err != nil {
+			return fmt.Errorf("%s: %w", filepath.Base(r.path), err)
+		}
+	}
+	return nil
+}
@@ -14,6 +14,7 @@ import (
 
 	"github.com/oriumgames/nbt"
 	"github.com/oriumgames/schem/format/internal/base"
+	"github.com/oriumgames/schem/format/internal/render"
 )
 
 const (
@@ -22,18 +23,23 @@ const (
 	chunkArea                = chunkSize * chunkSize
 	chunkVolume              = chunkSize * chunkArea
 	defaultEmptyBlock        = "minecraft:structure_void"
+	headerVersion     int32  = 1
+	// thumbnailSize is the width and height of generated thumbnails.
+	thumbnailSize = 128
 )
 
+// headerNBT is the header of a blueprint. Axiom writes every field, and so
+// does Write.
 type headerNBT struct {
 	Version         int32          `nbt:"Version"`
-	Name            string         `nbt:"Name,omitempty"`
-	Author          string         `nbt:"Author,omitempty"`
-	Tags            []string       `nbt:"Tags,omitempty"`
-	ThumbnailYaw    float32        `nbt:"ThumbnailYaw,omitempty"`
-	ThumbnailPitch  float32        `nbt:"ThumbnailPitch,omitempty"`
-	LockedThumbnail bool           `nbt:"LockedThumbnail,omitempty"`
-	BlockCount      int32          `nbt:"BlockCount,omitempty"`
-	ContainsAir     bool           `nbt:"ContainsAir,omitempty"`
+	Name            string         `nbt:"Name"`
+	Author          string         `nbt:"Author"`
+	Tags            []string       `nbt:"Tags"`
+	ThumbnailYaw    float32        `nbt:"ThumbnailYaw"`
+	ThumbnailPitch  float32        `nbt:"ThumbnailPitch"`
+	LockedThumbnail bool           `nbt:"LockedThumbnail"`
+	BlockCount      int32          `nbt:"BlockCount"`
+	ContainsAir     bool           `nbt:"ContainsAir"`
 	Extra           map[string]any `nbt:"*"`
 }
 
@@ -141,10 +147,7 @@ func (cb *chunkBuilder) paletteIndex(block *base.BlockState) int32 {
 	if idx, ok := cb.palette[key]; ok {
 		return idx
 	}
-	entry := paletteEntryNBT{Name: block.Name}
-	if len(block.Properties) > 0 {
-		entry.Properties = block.Properties
-	}
+	entry := paletteEntryNBT{Name: block.Name, Properties: block.NBTProperties()}
 	idx := len(cb.entries)
 	cb.entries = append(cb.entries, entry)
 	cb.palette[key] = int32(idx)
@@ -259,6 +262,12 @@ func Read(r io.Reader) (base.Schematic, error) {
 			if block == nil || isEmptyBlock(block.Name) {
 				continue
 			}
+			// Air is only part of the blueprint if the header says so;
+			// otherwise it marks positions left untouched when placing.
+			air := isAirBlock(block.Name)
+			if air && !header.ContainsAir {
+				continue
+			}
 
 			localY := int32(idx) / chunkArea
 			rem := int32(idx) % chunkArea
@@ -278,7 +287,9 @@ func Read(r io.Reader) (base.Schematic, error) {
 			maxY = max(maxY, int(globalY))
 			maxZ = max(maxZ, int(globalZ))
 			hasContent = true
-			blockCount++
+			if !air {
+				blockCount++
+			}
 		}
 	}
 
@@ -316,7 +327,7 @@ func Read(r io.Reader) (base.Schematic, error) {
 		if id, ok := raw["id"].(string); ok {
 			ent.ID = id
 		}
-		if pos, ok := raw["Pos"].([]float64); ok && len(pos) >= 3 {
+		if pos := base.Float64List(raw["Pos"]); len(pos) >= 3 {
 			ent.Pos[0], ent.Pos[1], ent.Pos[2] = pos[0], pos[1], pos[2]
 			minX = min(minX, int(math.Floor(pos[0])))
 			minY = min(minY, int(math.Floor(pos[1])))
@@ -326,10 +337,10 @@ func Read(r io.Reader) (base.Schematic, error) {
 			maxZ = max(maxZ, int(math.Ceil(pos[2])))
 			hasContent = true
 		}
-		if rot, ok := raw["Rotation"].([]float32); ok && len(rot) >= 2 {
+		if rot := base.Float32List(raw["Rotation"]); len(rot) >= 2 {
 			ent.Rotation[0], ent.Rotation[1] = rot[0], rot[1]
 		}
-		if motion, ok := raw["Motion"].([]float64); ok && len(motion) >= 3 {
+		if motion := base.Float64List(raw["Motion"]); len(motion) >= 3 {
 			ent.Motion[0], ent.Motion[1], ent.Motion[2] = motion[0], motion[1], motion[2]
 		}
 		for k, v := range raw {
@@ -344,7 +355,8 @@ func Read(r io.Reader) (base.Schematic, error) {
 	if !hasContent {
 		s := base.New(0, 0, 0, "axiom")
 		s.SetDataVersion(int(blockData.DataVersion))
-		recordHeaderMetadata(s, &header, 0, header.ContainsAir)
+		recordHeaderMetadata(s, &header, thumbnail, 0)
+		base.SetExtra(s, "data", blockData.Extra)
 		return s, nil
 	}
 
@@ -365,8 +377,8 @@ func Read(r io.Reader) (base.Schematic, error) {
 	s.SetOffset(minX, minY, minZ)
 	s.SetDataVersion(int(blockData.DataVersion))
 
-	containsAirComputed := blockCount < width*height*length
-	recordHeaderMetadata(s, &header, blockCount, containsAirComputed)
+	recordHeaderMetadata(s, &header, thumbnail, blockCount)
+	base.SetExtra(s, "data", blockData.Extra)
 
 	for _, placement := range placements {
 		x := int(placement.X) - minX
@@ -401,12 +413,63 @@ func Read(r io.Reader) (base.Schematic, error) {
 	return s, nil
 }
 
-// Write writes a schematic as Axiom blueprint format.
+// Options configures how WriteWithOptions writes a blueprint.
+type Options struct {
+	// RenderThumbnail renders a thumbnail for blueprints without one, and for
+	// blueprints edited since their thumbnail was taken unless it is locked.
+	// Otherwise the thumbnail read with the blueprint, if any, is written.
+	RenderThumbnail bool
+}
+
+// Write writes a schematic as Axiom blueprint format, with the thumbnail it
+// was read with, if any.
 func Write(w io.Writer, schem base.Schematic) error {
+	return WriteWithOptions(w, schem, Options{})
+}
+
+// WriteWithOptions writes a schematic as Axiom blueprint format according to
+// the options passed.
+func WriteWithOptions(w io.Writer, schem base.Schematic, opts Options) error {
 	width, height, length := schem.Dimensions()
 	offsetX, offsetY, offsetZ := schem.Offset()
 
-	header := &headerNBT{Version: 1}
+	info := schem.Info()
+	header := &headerNBT{
+		Version: headerVersion,
+		Name:    info.Name,
+		Author:  info.Author,
+		Tags:    info.Tags,
+		Extra:   base.Extra(schem, "axiom", "header"),
+	}
+	var thumbnail []byte
+	if schem.Format() == "axiom" {
+		meta := schem.Metadata()
+		if version, ok := meta["Version"].(int32); ok && version != 0 {
+			header.Version = version
+		}
+		header.ThumbnailYaw, _ = meta["ThumbnailYaw"].(float32)
+		header.ThumbnailPitch, _ = meta["ThumbnailPitch"].(float32)
+		header.LockedThumbnail, _ = meta["LockedThumbnail"].(bool)
+		thumbnail, _ = meta["Thumbnail"].([]byte)
+	}
+	if opts.RenderThumbnail && (len(thumbnail) == 0 || (!header.LockedThumbnail && base.Changed(schem))) {
+		// Blueprints without a thumbnail, or edited since theirs was taken,
+		// get a rendered one.
+		if header.ThumbnailYaw == 0 && header.ThumbnailPitch == 0 {
+			header.ThumbnailYaw, header.ThumbnailPitch = render.DefaultYaw, render.DefaultPitch
+		}
+		var buf bytes.Buffer
+		err := render.WritePNG(&buf, schem, render.Options{
+			View:  render.Isometric,
+			Yaw:   header.ThumbnailYaw,
+			Pitch: header.ThumbnailPitch,
+			Size:  thumbnailSize,
+		})
+		if err != nil {
+			return fmt.Errorf("render thumbnail: %w", err)
+		}
+		thumbnail = buf.Bytes()
+	}
 
 	chunks := make(map[chunkKey]*chunkBuilder)
 	blockCount := 0
@@ -415,11 +478,17 @@ func Write(w io.Writer, schem base.Schematic) error {
 	for y := range height {
 		for z := range length {
 			for x := range width {
+				// Positions without a block are left empty, and air is
+				// kept as air, which makes the blueprint contain air.
 				block := schem.Block(x, y, z)
 				if block == nil || isEmptyBlock(block.Name) {
-					containsAir = true
 					continue
 				}
+				if isAirBlock(block.Name) {
+					containsAir = true
+				} else {
+					blockCount++
+				}
 
 				worldX := int32(x + offsetX)
 				worldY := int32(y + offsetY)
@@ -451,7 +520,6 @@ func Write(w io.Writer, schem base.Schematic) error {
 					chunks[key] = builder
 				}
 				builder.set(localX, localY, localZ, block)
-				blockCount++
 			}
 		}
 	}
@@ -481,26 +549,13 @@ func Write(w io.Writer, schem base.Schematic) error {
 		chunkList = append(chunkList, newChunkBuilder().toNBT(0, 0, 0))
 	}
 
-	if blockCount == 0 {
-		containsAir = true
-	}
-	if header.Version == 0 {
-		header.Version = 1
-	}
-	if header.Name == "" {
-		header.Name = "Converted Blueprint"
-	}
-	if len(header.Tags) == 0 {
-		header.Tags = []string{"converted"}
-	}
 	header.BlockCount = int32(blockCount)
-	if !header.ContainsAir && containsAir {
-		header.ContainsAir = true
-	}
+	header.ContainsAir = containsAir
 
 	blockData := blockDataNBT{
 		DataVersion: int32(schem.DataVersion()),
 		BlockRegion: chunkList,
+		Extra:       base.Extra(schem, "axiom", "data"),
 	}
 	if len(blockEntityMaps) > 0 {
 		blockData.BlockEntities = blockEntityMaps
@@ -509,22 +564,18 @@ func Write(w io.Writer, schem base.Schematic) error {
 		blockData.Entities = entityMaps
 	}
 
-	var headerBuf bytes.Buffer
-	if err := nbt.NewEncoderWithEncoding(&headerBuf, nbt.BigEndian).Encode(header); err != nil {
+	headerData, err := base.MarshalNBT(header)
+	if err != nil {
 		return fmt.Errorf("encode header nbt: %w", err)
 	}
+	headerBuf := bytes.NewBuffer(headerData)
 	if headerBuf.Len() > int(math.MaxUint32) {
 		return fmt.Errorf("header too large: %d bytes", headerBuf.Len())
 	}
 
 	var dataBuf bytes.Buffer
-	gz := gzip.NewWriter(&dataBuf)
-	if err := nbt.NewEncoderWithEncoding(gz, nbt.BigEndian).Encode(blockData); err != nil {
-		gz.Close()
-		return fmt.Errorf("encode block data nbt: %w", err)
-	}
-	if err := gz.Close(); err != nil {
-		return fmt.Errorf("close gzip: %w", err)
+	if err := base.WriteGzipNBT(&dataBuf, blockData, 0); err != nil {
+		return fmt.Errorf("encode block data: %w", err)
 	}
 	if dataBuf.Len() > int(math.MaxUint32) {
 		return fmt.Errorf("block data too large: %d bytes", dataBuf.Len())
@@ -540,12 +591,15 @@ func Write(w io.Writer, schem base.Schematic) error {
 		return fmt.Errorf("write header: %w", err)
 	}
 
-	if len(header.Tags) > int(math.MaxUint32) {
-		return fmt.Errorf("thumbnail too large: %d bytes", len(header.Tags))
+	if len(thumbnail) > int(math.MaxUint32) {
+		return fmt.Errorf("thumbnail too large: %d bytes", len(thumbnail))
 	}
-	if err := binary.Write(w, binary.BigEndian, uint32(0)); err != nil {
+	if err := binary.Write(w, binary.BigEndian, uint32(len(thumbnail))); err != nil {
 		return fmt.Errorf("write thumbnail length: %w", err)
 	}
+	if _, err := w.Write(thumbnail); err != nil {
+		return fmt.Errorf("write thumbnail: %w", err)
+	}
 
 	if err := binary.Write(w, binary.BigEndian, uint32(dataBuf.Len())); err != nil {
 		return fmt.Errorf("write data length: %w", err)
@@ -573,9 +627,16 @@ func bitsPerBlock(paletteSize int) int {
 	return bits
 }
 
+// isEmptyBlock reports whether a block name marks a position that is not part
+// of the blueprint.
 func isEmptyBlock(name string) bool {
+	return name == "" || name == defaultEmptyBlock
+}
+
+// isAirBlock checks if a block name is an air variant.
+func isAirBlock(name string) bool {
 	switch name {
-	case "", "minecraft:air", "minecraft:void_air", "minecraft:cave_air", "minecraft:structure_void":
+	case "minecraft:air", "minecraft:void_air", "minecraft:cave_air":
 		return true
 	default:
 		return false
@@ -610,19 +671,16 @@ func blockStateKey(block *base.BlockState) string {
 	return builder.String()
 }
 
-func recordHeaderMetadata(s base.Schematic, header *headerNBT, computedBlocks int, containsAir bool) {
+func recordHeaderMetadata(s base.Schematic, header *headerNBT, thumbnail []byte, computedBlocks int) {
 	if header == nil {
 		return
 	}
-	if header.Name != "" {
-		s.SetMetadata("Name", header.Name)
-	}
-	if header.Author != "" {
-		s.SetMetadata("Author", header.Author)
-	}
-	if len(header.Tags) > 0 {
-		s.SetMetadata("Tags", append([]string(nil), header.Tags...))
-	}
+	s.SetInfo(base.Info{
+		Name:   header.Name,
+		Author: header.Author,
+		Tags:   header.Tags,
+		Tool:   "Axiom",
+	})
 	s.SetMetadata("Version", header.Version)
 	s.SetMetadata("BlockCount", int(header.BlockCount))
 	s.SetMetadata("ContainsAir", header.ContainsAir)
@@ -635,10 +693,11 @@ func recordHeaderMetadata(s base.Schematic, header *headerNBT, computedBlocks in
 	if header.LockedThumbnail {
 		s.SetMetadata("LockedThumbnail", header.LockedThumbnail)
 	}
-	s.SetMetadata("ComputedBlockCount", computedBlocks)
-	if containsAir {
-		s.SetMetadata("ComputedContainsAir", true)
+	if len(thumbnail) > 0 {
+		s.SetMetadata("Thumbnail", thumbnail)
 	}
+	s.SetMetadata("ComputedBlockCount", computedBlocks)
+	base.SetExtra(s, "header", header.Extra)
 }
 
 func collectBlockEntities(schem base.Schematic, offsetX, offsetY, offsetZ int) []map[string]any {
@@ -652,9 +711,9 @@ func collectBlockEntities(schem base.Schematic, offsetX, offsetY, offsetZ int) [
 					continue
 				}
 				m := make(map[string]any, len(be.Data)+4)
-				m["x"] = be.X + offsetX
-				m["y"] = be.Y + offsetY
-				m["z"] = be.Z + offsetZ
+				m["x"] = int32(be.X + offsetX)
+				m["y"] = int32(be.Y + offsetY)
+				m["z"] = int32(be.Z + offsetZ)
 				if be.ID != "" {
 					m["id"] = be.ID
 				}
@@ -0,0 +1,258 @@
+package base
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/oriumgames/nbt"
+)
+
+// NBT tag types, as found in encoded NBT data.
+const (
+	tagEnd byte = iota
+	tagByte
+	tagShort
+	tagInt
+	tagLong
+	tagFloat
+	tagDouble
+	tagByteArray
+	tagString
+	tagList
+	tagCompound
+	tagIntArray
+	tagLongArray
+)
+
+// maxNBTDepth is the maximum nesting of lists and compounds accepted by
+// CanonicalNBT.
+const maxNBTDepth = 512
+
+// MarshalNBT encodes v as big endian NBT in canonical form. Go maps are
+// encoded in random order, so the entries of every compound are sorted by name
+// to make equal values always produce the same bytes.
+func MarshalNBT(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := nbt.NewEncoderWithEncoding(&buf, nbt.BigEndian).Encode(v); err != nil {
+		return nil, err
+	}
+	return CanonicalNBT(buf.Bytes(), binary.BigEndian)
+}
+
+// WriteGzipNBT encodes v as big endian NBT in canonical form and writes it to w
+// gzip compressed. The gzip header holds no name or modification time, so
+// that the output only depends on v. A level of 0 uses
+// gzip.DefaultCompression.
+func WriteGzipNBT(w io.Writer, v any, level int) error {
+	data, err := MarshalNBT(v)
+	if err != nil {
+		return fmt.Errorf("encode nbt: %w", err)
+	}
+	return WriteGzip(w, data, level)
+}
+
+// WriteGzip writes data to w gzip compressed with a header that holds no name
+// or modification time. A level of 0 uses gzip.DefaultCompression.
+func WriteGzip(w io.Writer, data []byte, level int) error {
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	gz, err := gzip.NewWriterLevel(w, level)
+	if err != nil {
+		return fmt.Errorf("gzip compress: %w", err)
+	}
+	gz.Header = gzip.Header{OS: 255}
+	if _, err := gz.Write(data); err != nil {
+		return fmt.Errorf("gzip compress: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("close gzip: %w", err)
+	}
+	return nil
+}
+
+// CanonicalNBT rewrites encoded NBT data so that the entries of every compound
+// are sorted by name. The data must hold a single named root tag.
+func CanonicalNBT(data []byte, order binary.ByteOrder) ([]byte, error) {
+	c := canonicalizer{data: data, order: order}
+	var out bytes.Buffer
+	t, err := c.byte()
+	if err != nil {
+		return nil, err
+	}
+	name, err := c.string()
+	if err != nil {
+		return nil, err
+	}
+	out.WriteByte(t)
+	c.writeString(&out, name)
+	if err := c.payload(&out, t, 0); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// canonicalizer reads NBT data and writes it back in canonical form.
+type canonicalizer struct {
+	data  []byte
+	off   int
+	order binary.ByteOrder
+}
+
+// payload copies the payload of a tag of type t, sorting compound entries.
+func (c *canonicalizer) payload(out *bytes.Buffer, t byte, depth int) error {
+	if depth > maxNBTDepth {
+		return fmt.Errorf("nbt nested too deeply")
+	}
+	switch t {
+	case tagByte:
+		return c.copy(out, 1)
+	case tagShort:
+		return c.copy(out, 2)
+	case tagInt, tagFloat:
+		return c.copy(out, 4)
+	case tagLong, tagDouble:
+		return c.copy(out, 8)
+	case tagByteArray:
+		return c.array(out, 1)
+	case tagIntArray:
+		return c.array(out, 4)
+	case tagLongArray:
+		return c.array(out, 8)
+	case tagString:
+		s, err := c.string()
+		if err != nil {
+			return err
+		}
+		c.writeString(out, s)
+		return nil
+	case tagList:
+		elemType, err := c.byte()
+		if err != nil {
+			return err
+		}
+		out.WriteByte(elemType)
+		n, err := c.length(out)
+		if err != nil {
+			return err
+		}
+		for range n {
+			if err := c.payload(out, elemType, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	case tagCompound:
+		return c.compound(out, depth)
+	default:
+		return fmt.Errorf("invalid nbt tag type %d at offset %d", t, c.off)
+	}
+}
+
+// compound copies the entries of a compound sorted by name.
+func (c *canonicalizer) compound(out *bytes.Buffer, depth int) error {
+	type entry struct {
+		name string
+		data []byte
+	}
+	var entries []entry
+	for {
+		t, err := c.byte()
+		if err != nil {
+			return err
+		}
+		if t == tagEnd {
+			break
+		}
+		name, err := c.string()
+		if err != nil {
+			return err
+		}
+		var buf bytes.Buffer
+		buf.WriteByte(t)
+		c.writeString(&buf, name)
+		if err := c.payload(&buf, t, depth+1); err != nil {
+			return err
+		}
+		entries = append(entries, entry{name: name, data: buf.Bytes()})
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].name < entries[j].name
+	})
+	for _, e := range entries {
+		out.Write(e.data)
+	}
+	out.WriteByte(tagEnd)
+	return nil
+}
+
+// byte reads a single byte.
+func (c *canonicalizer) byte() (byte, error) {
+	if c.off >= len(c.data) {
+		return 0, io.ErrUnexpectedEOF
+	}
+	b := c.data[c.off]
+	c.off++
+	return b, nil
+}
+
+// string reads a length-prefixed string.
+func (c *canonicalizer) string() (string, error) {
+	if c.off+2 > len(c.data) {
+		return "", io.ErrUnexpectedEOF
+	}
+	n := int(c.order.Uint16(c.data[c.off:]))
+	c.off += 2
+	if c.off+n > len(c.data) {
+		return "", io.ErrUnexpectedEOF
+	}
+	s := string(c.data[c.off : c.off+n])
+	c.off += n
+	return s, nil
+}
+
+// writeString writes a length-prefixed string.
+func (c *canonicalizer) writeString(out *bytes.Buffer, s string) {
+	var n [2]byte
+	c.order.PutUint16(n[:], uint16(len(s)))
+	out.Write(n[:])
+	out.WriteString(s)
+}
+
+// length reads the length of an array or list and copies it to out.
+func (c *canonicalizer) length(out *bytes.Buffer) (int, error) {
+	if c.off+4 > len(c.data) {
+		return 0, io.ErrUnexpectedEOF
+	}
+	n := int(int32(c.order.Uint32(c.data[c.off:])))
+	if n < 0 {
+		n = 0
+	}
+	if err := c.copy(out, 4); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// array copies an array with elements of the size passed.
+func (c *canonicalizer) array(out *bytes.Buffer, size int) error {
+	n, err := c.length(out)
+	if err != nil {
+		return err
+	}
+	return c.copy(out, n*size)
+}
+
+// copy copies n bytes to out unchanged.
+func (c *canonicalizer) copy(out *bytes.Buffer, n int) error {
+	if n < 0 || c.off+n > len(c.data) {
+		return io.ErrUnexpectedEOF
+	}
+	out.Write(c.data[c.off : c.off+n])
+	c.off += n
+	return nil
+}
@@ -0,0 +1,436 @@
+package base
+
+import (
+	"image/color"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// ColorTable maps blocks to colours. Keys are block names such as
+// "minecraft:stone", or block state strings such as
+// "minecraft:oak_log[axis=x]" for colours that apply to a single state.
+type ColorTable map[string]color.RGBA
+
+// DefaultColors returns a copy of the default colour table, which holds the
+// average texture colour of the full blocks of the game, as seen from above.
+// Translucent blocks, such as glass and water, have an alpha below 255.
+func DefaultColors() ColorTable {
+	return maps.Clone(defaultColors)
+}
+
+// UnknownColor is the colour given to blocks missing from a colour table.
+var UnknownColor = color.RGBA{R: 128, G: 128, B: 128, A: 255}
+
+// IsEmpty reports whether a block is invisible, and so left out of models
+// and images: air, structure voids, barriers and light blocks.
+func IsEmpty(name string) bool {
+	switch name {
+	case "", "minecraft:air", "minecraft:cave_air", "minecraft:void_air", "minecraft:structure_void",
+		"minecraft:barrier", "minecraft:light":
+		return true
+	}
+	return false
+}
+
+// Color returns the colour of a block state. States without an entry of their
+// own use the entry of their name, and blocks missing from the table, such as
+// stairs, slabs and carpets, borrow the colour of the block they are made of
+// or of the dye in their name. It returns false if no colour was found.
+func (t ColorTable) Color(state *BlockState) (color.RGBA, bool) {
+	if state == nil {
+		return color.RGBA{}, false
+	}
+	if len(state.Properties) > 0 {
+		if c, ok := t[state.String()]; ok {
+			return c, true
+		}
+	}
+	if c, ok := t[state.Name]; ok {
+		return c, true
+	}
+
+	namespace, path, found := strings.Cut(state.Name, ":")
+	if !found {
+		namespace, path = "minecraft", state.Name
+	}
+	for _, candidate := range materialCandidates(path) {
+		if c, ok := t[namespace+":"+candidate]; ok {
+			return c, true
+		}
+	}
+	for _, dye := range dyeColors {
+		if strings.HasPrefix(path, dye+"_") {
+			if c, ok := t[namespace+":"+dye+"_wool"]; ok {
+				return c, true
+			}
+		}
+	}
+	return color.RGBA{}, false
+}
+
+// Nearest returns the block whose colour is closest to c. Ties are resolved in
+// favour of the key that sorts first, so the result is deterministic.
+func (t ColorTable) Nearest(c color.RGBA) BlockState {
+	best, bestDist := "", -1
+	for _, key := range slices.Sorted(maps.Keys(t)) {
+		if d := colorDistance(c, t[key]); bestDist < 0 || d < bestDist {
+			best, bestDist = key, d
+		}
+	}
+	if best == "" {
+		return BlockState{Name: "minecraft:air"}
+	}
+	return *ParseBlockState(best)
+}
+
+// colorDistance returns the squared distance between two colours, weighting
+// the channels by how sensitive the eye is to them.
+func colorDistance(a, b color.RGBA) int {
+	rMean := (int(a.R) + int(b.R)) / 2
+	dr, dg, db, da := int(a.R)-int(b.R), int(a.G)-int(b.G), int(a.B)-int(b.B), int(a.A)-int(b.A)
+	return ((512+rMean)*dr*dr)>>8 + 4*dg*dg + ((767-rMean)*db*db)>>8 + 2*da*da
+}
+
+// shapeSuffixes are the suffixes of blocks shaped from another block.
+var shapeSuffixes = []string{
+	"_stairs", "_slab", "_wall", "_fence_gate", "_fence", "_pressure_plate", "_button",
+	"_door", "_trapdoor", "_wall_hanging_sign", "_hanging_sign", "_wall_sign", "_sign",
+	"_pane", "_carpet", "_bed", "_wall_banner", "_banner", "_candle", "_shulker_box",
+	"_glazed_terracotta",
+}
+
+// materialCandidates returns the names of the blocks a block may be made of,
+// in order of preference.
+func materialCandidates(path string) []string {
+	path = strings.TrimPrefix(path, "waxed_")
+	path = strings.TrimPrefix(path, "potted_")
+	if rest, ok := strings.CutPrefix(path, "stripped_"); ok {
+		for _, wood := range []string{"_log", "_wood", "_stem", "_hyphae"} {
+			if base, ok := strings.CutSuffix(rest, wood); ok {
+				return []string{base + "_planks"}
+			}
+		}
+		path = rest
+	}
+	if base, ok := strings.CutSuffix(path, "_wood"); ok {
+		return []string{base + "_log"}
+	}
+	if base, ok := strings.CutSuffix(path, "_hyphae"); ok {
+		return []string{base + "_stem"}
+	}
+	if strings.HasSuffix(path, "_ore") {
+		if strings.HasPrefix(path, "deepslate_") {
+			return []string{"deepslate"}
+		}
+		return []string{"stone"}
+	}
+
+	for _, suffix := range shapeSuffixes {
+		if base, ok := strings.CutSuffix(path, suffix); ok {
+			path = base
+			break
+		}
+	}
+	candidates := []string{path, path + "s", path + "_planks", path + "_block"}
+	for _, prefix := range variantPrefixes {
+		if base, ok := strings.CutPrefix(path, prefix); ok {
+			candidates = append(candidates, base, base+"s", base+"_block")
+		}
+	}
+	return candidates
+}
+
+// variantPrefixes are the prefixes of blocks that look much like the block
+// without the prefix.
+var variantPrefixes = []string{"cracked_", "chiseled_", "infested_", "polished_", "smooth_", "cut_"}
+
+// dyeColors are the dye colours of the game, longest first so that
+// light_blue is matched before blue.
+var dyeColors = []string{
+	"light_blue", "light_gray", "magenta", "orange", "yellow", "purple", "white", "brown",
+	"green", "black", "lime", "pink", "gray", "cyan", "blue", "red",
+}
+
+func rgb(r, g, b uint8) color.RGBA {
+	return color.RGBA{R: r, G: g, B: b, A: 255}
+}
+
+func rgba(r, g, b, a uint8) color.RGBA {
+	return color.RGBA{R: r, G: g, B: b, A: a}
+}
+
+var defaultColors = ColorTable{
+	// Stone and terrain.
+	"minecraft:stone":                rgb(125, 125, 125),
+	"minecraft:granite":              rgb(149, 103, 85),
+	"minecraft:polished_granite":     rgb(154, 106, 89),
+	"minecraft:diorite":              rgb(188, 188, 188),
+	"minecraft:polished_diorite":     rgb(192, 193, 194),
+	"minecraft:andesite":             rgb(136, 136, 136),
+	"minecraft:polished_andesite":    rgb(132, 134, 133),
+	"minecraft:deepslate":            rgb(80, 80, 82),
+	"minecraft:cobbled_deepslate":    rgb(77, 77, 80),
+	"minecraft:polished_deepslate":   rgb(72, 72, 73),
+	"minecraft:deepslate_bricks":     rgb(70, 70, 71),
+	"minecraft:deepslate_tiles":      rgb(54, 54, 55),
+	"minecraft:calcite":              rgb(223, 224, 220),
+	"minecraft:tuff":                 rgb(108, 109, 102),
+	"minecraft:polished_tuff":        rgb(97, 104, 99),
+	"minecraft:tuff_bricks":          rgb(98, 103, 95),
+	"minecraft:dripstone_block":      rgb(134, 107, 92),
+	"minecraft:cobblestone":          rgb(127, 127, 127),
+	"minecraft:mossy_cobblestone":    rgb(110, 118, 94),
+	"minecraft:stone_bricks":         rgb(122, 121, 122),
+	"minecraft:mossy_stone_bricks":   rgb(115, 121, 105),
+	"minecraft:smooth_stone":         rgb(158, 158, 158),
+	"minecraft:bricks":               rgb(150, 97, 83),
+	"minecraft:bedrock":              rgb(85, 85, 85),
+	"minecraft:obsidian":             rgb(15, 10, 24),
+	"minecraft:crying_obsidian":      rgb(32, 10, 60),
+	"minecraft:grass_block":          rgb(95, 159, 53),
+	"minecraft:dirt":                 rgb(134, 96, 67),
+	"minecraft:coarse_dirt":          rgb(119, 85, 59),
+	"minecraft:rooted_dirt":          rgb(144, 103, 76),
+	"minecraft:podzol":               rgb(91, 63, 24),
+	"minecraft:mycelium":             rgb(111, 99, 105),
+	"minecraft:dirt_path":            rgb(148, 122, 65),
+	"minecraft:farmland":             rgb(110, 76, 48),
+	"minecraft:mud":                  rgb(60, 57, 60),
+	"minecraft:packed_mud":           rgb(142, 106, 79),
+	"minecraft:mud_bricks":           rgb(137, 103, 79),
+	"minecraft:clay":                 rgb(160, 166, 179),
+	"minecraft:gravel":               rgb(131, 127, 126),
+	"minecraft:sand":                 rgb(219, 207, 163),
+	"minecraft:red_sand":             rgb(190, 102, 33),
+	"minecraft:sandstone":            rgb(216, 203, 155),
+	"minecraft:cut_sandstone":        rgb(217, 206, 159),
+	"minecraft:smooth_sandstone":     rgb(223, 214, 170),
+	"minecraft:red_sandstone":        rgb(186, 99, 29),
+	"minecraft:cut_red_sandstone":    rgb(189, 101, 31),
+	"minecraft:smooth_red_sandstone": rgb(181, 97, 31),
+	"minecraft:moss_block":           rgb(89, 109, 45),
+	"minecraft:snow_block":           rgb(249, 254, 254),
+	"minecraft:ice":                  rgba(145, 183, 253, 190),
+	"minecraft:packed_ice":           rgb(141, 180, 250),
+	"minecraft:blue_ice":             rgb(116, 167, 253),
+	"minecraft:water":                rgba(63, 118, 228, 180),
+	"minecraft:lava":                 rgb(207, 92, 20),
+
+	// Wood.
+	"minecraft:oak_log":              rgb(109, 85, 50),
+	"minecraft:oak_planks":           rgb(162, 130, 78),
+	"minecraft:spruce_log":           rgb(58, 37, 16),
+	"minecraft:spruce_planks":        rgb(114, 84, 48),
+	"minecraft:birch_log":            rgb(216, 215, 210),
+	"minecraft:birch_planks":         rgb(192, 175, 121),
+	"minecraft:jungle_log":           rgb(85, 67, 25),
+	"minecraft:jungle_planks":        rgb(160, 115, 80),
+	"minecraft:acacia_log":           rgb(103, 96, 86),
+	"minecraft:acacia_planks":        rgb(168, 90, 50),
+	"minecraft:dark_oak_log":         rgb(60, 46, 26),
+	"minecraft:dark_oak_planks":      rgb(66, 43, 20),
+	"minecraft:mangrove_log":         rgb(84, 66, 41),
+	"minecraft:mangrove_planks":      rgb(117, 54, 48),
+	"minecraft:cherry_log":           rgb(54, 33, 44),
+	"minecraft:cherry_planks":        rgb(226, 178, 172),
+	"minecraft:pale_oak_log":         rgb(87, 77, 75),
+	"minecraft:pale_oak_planks":      rgb(227, 217, 216),
+	"minecraft:bamboo_block":         rgb(127, 143, 52),
+	"minecraft:bamboo_planks":        rgb(193, 173, 80),
+	"minecraft:crimson_stem":         rgb(92, 25, 29),
+	"minecraft:crimson_planks":       rgb(101, 48, 70),
+	"minecraft:warped_stem":          rgb(58, 58, 77),
+	"minecraft:warped_planks":        rgb(43, 104, 99),
+	"minecraft:oak_leaves":           rgb(72, 115, 36),
+	"minecraft:spruce_leaves":        rgb(61, 99, 61),
+	"minecraft:birch_leaves":         rgb(107, 141, 64),
+	"minecraft:jungle_leaves":        rgb(48, 114, 13),
+	"minecraft:acacia_leaves":        rgb(85, 117, 35),
+	"minecraft:dark_oak_leaves":      rgb(54, 91, 19),
+	"minecraft:mangrove_leaves":      rgb(72, 132, 30),
+	"minecraft:cherry_leaves":        rgb(229, 173, 194),
+	"minecraft:azalea_leaves":        rgb(90, 115, 44),
+	"minecraft:pale_oak_leaves":      rgb(160, 166, 155),
+	"minecraft:bookshelf":            rgb(117, 94, 59),
+	"minecraft:crafting_table":       rgb(119, 73, 42),
+	"minecraft:note_block":           rgb(88, 58, 40),
+	"minecraft:jukebox":              rgb(93, 64, 47),
+	"minecraft:brown_mushroom_block": rgb(149, 111, 81),
+	"minecraft:red_mushroom_block":   rgb(200, 46, 45),
+	"minecraft:mushroom_stem":        rgb(203, 196, 185),
+
+	// Wool.
+	"minecraft:white_wool":      rgb(234, 236, 237),
+	"minecraft:orange_wool":     rgb(241, 118, 20),
+	"minecraft:magenta_wool":    rgb(190, 69, 180),
+	"minecraft:light_blue_wool": rgb(58, 175, 217),
+	"minecraft:yellow_wool":     rgb(249, 198, 40),
+	"minecraft:lime_wool":       rgb(112, 185, 26),
+	"minecraft:pink_wool":       rgb(238, 141, 172),
+	"minecraft:gray_wool":       rgb(63, 68, 72),
+	"minecraft:light_gray_wool": rgb(142, 142, 135),
+	"minecraft:cyan_wool":       rgb(21, 138, 145),
+	"minecraft:purple_wool":     rgb(122, 42, 173),
+	"minecraft:blue_wool":       rgb(53, 57, 157),
+	"minecraft:brown_wool":      rgb(114, 72, 41),
+	"minecraft:green_wool":      rgb(85, 110, 28),
+	"minecraft:red_wool":        rgb(161, 39, 35),
+	"minecraft:black_wool":      rgb(21, 21, 26),
+
+	// Concrete.
+	"minecraft:white_concrete":      rgb(207, 213, 214),
+	"minecraft:orange_concrete":     rgb(224, 97, 1),
+	"minecraft:magenta_concrete":    rgb(169, 48, 159),
+	"minecraft:light_blue_concrete": rgb(36, 137, 199),
+	"minecraft:yellow_concrete":     rgb(241, 175, 21),
+	"minecraft:lime_concrete":       rgb(94, 169, 24),
+	"minecraft:pink_concrete":       rgb(214, 101, 143),
+	"minecraft:gray_concrete":       rgb(55, 58, 62),
+	"minecraft:light_gray_concrete": rgb(125, 125, 115),
+	"minecraft:cyan_concrete":       rgb(21, 119, 136),
+	"minecraft:purple_concrete":     rgb(100, 32, 156),
+	"minecraft:blue_concrete":       rgb(45, 47, 143),
+	"minecraft:brown_concrete":      rgb(96, 60, 32),
+	"minecraft:green_concrete":      rgb(73, 91, 36),
+	"minecraft:red_concrete":        rgb(142, 33, 33),
+	"minecraft:black_concrete":      rgb(8, 10, 15),
+
+	// Concrete powder.
+	"minecraft:white_concrete_powder":      rgb(226, 227, 228),
+	"minecraft:orange_concrete_powder":     rgb(227, 132, 32),
+	"minecraft:magenta_concrete_powder":    rgb(193, 84, 185),
+	"minecraft:light_blue_concrete_powder": rgb(74, 181, 213),
+	"minecraft:yellow_concrete_powder":     rgb(233, 199, 55),
+	"minecraft:lime_concrete_powder":       rgb(125, 189, 42),
+	"minecraft:pink_concrete_powder":       rgb(229, 153, 181),
+	"minecraft:gray_concrete_powder":       rgb(77, 81, 85),
+	"minecraft:light_gray_concrete_powder": rgb(155, 155, 148),
+	"minecraft:cyan_concrete_powder":       rgb(37, 148, 157),
+	"minecraft:purple_concrete_powder":     rgb(132, 56, 178),
+	"minecraft:blue_concrete_powder":       rgb(70, 73, 167),
+	"minecraft:brown_concrete_powder":      rgb(126, 85, 54),
+	"minecraft:green_concrete_powder":      rgb(97, 119, 45),
+	"minecraft:red_concrete_powder":        rgb(168, 54, 51),
+	"minecraft:black_concrete_powder":      rgb(25, 27, 32),
+
+	// Terracotta.
+	"minecraft:terracotta":            rgb(152, 94, 68),
+	"minecraft:white_terracotta":      rgb(210, 178, 161),
+	"minecraft:orange_terracotta":     rgb(162, 84, 38),
+	"minecraft:magenta_terracotta":    rgb(150, 88, 109),
+	"minecraft:light_blue_terracotta": rgb(113, 109, 138),
+	"minecraft:yellow_terracotta":     rgb(186, 133, 35),
+	"minecraft:lime_terracotta":       rgb(104, 118, 53),
+	"minecraft:pink_terracotta":       rgb(162, 78, 79),
+	"minecraft:gray_terracotta":       rgb(58, 42, 36),
+	"minecraft:light_gray_terracotta": rgb(135, 107, 98),
+	"minecraft:cyan_terracotta":       rgb(87, 91, 91),
+	"minecraft:purple_terracotta":     rgb(118, 70, 86),
+	"minecraft:blue_terracotta":       rgb(74, 60, 91),
+	"minecraft:brown_terracotta":      rgb(77, 51, 36),
+	"minecraft:green_terracotta":      rgb(76, 83, 42),
+	"minecraft:red_terracotta":        rgb(143, 61, 47),
+	"minecraft:black_terracotta":      rgb(37, 23, 16),
+
+	// Glass.
+	"minecraft:glass":                    rgba(175, 213, 219, 96),
+	"minecraft:tinted_glass":             rgba(44, 38, 46, 200),
+	"minecraft:white_stained_glass":      rgba(255, 255, 255, 128),
+	"minecraft:orange_stained_glass":     rgba(216, 127, 51, 128),
+	"minecraft:magenta_stained_glass":    rgba(178, 76, 216, 128),
+	"minecraft:light_blue_stained_glass": rgba(102, 153, 216, 128),
+	"minecraft:yellow_stained_glass":     rgba(229, 229, 51, 128),
+	"minecraft:lime_stained_glass":       rgba(127, 204, 25, 128),
+	"minecraft:pink_stained_glass":       rgba(242, 127, 165, 128),
+	"minecraft:gray_stained_glass":       rgba(76, 76, 76, 128),
+	"minecraft:light_gray_stained_glass": rgba(153, 153, 153, 128),
+	"minecraft:cyan_stained_glass":       rgba(76, 127, 153, 128),
+	"minecraft:purple_stained_glass":     rgba(127, 63, 178, 128),
+	"minecraft:blue_stained_glass":       rgba(51, 76, 178, 128),
+	"minecraft:brown_stained_glass":      rgba(102, 76, 51, 128),
+	"minecraft:green_stained_glass":      rgba(102, 127, 51, 128),
+	"minecraft:red_stained_glass":        rgba(153, 51, 51, 128),
+	"minecraft:black_stained_glass":      rgba(25, 25, 25, 128),
+
+	// Ores and mineral blocks.
+	"minecraft:coal_ore":         rgb(105, 105, 105),
+	"minecraft:iron_ore":         rgb(136, 129, 122),
+	"minecraft:gold_ore":         rgb(145, 133, 106),
+	"minecraft:diamond_ore":      rgb(121, 141, 140),
+	"minecraft:coal_block":       rgb(16, 15, 15),
+	"minecraft:iron_block":       rgb(220, 220, 220),
+	"minecraft:gold_block":       rgb(246, 208, 61),
+	"minecraft:redstone_block":   rgb(175, 24, 5),
+	"minecraft:emerald_block":    rgb(42, 203, 87),
+	"minecraft:lapis_block":      rgb(30, 67, 140),
+	"minecraft:diamond_block":    rgb(98, 237, 228),
+	"minecraft:netherite_block":  rgb(66, 61, 63),
+	"minecraft:amethyst_block":   rgb(133, 97, 191),
+	"minecraft:raw_iron_block":   rgb(166, 135, 107),
+	"minecraft:raw_copper_block": rgb(154, 105, 79),
+	"minecraft:raw_gold_block":   rgb(221, 169, 46),
+	"minecraft:copper_block":     rgb(192, 107, 79),
+	"minecraft:exposed_copper":   rgb(161, 125, 103),
+	"minecraft:weathered_copper": rgb(108, 153, 110),
+	"minecraft:oxidized_copper":  rgb(82, 162, 132),
+	"minecraft:cut_copper":       rgb(191, 106, 80),
+	"minecraft:quartz_block":     rgb(235, 229, 222),
+	"minecraft:smooth_quartz":    rgb(235, 229, 222),
+	"minecraft:quartz_bricks":    rgb(234, 229, 221),
+	"minecraft:quartz_pillar":    rgb(235, 230, 224),
+
+	// Nether and End.
+	"minecraft:netherrack":                 rgb(97, 38, 38),
+	"minecraft:nether_bricks":              rgb(44, 21, 26),
+	"minecraft:red_nether_bricks":          rgb(69, 7, 9),
+	"minecraft:soul_sand":                  rgb(81, 62, 50),
+	"minecraft:soul_soil":                  rgb(75, 57, 46),
+	"minecraft:basalt":                     rgb(80, 81, 86),
+	"minecraft:polished_basalt":            rgb(99, 98, 100),
+	"minecraft:smooth_basalt":              rgb(72, 72, 78),
+	"minecraft:blackstone":                 rgb(42, 36, 41),
+	"minecraft:polished_blackstone":        rgb(53, 48, 56),
+	"minecraft:polished_blackstone_bricks": rgb(48, 42, 49),
+	"minecraft:gilded_blackstone":          rgb(56, 43, 38),
+	"minecraft:glowstone":                  rgb(171, 131, 84),
+	"minecraft:shroomlight":                rgb(240, 146, 70),
+	"minecraft:magma_block":                rgb(142, 63, 31),
+	"minecraft:crimson_nylium":             rgb(130, 31, 31),
+	"minecraft:warped_nylium":              rgb(43, 114, 101),
+	"minecraft:nether_wart_block":          rgb(114, 2, 2),
+	"minecraft:warped_wart_block":          rgb(22, 119, 121),
+	"minecraft:ancient_debris":             rgb(94, 66, 58),
+	"minecraft:end_stone":                  rgb(219, 222, 158),
+	"minecraft:end_stone_bricks":           rgb(218, 224, 162),
+	"minecraft:purpur_block":               rgb(169, 125, 169),
+	"minecraft:purpur_pillar":              rgb(171, 129, 171),
+
+	// Ocean.
+	"minecraft:prismarine":        rgb(99, 156, 151),
+	"minecraft:prismarine_bricks": rgb(99, 171, 158),
+	"minecraft:dark_prismarine":   rgb(51, 91, 75),
+	"minecraft:sea_lantern":       rgb(172, 199, 190),
+	"minecraft:sponge":            rgb(195, 192, 74),
+	"minecraft:wet_sponge":        rgb(171, 181, 70),
+	"minecraft:dried_kelp_block":  rgb(50, 58, 38),
+
+	// Miscellaneous.
+	"minecraft:hay_block":       rgb(166, 136, 38),
+	"minecraft:pumpkin":         rgb(198, 118, 24),
+	"minecraft:carved_pumpkin":  rgb(150, 84, 17),
+	"minecraft:jack_o_lantern":  rgb(214, 152, 52),
+	"minecraft:melon":           rgb(111, 145, 30),
+	"minecraft:tnt":             rgb(142, 62, 53),
+	"minecraft:furnace":         rgb(110, 109, 109),
+	"minecraft:slime_block":     rgba(111, 192, 91, 200),
+	"minecraft:honey_block":     rgba(251, 185, 52, 200),
+	"minecraft:honeycomb_block": rgb(229, 148, 29),
+	"minecraft:beacon":          rgb(117, 220, 215),
+	"minecraft:target":          rgb(226, 170, 157),
+	"minecraft:bone_block":      rgb(229, 225, 207),
+	"minecraft:sculk":           rgb(12, 29, 36),
+	"minecraft:lodestone":       rgb(147, 149, 152),
+	"minecraft:resin_block":     rgb(217, 99, 25),
+	"minecraft:resin_bricks":    rgb(206, 88, 24),
+}
@@ -0,0 +1,36 @@
+package base
+
+import (
+	"maps"
+	"strings"
+)
+
+// extraPrefix is the prefix of metadata keys holding NBT tags that a codec did
+// not understand, followed by the scope the tags were found in.
+const extraPrefix = "extra:"
+
+// SetExtra keeps NBT tags a codec did not understand under the scope passed,
+// such as "root" or "metadata", so that a writer of the same format can
+// restore them.
+func SetExtra(s Schematic, scope string, tags map[string]any) {
+	if len(tags) == 0 {
+		return
+	}
+	s.SetMetadata(extraPrefix+scope, tags)
+}
+
+// Extra returns the NBT tags kept by SetExtra for the scope passed if s was
+// read in the format passed. Tags are not carried over to other formats, as
+// their meaning is specific to the format they were found in.
+func Extra(s Schematic, formatID, scope string) map[string]any {
+	if s.Format() != formatID {
+		return nil
+	}
+	tags, _ := s.Metadata()[extraPrefix+scope].(map[string]any)
+	return maps.Clone(tags)
+}
+
+// IsExtraKey reports whether a metadata key holds NBT tags kept by SetExtra.
+func IsExtraKey(key string) bool {
+	return strings.HasPrefix(key, extraPrefix)
+}
@@ -0,0 +1,101 @@
+package base
+
+import (
+	"slices"
+	"time"
+)
+
+// Info is the format-neutral description of a schematic. Readers fill it from
+// the native fields of their format, writers map it back to them, so that it
+// survives conversion between formats.
+type Info struct {
+	Name        string
+	Author      string
+	Description string
+
+	Created  time.Time // Zero if unknown
+	Modified time.Time // Zero if unknown
+
+	Tags         []string
+	RequiredMods []string // Mod IDs, e.g. "create"
+
+	// Tool is the tool the schematic was created with, e.g. "WorldEdit".
+	Tool string
+}
+
+// Clone creates a deep copy of the Info.
+func (i Info) Clone() Info {
+	i.Tags = slices.Clone(i.Tags)
+	i.RequiredMods = slices.Clone(i.RequiredMods)
+	return i
+}
+
+// TimeFromMillis converts a Unix timestamp in milliseconds, as stored by most
+// formats, to a time.Time. A timestamp of 0 results in the zero time.
+func TimeFromMillis(ms int64) time.Time {
+	if ms == 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(ms)
+}
+
+// Millis converts a time.Time to a Unix timestamp in milliseconds. The zero
+// time results in 0.
+func Millis(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixMilli()
+}
+
+// StringList converts a decoded NBT list of strings to a []string, skipping
+// elements of other types.
+func StringList(v any) []string {
+	switch list := v.(type) {
+	case []string:
+		return slices.Clone(list)
+	case []any:
+		out := make([]string, 0, len(list))
+		for _, e := range list {
+			if s, ok := e.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	default:
+		return nil
+	}
+}
+
+// Float64List converts a decoded NBT list of doubles to a []float64. It
+// returns nil if an element is of another type.
+func Float64List(v any) []float64 {
+	return numberList[float64](v)
+}
+
+// Float32List converts a decoded NBT list of floats to a []float32. It
+// returns nil if an element is of another type.
+func Float32List(v any) []float32 {
+	return numberList[float32](v)
+}
+
+// numberList converts a decoded NBT list, decoded either as []T or as []any,
+// to a []T.
+func numberList[T float32 | float64](v any) []T {
+	switch list := v.(type) {
+	case []T:
+		return slices.Clone(list)
+	case []any:
+		out := make([]T, 0, len(list))
+		for _, e := range list {
+			f, ok := e.(T)
+			if !ok {
+				return nil
+			}
+			out = append(out, f)
+		}
+		return out
+	default:
+		return nil
+	}
+}
@@ -106,3 +106,29 @@ func blockStateKey(block *BlockState) string {
 	buf.WriteByte(']')
 	return buf.String()
 }
+
+// Sort orders the palette lexicographically by block state and rewrites the
+// palette indices passed to match the new order.
+func (p *Palette) Sort(indices []int) {
+	keys := make([]string, len(p.blocks))
+	order := make([]int, len(p.blocks))
+	for i := range p.blocks {
+		keys[i] = blockStateKey(&p.blocks[i])
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return keys[order[i]] < keys[order[j]]
+	})
+
+	remap := make([]int, len(order))
+	blocks := make([]BlockState, len(order))
+	for newIdx, oldIdx := range order {
+		remap[oldIdx] = newIdx
+		blocks[newIdx] = p.blocks[oldIdx]
+		p.index[keys[oldIdx]] = newIdx
+	}
+	p.blocks = blocks
+	for i, idx := range indices {
+		indices[i] = remap[idx]
+	}
+}
@@ -12,9 +12,14 @@ type SchematicImpl struct {
 	blockEntities map[int]*BlockEntity
 	biomes        map[int]string
 	entities      []*Entity
+	info          Info
 	metadata      map[string]any
 	formatID      string
 	dataVersion   int
+
+	// changed reports whether the offset, blocks, block entities, entities
+	// or biomes were modified since the last call to ResetChanged.
+	changed bool
 }
 
 // New creates a new schematic with the given dimensions and format ID.
@@ -46,6 +51,7 @@ func (s *SchematicImpl) Offset() (int, int, int) {
 
 func (s *SchematicImpl) SetOffset(x, y, z int) {
 	s.offsetX, s.offsetY, s.offsetZ = x, y, z
+	s.changed = true
 }
 
 func (s *SchematicImpl) Block(x, y, z int) *BlockState {
@@ -59,6 +65,7 @@ func (s *SchematicImpl) SetBlock(x, y, z int, block *BlockState) {
 	if x < 0 || x >= s.width || y < 0 || y >= s.height || z < 0 || z >= s.length {
 		return
 	}
+	s.changed = true
 	idx := s.index(x, y, z)
 	if block == nil {
 		delete(s.blocks, idx)
@@ -78,6 +85,7 @@ func (s *SchematicImpl) SetBlockEntity(x, y, z int, be *BlockEntity) {
 	if x < 0 || x >= s.width || y < 0 || y >= s.height || z < 0 || z >= s.length {
 		return
 	}
+	s.changed = true
 	idx := s.index(x, y, z)
 	if be == nil {
 		delete(s.blockEntities, idx)
@@ -95,12 +103,14 @@ func (s *SchematicImpl) Entities() []*Entity {
 
 func (s *SchematicImpl) AddEntity(entity *Entity) {
 	s.entities = append(s.entities, entity)
+	s.changed = true
 }
 
 func (s *SchematicImpl) RemoveEntity(entity *Entity) {
 	for i, e := range s.entities {
 		if e == entity {
 			s.entities = append(s.entities[:i], s.entities[i+1:]...)
+			s.changed = true
 			return
 		}
 	}
@@ -130,6 +140,7 @@ func (s *SchematicImpl) SetBiome(x, y, z int, biome string) {
 	} else {
 		idx = x + z*s.width
 	}
+	s.changed = true
 	if biome == "" {
 		delete(s.biomes, idx)
 	} else {
@@ -137,6 +148,14 @@ func (s *SchematicImpl) SetBiome(x, y, z int, biome string) {
 	}
 }
 
+func (s *SchematicImpl) Info() Info {
+	return s.info.Clone()
+}
+
+func (s *SchematicImpl) SetInfo(info Info) {
+	s.info = info.Clone()
+}
+
 func (s *SchematicImpl) Metadata() map[string]any {
 	m := make(map[string]any, len(s.metadata))
 	maps.Copy(m, s.metadata)
@@ -147,6 +166,27 @@ func (s *SchematicImpl) SetMetadata(key string, value any) {
 	s.metadata[key] = value
 }
 
+// Changed reports whether the content of the schematic was modified since it
+// was read. Changes to its info and metadata are not counted.
+func (s *SchematicImpl) Changed() bool {
+	return s.changed
+}
+
+// ResetChanged marks the schematic as unmodified. It is called once a
+// schematic has been read from a file.
+func (s *SchematicImpl) ResetChanged() {
+	s.changed = false
+}
+
+// Changed reports whether the content of s was modified since it was read.
+// Schematics that do not track changes are always considered modified.
+func Changed(s Schematic) bool {
+	if c, ok := s.(interface{ Changed() bool }); ok {
+		return c.Changed()
+	}
+	return true
+}
+
 func (s *SchematicImpl) Format() string {
 	return s.formatID
 }
@@ -0,0 +1,83 @@
+package base
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// SplitList removes the elements of the list named name from the root
+// compound of uncompressed big endian NBT data. It returns the data with the
+// list emptied, and the elements each encoded as an unnamed root tag so that
+// they can be decoded on their own. If the root compound holds no such list,
+// the data is returned unchanged.
+//
+// The NBT decoder does not leave the nesting level of a list of numbers once
+// it was read, and fails after reading 512 of them, so long lists of
+// compounds holding such lists, like the blocks of structure files, must be
+// decoded element by element.
+func SplitList(data []byte, name string) ([]byte, [][]byte, error) {
+	c := canonicalizer{data: data, order: binary.BigEndian}
+	t, err := c.byte()
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := c.string(); err != nil {
+		return nil, nil, err
+	}
+	if t != tagCompound {
+		return data, nil, nil
+	}
+
+	var (
+		out      bytes.Buffer
+		elements [][]byte
+		scratch  bytes.Buffer
+	)
+	out.Write(data[:c.off])
+	for {
+		start := c.off
+		t, err := c.byte()
+		if err != nil {
+			return nil, nil, err
+		}
+		if t == tagEnd {
+			out.WriteByte(tagEnd)
+			return out.Bytes(), elements, nil
+		}
+		tagName, err := c.string()
+		if err != nil {
+			return nil, nil, err
+		}
+		if t != tagList || tagName != name {
+			scratch.Reset()
+			if err := c.payload(&scratch, t, 1); err != nil {
+				return nil, nil, err
+			}
+			out.Write(data[start:c.off])
+			continue
+		}
+
+		elemType, err := c.byte()
+		if err != nil {
+			return nil, nil, err
+		}
+		scratch.Reset()
+		n, err := c.length(&scratch)
+		if err != nil {
+			return nil, nil, err
+		}
+		for range n {
+			elemStart := c.off
+			scratch.Reset()
+			if err := c.payload(&scratch, elemType, 2); err != nil {
+				return nil, nil, fmt.Errorf("list %s: %w", name, err)
+			}
+			element := make([]byte, 0, 3+c.off-elemStart)
+			element = append(element, elemType, 0, 0)
+			elements = append(elements, append(element, data[elemStart:c.off]...))
+		}
+		out.Write(data[start : start+3+len(tagName)])
+		out.Write([]byte{elemType, 0, 0, 0, 0})
+	}
+}
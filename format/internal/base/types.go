@@ -45,6 +45,19 @@ func (b *BlockState) String() string {
 	return b.Name + "[" + strings.Join(parts, ",") + "]"
 }
 
+// NBTProperties returns the properties of the block state as the string
+// tags palettes store them as, or nil if it has none.
+func (b *BlockState) NBTProperties() map[string]any {
+	if len(b.Properties) == 0 {
+		return nil
+	}
+	props := make(map[string]any, len(b.Properties))
+	for k, v := range b.Properties {
+		props[k] = fmt.Sprint(v)
+	}
+	return props
+}
+
 // ParseBlockState parses a block state string into a BlockState.
 func ParseBlockState(s string) *BlockState {
 	name, props, _ := strings.Cut(s, "[")
@@ -203,6 +216,12 @@ type Schematic interface {
 	// SetBiome sets the biome at the given position.
 	SetBiome(x, y, z int, biome string)
 
+	// Info returns the format-neutral description of the schematic.
+	Info() Info
+
+	// SetInfo sets the format-neutral description of the schematic.
+	SetInfo(info Info)
+
 	// Metadata returns format-specific metadata.
 	Metadata() map[string]any
 
@@ -28,6 +28,10 @@ func DecodeVarInt(data []byte) (int, int, error) {
 
 // DecodeVarIntArray decodes multiple VarInts from a byte slice.
 func DecodeVarIntArray(data []byte, count int) ([]int, error) {
+	if count < 0 || len(data) < count {
+		// Every VarInt takes up at least one byte.
+		return nil, fmt.Errorf("expected %d varints, got %d bytes", count, len(data))
+	}
 	values := make([]int, count)
 	offset := 0
 	for i := range count {
@@ -0,0 +1,291 @@
+package blueprint
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/oriumgames/nbt"
+	"github.com/oriumgames/schem/format/internal/base"
+)
+
+const (
+	// version is the blueprint version written.
+	version = 1
+	// substitution is the block Structurize leaves the world untouched at.
+	substitution = "structurize:blocksubstitution"
+	// tool is the tool blueprints are created with.
+	tool = "Structurize"
+	// architectsKey is the metadata key of the architects of a blueprint.
+	architectsKey = "Architects"
+)
+
+type paletteEntry struct {
+	Name       string         `nbt:"Name"`
+	Properties map[string]any `nbt:"Properties,omitempty"`
+}
+
+type blueprintNBT struct {
+	Version      uint8            `nbt:"version"`
+	SizeX        int16            `nbt:"size_x"`
+	SizeY        int16            `nbt:"size_y"`
+	SizeZ        int16            `nbt:"size_z"`
+	Palette      []paletteEntry   `nbt:"palette"`
+	Blocks       []int32          `nbt:"blocks,array"`
+	TileEntities []map[string]any `nbt:"tile_entities"`
+	Entities     []map[string]any `nbt:"entities"`
+	Name         string           `nbt:"name,omitempty"`
+	Architects   []string         `nbt:"architects"`
+	RequiredMods []string         `nbt:"required_mods"`
+	MCVersion    int32            `nbt:"mcversion,omitempty"`
+	Extra        map[string]any   `nbt:"*"`
+}
+
+// Read reads a Structurize blueprint, as used by MineColonies. Positions
+// holding structurize:blocksubstitution, which leaves the world untouched
+// when the blueprint is placed, have no block. The name and required mods of
+// the blueprint are read into the schematic info, and its architects into the
+// Author of the info and the Architects metadata.
+func Read(r io.Reader) (base.Schematic, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("gzip decompress: %w", err)
+	}
+	defer gz.Close()
+
+	raw, err := io.ReadAll(gz)
+	if err != nil {
+		return nil, fmt.Errorf("read gzip data: %w", err)
+	}
+	data, err := decode(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	width, height, length := int(data.SizeX), int(data.SizeY), int(data.SizeZ)
+	if width <= 0 || height <= 0 || length <= 0 {
+		return nil, fmt.Errorf("invalid dimensions: %dx%dx%d", width, height, length)
+	}
+	volume := width * height * length
+	if len(data.Blocks) != (volume+1)/2 {
+		return nil, fmt.Errorf("expected %d block entries, got %d", (volume+1)/2, len(data.Blocks))
+	}
+
+	s := base.New(width, height, length, "blueprint")
+	s.SetDataVersion(int(data.MCVersion))
+	s.SetInfo(base.Info{
+		Name:         data.Name,
+		Author:       strings.Join(data.Architects, ", "),
+		RequiredMods: data.RequiredMods,
+		Tool:         tool,
+	})
+	if len(data.Architects) > 0 {
+		s.SetMetadata(architectsKey, data.Architects)
+	}
+	base.SetExtra(s, "root", data.Extra)
+
+	states := make([]*base.BlockState, len(data.Palette))
+	for i, entry := range data.Palette {
+		if entry.Name != substitution {
+			states[i] = &base.BlockState{Name: entry.Name, Properties: entry.Properties}
+		}
+	}
+
+	// Blocks are palette indices stored as pairs of shorts, the first in the
+	// high half of each int, in Y, Z, X order.
+	for i := range volume {
+		idx := uint16(data.Blocks[i/2] >> 16)
+		if i%2 == 1 {
+			idx = uint16(data.Blocks[i/2])
+		}
+		if int(idx) >= len(states) {
+			return nil, fmt.Errorf("invalid palette index %d", idx)
+		}
+		if state := states[idx]; state != nil {
+			x, z, y := i%width, i/width%length, i/(width*length)
+			s.SetBlock(x, y, z, state.Clone())
+		}
+	}
+
+	for _, raw := range data.TileEntities {
+		x, okX := raw["x"].(int32)
+		y, okY := raw["y"].(int32)
+		z, okZ := raw["z"].(int32)
+		if !okX || !okY || !okZ {
+			return nil, fmt.Errorf("tile entity without position")
+		}
+		be := &base.BlockEntity{Data: make(map[string]any, len(raw))}
+		be.ID, _ = raw["id"].(string)
+		for k, v := range raw {
+			switch k {
+			case "id", "x", "y", "z":
+			default:
+				be.Data[k] = v
+			}
+		}
+		s.SetBlockEntity(int(x), int(y), int(z), be)
+	}
+
+	for _, raw := range data.Entities {
+		ent := &base.Entity{Data: make(map[string]any, len(raw))}
+		ent.ID, _ = raw["id"].(string)
+		if pos := base.Float64List(raw["Pos"]); len(pos) >= 3 {
+			ent.Pos = [3]float64{pos[0], pos[1], pos[2]}
+		}
+		if rot := base.Float32List(raw["Rotation"]); len(rot) >= 2 {
+			ent.Rotation = [2]float32{rot[0], rot[1]}
+		}
+		if motion := base.Float64List(raw["Motion"]); len(motion) >= 3 {
+			ent.Motion = [3]float64{motion[0], motion[1], motion[2]}
+		}
+		if uuid, ok := raw["UUID"].([4]int32); ok {
+			ent.UUID = &uuid
+		}
+		for k, v := range raw {
+			switch k {
+			case "id", "Pos", "Rotation", "Motion", "UUID":
+			default:
+				ent.Data[k] = v
+			}
+		}
+		s.AddEntity(ent)
+	}
+	return s, nil
+}
+
+// decode decodes an uncompressed blueprint, decoding its tile entities and
+// entities one by one as they may hold lists of numbers.
+func decode(raw []byte) (blueprintNBT, error) {
+	var data blueprintNBT
+	raw, tileEntities, err := base.SplitList(raw, "tile_entities")
+	if err != nil {
+		return data, fmt.Errorf("decode nbt: %w", err)
+	}
+	raw, entities, err := base.SplitList(raw, "entities")
+	if err != nil {
+		return data, fmt.Errorf("decode nbt: %w", err)
+	}
+	if err := decodeNBT(raw, &data); err != nil {
+		return data, err
+	}
+	data.TileEntities = make([]map[string]any, len(tileEntities))
+	for i, te := range tileEntities {
+		if err := decodeNBT(te, &data.TileEntities[i]); err != nil {
+			return data, err
+		}
+	}
+	data.Entities = make([]map[string]any, len(entities))
+	for i, entity := range entities {
+		if err := decodeNBT(entity, &data.Entities[i]); err != nil {
+			return data, err
+		}
+	}
+	return data, nil
+}
+
+// decodeNBT decodes uncompressed big endian NBT into v.
+func decodeNBT(data []byte, v any) error {
+	if err := nbt.NewDecoderWithEncoding(bytes.NewReader(data), nbt.BigEndian).Decode(v); err != nil {
+		return fmt.Errorf("decode nbt: %w", err)
+	}
+	return nil
+}
+
+// Write writes a schematic as a Structurize blueprint. Positions without a
+// block are written as structurize:blocksubstitution, so that placing the
+// blueprint keeps the blocks already there.
+func Write(w io.Writer, s base.Schematic) error {
+	width, height, length := s.Dimensions()
+	if max(width, height, length) > math.MaxInt16 {
+		return fmt.Errorf("dimensions %dx%dx%d exceed the maximum of %d", width, height, length, math.MaxInt16)
+	}
+
+	info := s.Info()
+	data := blueprintNBT{
+		Version:      version,
+		SizeX:        int16(width),
+		SizeY:        int16(height),
+		SizeZ:        int16(length),
+		Palette:      []paletteEntry{},
+		TileEntities: []map[string]any{},
+		Entities:     []map[string]any{},
+		Name:         info.Name,
+		Architects:   architects(s, info),
+		RequiredMods: slices.Clone(info.RequiredMods),
+		MCVersion:    int32(s.DataVersion()),
+		Extra:        base.Extra(s, "blueprint", "root"),
+	}
+	if data.RequiredMods == nil {
+		data.RequiredMods = []string{}
+	}
+
+	palette := base.NewPalette()
+	indices := make([]uint16, 0, width*height*length)
+	for y := range height {
+		for z := range length {
+			for x := range width {
+				state := s.Block(x, y, z)
+				if state == nil {
+					state = &base.BlockState{Name: substitution}
+				}
+				idx := palette.Add(*state)
+				if idx > math.MaxInt16 {
+					return fmt.Errorf("more than %d block states", math.MaxInt16+1)
+				}
+				if idx == len(data.Palette) {
+					entry := paletteEntry{Name: state.Name, Properties: state.NBTProperties()}
+					data.Palette = append(data.Palette, entry)
+				}
+				indices = append(indices, uint16(idx))
+
+				if be := s.BlockEntity(x, y, z); be != nil {
+					raw := make(map[string]any, len(be.Data)+4)
+					maps.Copy(raw, be.Data)
+					raw["id"] = be.ID
+					raw["x"], raw["y"], raw["z"] = int32(x), int32(y), int32(z)
+					data.TileEntities = append(data.TileEntities, raw)
+				}
+			}
+		}
+	}
+	data.Blocks = make([]int32, (len(indices)+1)/2)
+	for i, idx := range indices {
+		if i%2 == 0 {
+			data.Blocks[i/2] = int32(idx) << 16
+		} else {
+			data.Blocks[i/2] |= int32(idx)
+		}
+	}
+
+	for _, ent := range s.Entities() {
+		raw := make(map[string]any, len(ent.Data)+5)
+		maps.Copy(raw, ent.Data)
+		raw["id"] = ent.ID
+		raw["Pos"] = []float64{ent.Pos[0], ent.Pos[1], ent.Pos[2]}
+		raw["Rotation"] = []float32{ent.Rotation[0], ent.Rotation[1]}
+		raw["Motion"] = []float64{ent.Motion[0], ent.Motion[1], ent.Motion[2]}
+		if ent.UUID != nil {
+			raw["UUID"] = *ent.UUID
+		}
+		data.Entities = append(data.Entities, raw)
+	}
+	return base.WriteGzipNBT(w, data, 0)
+}
+
+// architects returns the architects of a blueprint: those read with it,
+// unless the author of the info was changed since, in which case the author
+// is the only architect.
+func architects(s base.Schematic, info base.Info) []string {
+	if names, ok := s.Metadata()[architectsKey].([]string); ok && strings.Join(names, ", ") == info.Author {
+		return slices.Clone(names)
+	}
+	if info.Author == "" {
+		return []string{}
+	}
+	return []string{info.Author}
+}
@@ -0,0 +1,526 @@
+package datapack
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"maps"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/oriumgames/schem/format/internal/base"
+	"github.com/oriumgames/schem/format/internal/structure"
+)
+
+const (
+	// maxPieceSize is the largest width, height or length of a structure
+	// file that structure blocks and jigsaw pieces handle.
+	maxPieceSize = 48
+	// maxDepth is the largest number of jigsaw connections between the
+	// start piece and any other piece.
+	maxDepth = 20
+	// maxDistance is the largest distance of a piece from the centre of the
+	// start piece.
+	maxDistance = 128
+	// defaultDataVersion is the data version of schematics that declare none.
+	defaultDataVersion = 4665
+)
+
+// packFormats maps the first data version of a Minecraft release to the
+// data pack format of that release, newest first.
+var packFormats = []struct{ dataVersion, packFormat int }{
+	{4665, 94}, // 1.21.11
+	{4554, 88}, // 1.21.9
+	{4438, 81}, // 1.21.7
+	{4435, 80}, // 1.21.6
+	{4325, 71}, // 1.21.5
+	{4189, 61}, // 1.21.4
+	{4080, 57}, // 1.21.2
+	{3953, 48}, // 1.21
+	{3837, 41}, // 1.20.5
+	{3698, 26}, // 1.20.3
+	{3578, 18}, // 1.20.2
+	{3463, 15}, // 1.20
+}
+
+// Options configures a data pack.
+type Options struct {
+	// Namespace is the namespace of every file of the data pack, such as
+	// "mypack". It is required.
+	Namespace string
+	// Description is the description of the data pack shown in game.
+	Description string
+	// PackFormat is the data pack format written to pack.mcmeta. If 0, it
+	// is derived from the data version of the newest schematic.
+	PackFormat int
+}
+
+// Structure is a schematic generated in the world as a jigsaw structure.
+type Structure struct {
+	// Name is the path of the structure within the namespace, such as
+	// "houses/cottage".
+	Name string
+	// Schematic holds the blocks of the structure. Positions without a block
+	// keep the terrain they are placed in.
+	Schematic base.Schematic
+	// Biomes is the biome or biome tag the structure generates in. If
+	// empty, "#minecraft:is_overworld" is used.
+	Biomes string
+	// Step is the generation step of the structure. If empty,
+	// "surface_structures" is used.
+	Step string
+	// TerrainAdaptation is how terrain is adapted around the structure,
+	// such as "beard_thin" or "none". If empty, "beard_thin" is used.
+	TerrainAdaptation string
+	// Spacing and Separation are the average and minimum distance in chunks
+	// between two structures. If 0, 32 and 8 are used.
+	Spacing, Separation int
+	// StartHeight is the height of the bottom of the structure relative to
+	// the surface of the world.
+	StartHeight int
+}
+
+// Write writes the data pack holding the structures passed into the
+// directory dir, creating it if needed.
+func Write(dir string, structures []Structure, opts Options) error {
+	files, err := build(structures, opts)
+	if err != nil {
+		return err
+	}
+	for _, name := range slices.Sorted(maps.Keys(files)) {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return fmt.Errorf("create directory: %w", err)
+		}
+		if err := os.WriteFile(path, files[name], 0o644); err != nil {
+			return fmt.Errorf("write %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// WriteZip writes the data pack holding the structures passed as a zip
+// archive.
+func WriteZip(w io.Writer, structures []Structure, opts Options) error {
+	files, err := build(structures, opts)
+	if err != nil {
+		return err
+	}
+	zw := zip.NewWriter(w)
+	for _, name := range slices.Sorted(maps.Keys(files)) {
+		f, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate})
+		if err != nil {
+			return fmt.Errorf("create %s: %w", name, err)
+		}
+		if _, err := f.Write(files[name]); err != nil {
+			return fmt.Errorf("write %s: %w", name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("close zip: %w", err)
+	}
+	return nil
+}
+
+type packMeta struct {
+	Pack struct {
+		Description string `json:"description"`
+		PackFormat  int    `json:"pack_format"`
+		MinFormat   int    `json:"min_format,omitempty"`
+		MaxFormat   int    `json:"max_format,omitempty"`
+	} `json:"pack"`
+}
+
+type poolElement struct {
+	ElementType string `json:"element_type"`
+	Location    string `json:"location"`
+	Projection  string `json:"projection"`
+	Processors  string `json:"processors"`
+}
+
+type poolEntry struct {
+	Weight  int         `json:"weight"`
+	Element poolElement `json:"element"`
+}
+
+type templatePool struct {
+	Fallback string      `json:"fallback"`
+	Elements []poolEntry `json:"elements"`
+}
+
+type worldgenStructure struct {
+	Type                    string         `json:"type"`
+	Biomes                  string         `json:"biomes"`
+	Step                    string         `json:"step"`
+	SpawnOverrides          struct{}       `json:"spawn_overrides"`
+	TerrainAdaptation       string         `json:"terrain_adaptation"`
+	StartPool               string         `json:"start_pool"`
+	Size                    int            `json:"size"`
+	StartHeight             map[string]int `json:"start_height"`
+	ProjectStartToHeightmap string         `json:"project_start_to_heightmap"`
+	MaxDistanceFromCenter   int            `json:"max_distance_from_center"`
+	UseExpansionHack        bool           `json:"use_expansion_hack"`
+}
+
+type setEntry struct {
+	Structure string `json:"structure"`
+	Weight    int    `json:"weight"`
+}
+
+type placement struct {
+	Type       string `json:"type"`
+	Spacing    int    `json:"spacing"`
+	Separation int    `json:"separation"`
+	Salt       int32  `json:"salt"`
+}
+
+type structureSet struct {
+	Structures []setEntry `json:"structures"`
+	Placement  placement  `json:"placement"`
+}
+
+// build returns the files of the data pack by their path in it.
+func build(structures []Structure, opts Options) (map[string][]byte, error) {
+	if !validPath(opts.Namespace, false) {
+		return nil, fmt.Errorf("invalid namespace %q", opts.Namespace)
+	}
+	if len(structures) == 0 {
+		return nil, fmt.Errorf("no structures")
+	}
+
+	files := make(map[string][]byte)
+	newest := 0
+	for _, st := range structures {
+		if st.Schematic == nil {
+			return nil, fmt.Errorf("structure %s: no schematic", st.Name)
+		}
+		newest = max(newest, dataVersion(st.Schematic))
+	}
+	// The structure directory was named structures before 1.21.
+	structureDir := "structure"
+	if newest < 3953 {
+		structureDir = "structures"
+	}
+
+	for _, st := range structures {
+		if !validPath(st.Name, true) {
+			return nil, fmt.Errorf("invalid structure name %q", st.Name)
+		}
+		if err := addStructure(files, structureDir, opts.Namespace, st); err != nil {
+			return nil, fmt.Errorf("structure %s: %w", st.Name, err)
+		}
+	}
+
+	var meta packMeta
+	meta.Pack.Description = opts.Description
+	meta.Pack.PackFormat = opts.PackFormat
+	if meta.Pack.PackFormat == 0 {
+		meta.Pack.PackFormat = packFormats[len(packFormats)-1].packFormat
+		for _, f := range packFormats {
+			if newest >= f.dataVersion {
+				meta.Pack.PackFormat = f.packFormat
+				break
+			}
+		}
+	}
+	// Since 1.21.9 the supported formats are declared as a range.
+	if meta.Pack.PackFormat >= 82 {
+		meta.Pack.MinFormat, meta.Pack.MaxFormat = meta.Pack.PackFormat, meta.Pack.PackFormat
+	}
+	return files, addJSON(files, "pack.mcmeta", meta)
+}
+
+// piece is a part of a structure no larger than maxPieceSize on any axis.
+type piece struct {
+	// index is the position of the piece in the grid of pieces, and min and
+	// size its position and size in the schematic.
+	index, min, size [3]int
+	// location is the resource location of its structure file and template
+	// pool.
+	location string
+	// jigsaws holds the jigsaw blocks of the piece by their position in it.
+	jigsaws map[[3]int]jigsaw
+}
+
+// jigsaw is a jigsaw block connecting two pieces.
+type jigsaw struct {
+	orientation, name, target, pool string
+}
+
+// direction is a direction between two neighbouring pieces.
+type direction struct {
+	offset [3]int
+	// orientation is the orientation of the jigsaw block facing the
+	// direction, and back the one of the jigsaw block facing the other way.
+	orientation, back string
+}
+
+var directions = []direction{
+	{[3]int{1, 0, 0}, "east_up", "west_up"},
+	{[3]int{-1, 0, 0}, "west_up", "east_up"},
+	{[3]int{0, 0, 1}, "south_up", "north_up"},
+	{[3]int{0, 0, -1}, "north_up", "south_up"},
+	{[3]int{0, 1, 0}, "up_north", "down_north"},
+	{[3]int{0, -1, 0}, "down_north", "up_north"},
+}
+
+// addStructure adds the files of a structure: its pieces, their template
+// pools, the structure and its structure set.
+func addStructure(files map[string][]byte, structureDir, ns string, st Structure) error {
+	width, height, length := st.Schematic.Dimensions()
+	if width <= 0 || height <= 0 || length <= 0 {
+		return fmt.Errorf("invalid dimensions: %dx%dx%d", width, height, length)
+	}
+	bounds := [3][]int{split(width), split(height), split(length)}
+	counts := [3]int{len(bounds[0]) - 1, len(bounds[1]) - 1, len(bounds[2]) - 1}
+
+	grid := make(map[[3]int]*piece)
+	for i := range counts[0] {
+		for j := range counts[1] {
+			for k := range counts[2] {
+				p := &piece{index: [3]int{i, j, k}, jigsaws: make(map[[3]int]jigsaw)}
+				for axis, idx := range p.index {
+					p.min[axis] = bounds[axis][idx]
+					p.size[axis] = bounds[axis][idx+1] - bounds[axis][idx]
+				}
+				p.location = ns + ":" + st.Name
+				if counts[0]*counts[1]*counts[2] > 1 {
+					p.location += fmt.Sprintf("/%d_%d_%d", i, j, k)
+				}
+				grid[p.index] = p
+			}
+		}
+	}
+
+	// The pieces are connected breadth first from the start piece, at the
+	// bottom of the structure in the middle of it, so that every piece is as
+	// close to the start piece as possible.
+	start := grid[[3]int{counts[0] / 2, 0, counts[2] / 2}]
+	depth := map[*piece]int{start: 0}
+	queue := []*piece{start}
+	maxReached := 0
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		for _, d := range directions {
+			n := grid[[3]int{p.index[0] + d.offset[0], p.index[1] + d.offset[1], p.index[2] + d.offset[2]}]
+			if _, seen := depth[n]; n == nil || seen {
+				continue
+			}
+			depth[n] = depth[p] + 1
+			maxReached = max(maxReached, depth[n])
+			queue = append(queue, n)
+
+			// The jigsaw block of the parent places the pool of the child,
+			// attached to the jigsaw block of the child named after it.
+			p.jigsaws[facePos(p.size, d.offset)] = jigsaw{orientation: d.orientation, name: "minecraft:empty", target: n.location, pool: n.location}
+			back := [3]int{-d.offset[0], -d.offset[1], -d.offset[2]}
+			n.jigsaws[facePos(n.size, back)] = jigsaw{orientation: d.back, name: n.location, target: "minecraft:empty", pool: "minecraft:empty"}
+		}
+	}
+	if maxReached > maxDepth {
+		return fmt.Errorf("%dx%dx%d needs %d jigsaw connections, more than the maximum of %d", width, height, length, maxReached, maxDepth)
+	}
+	distance := height
+	for _, axis := range []int{0, 2} {
+		centre := start.min[axis] + start.size[axis]/2
+		distance = max(distance, centre+1, []int{width, height, length}[axis]-centre)
+	}
+	if distance > maxDistance {
+		return fmt.Errorf("%dx%dx%d is too large: pieces reach %d blocks from the start, more than the maximum of %d", width, height, length, distance, maxDistance)
+	}
+
+	keys := slices.SortedFunc(maps.Keys(grid), func(a, b [3]int) int {
+		return slices.Compare(a[:], b[:])
+	})
+	for _, key := range keys {
+		p := grid[key]
+		var buf bytes.Buffer
+		if err := structure.Write(&buf, pieceOf(st.Schematic, p)); err != nil {
+			return fmt.Errorf("write piece %v: %w", p.index, err)
+		}
+		files[resourcePath(p.location, structureDir, ".nbt")] = buf.Bytes()
+
+		pool := templatePool{Fallback: "minecraft:empty", Elements: []poolEntry{{Weight: 1, Element: poolElement{
+			ElementType: "minecraft:single_pool_element",
+			Location:    p.location,
+			Projection:  "rigid",
+			Processors:  "minecraft:empty",
+		}}}}
+		if err := addJSON(files, resourcePath(p.location, "worldgen/template_pool", ".json"), pool); err != nil {
+			return err
+		}
+	}
+
+	location := ns + ":" + st.Name
+	ws := worldgenStructure{
+		Type:                    "minecraft:jigsaw",
+		Biomes:                  or(st.Biomes, "#minecraft:is_overworld"),
+		Step:                    or(st.Step, "surface_structures"),
+		TerrainAdaptation:       or(st.TerrainAdaptation, "beard_thin"),
+		StartPool:               start.location,
+		Size:                    max(maxReached, 1),
+		StartHeight:             map[string]int{"absolute": st.StartHeight},
+		ProjectStartToHeightmap: "WORLD_SURFACE_WG",
+		MaxDistanceFromCenter:   max(distance, 1),
+	}
+	if err := addJSON(files, resourcePath(location, "worldgen/structure", ".json"), ws); err != nil {
+		return err
+	}
+
+	spacing, separation := st.Spacing, st.Separation
+	if spacing == 0 {
+		spacing = 32
+	}
+	if separation == 0 {
+		separation = min(8, spacing-1)
+	}
+	if separation >= spacing {
+		return fmt.Errorf("separation %d must be smaller than spacing %d", separation, spacing)
+	}
+	// The salt is derived from the name so that structures of different
+	// packs do not generate at the same places.
+	h := fnv.New32a()
+	h.Write([]byte(location))
+	set := structureSet{
+		Structures: []setEntry{{Structure: location, Weight: 1}},
+		Placement:  placement{Type: "minecraft:random_spread", Spacing: spacing, Separation: separation, Salt: int32(h.Sum32() & 0x7FFFFFFF)},
+	}
+	return addJSON(files, resourcePath(location, "worldgen/structure_set", ".json"), set)
+}
+
+// pieceOf returns the part of a schematic covered by a piece, with its
+// jigsaw blocks. A jigsaw block turns into the block it replaced once the
+// structure is generated, but the block entity of that block is lost.
+func pieceOf(s base.Schematic, p *piece) base.Schematic {
+	out := base.New(p.size[0], p.size[1], p.size[2], "")
+	out.SetDataVersion(dataVersion(s))
+	for y := range p.size[1] {
+		for z := range p.size[2] {
+			for x := range p.size[0] {
+				sx, sy, sz := p.min[0]+x, p.min[1]+y, p.min[2]+z
+				state := s.Block(sx, sy, sz)
+				if j, ok := p.jigsaws[[3]int{x, y, z}]; ok {
+					final := "minecraft:air"
+					if state != nil {
+						final = state.String()
+					}
+					out.SetBlock(x, y, z, &base.BlockState{Name: "minecraft:jigsaw", Properties: map[string]any{"orientation": j.orientation}})
+					out.SetBlockEntity(x, y, z, &base.BlockEntity{ID: "minecraft:jigsaw", X: x, Y: y, Z: z, Data: map[string]any{
+						"name":               j.name,
+						"target":             j.target,
+						"pool":               j.pool,
+						"final_state":        final,
+						"joint":              "aligned",
+						"placement_priority": int32(0),
+						"selection_priority": int32(0),
+					}})
+					continue
+				}
+				if state == nil {
+					continue
+				}
+				out.SetBlock(x, y, z, state)
+				if be := s.BlockEntity(sx, sy, sz); be != nil {
+					out.SetBlockEntity(x, y, z, &base.BlockEntity{ID: be.ID, X: x, Y: y, Z: z, Data: be.Data})
+				}
+			}
+		}
+	}
+	for _, ent := range s.Entities() {
+		inside := true
+		for axis := range 3 {
+			v := int(math.Floor(ent.Pos[axis]))
+			inside = inside && v >= p.min[axis] && v < p.min[axis]+p.size[axis]
+		}
+		if !inside {
+			continue
+		}
+		moved := *ent
+		moved.Pos = [3]float64{ent.Pos[0] - float64(p.min[0]), ent.Pos[1] - float64(p.min[1]), ent.Pos[2] - float64(p.min[2])}
+		out.AddEntity(&moved)
+	}
+	return out
+}
+
+// split returns the boundaries of the pieces a length is split into, evenly
+// so that no piece is much thinner than the others.
+func split(length int) []int {
+	n := (length + maxPieceSize - 1) / maxPieceSize
+	bounds := make([]int, n+1)
+	for i := range bounds {
+		bounds[i] = i * length / n
+	}
+	return bounds
+}
+
+// facePos returns the position of the jigsaw block at the centre of the face
+// of a piece of the size passed facing the offset passed.
+func facePos(size, offset [3]int) [3]int {
+	pos := [3]int{size[0] / 2, size[1] / 2, size[2] / 2}
+	for axis, d := range offset {
+		switch d {
+		case 1:
+			pos[axis] = size[axis] - 1
+		case -1:
+			pos[axis] = 0
+		}
+	}
+	return pos
+}
+
+// resourcePath returns the path in the data pack of the file of a resource
+// location in the directory passed.
+func resourcePath(location, dir, ext string) string {
+	ns, path, _ := strings.Cut(location, ":")
+	return "data/" + ns + "/" + dir + "/" + path + ext
+}
+
+// addJSON adds a file holding v as indented JSON.
+func addJSON(files map[string][]byte, name string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode %s: %w", name, err)
+	}
+	files[name] = append(data, '\n')
+	return nil
+}
+
+// dataVersion returns the data version of a schematic, or the default one
+// if it declares none.
+func dataVersion(s base.Schematic) int {
+	if v := s.DataVersion(); v > 0 {
+		return v
+	}
+	return defaultDataVersion
+}
+
+// validPath reports whether a namespace, or a path if slashes are allowed,
+// only holds characters valid in resource locations.
+func validPath(s string, slashes bool) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '_', r == '-', r == '.':
+		case r == '/' && slashes:
+		default:
+			return false
+		}
+	}
+	return !strings.HasPrefix(s, "/") && !strings.HasSuffix(s, "/")
+}
+
+func or(s, fallback string) string {
+	if s == "" {
+		return fallback
+	}
+	return s
+}
@@ -0,0 +1,171 @@
+package gadgets
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/oriumgames/nbt"
+	"github.com/oriumgames/schem/format/internal/base"
+)
+
+const (
+	// maxSize is the largest size of a template along any axis, as positions
+	// are stored as signed byte offsets from the start position.
+	maxSize = 128
+	// tool is the tool templates are created with.
+	tool = "Building Gadgets"
+)
+
+type posNBT struct {
+	X int32 `nbt:"X"`
+	Y int32 `nbt:"Y"`
+	Z int32 `nbt:"Z"`
+}
+
+type stateNBT struct {
+	Name       string         `nbt:"Name"`
+	Properties map[string]any `nbt:"Properties,omitempty"`
+}
+
+type mapEntryNBT struct {
+	Slot  int16    `nbt:"mapSlot"`
+	State stateNBT `nbt:"mapState"`
+}
+
+type templateNBT struct {
+	StateIntArray []int32        `nbt:"stateIntArray,array"`
+	PosIntArray   []int32        `nbt:"posIntArray,array"`
+	MapIntState   []mapEntryNBT  `nbt:"mapIntState"`
+	StartPos      posNBT         `nbt:"startPos"`
+	EndPos        posNBT         `nbt:"endPos"`
+	Name          string         `nbt:"name,omitempty"`
+	Extra         map[string]any `nbt:"*"`
+}
+
+// Read reads a legacy template of the Copy-Paste Gadget of Building Gadgets,
+// either as the base64 string copied from the template manager or as the
+// compressed NBT it encodes. Blocks are read as stored, with their modded IDs.
+// The JSON templates of later versions, which wrap differently structured
+// NBT, are not supported.
+func Read(r io.Reader) (base.Schematic, error) {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("read data: %w", err)
+	}
+	if trimmed := bytes.TrimSpace(raw); len(trimmed) > 0 && trimmed[0] == '{' {
+		return nil, fmt.Errorf("JSON templates are not supported, only legacy base64 templates")
+	}
+	if !gzipped(raw) {
+		if raw, err = base64.StdEncoding.DecodeString(string(bytes.TrimSpace(raw))); err != nil {
+			return nil, fmt.Errorf("decode base64: %w", err)
+		}
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("gzip decompress: %w", err)
+	}
+	defer gz.Close()
+
+	var data templateNBT
+	if err := nbt.NewDecoderWithEncoding(gz, nbt.BigEndian).Decode(&data); err != nil {
+		return nil, fmt.Errorf("decode nbt: %w", err)
+	}
+	if len(data.StateIntArray) != len(data.PosIntArray) {
+		return nil, fmt.Errorf("%d states for %d positions", len(data.StateIntArray), len(data.PosIntArray))
+	}
+
+	states := make(map[int32]*base.BlockState, len(data.MapIntState))
+	for _, entry := range data.MapIntState {
+		states[int32(entry.Slot)] = &base.BlockState{Name: entry.State.Name, Properties: entry.State.Properties}
+	}
+
+	// Positions are signed byte offsets from the start position, packed as
+	// X, Y and Z in the three low bytes of an int. The template spans from
+	// the start to the end position.
+	start := [3]int{int(data.StartPos.X), int(data.StartPos.Y), int(data.StartPos.Z)}
+	end := [3]int{int(data.EndPos.X), int(data.EndPos.Y), int(data.EndPos.Z)}
+	positions := make([][3]int, len(data.PosIntArray))
+	minPos, maxPos := start, start
+	for axis := range 3 {
+		minPos[axis] = min(minPos[axis], end[axis])
+		maxPos[axis] = max(maxPos[axis], end[axis])
+	}
+	for i, p := range data.PosIntArray {
+		positions[i] = [3]int{start[0] + int(int8(p>>16)), start[1] + int(int8(p>>8)), start[2] + int(int8(p))}
+		for axis, v := range positions[i] {
+			minPos[axis] = min(minPos[axis], v)
+			maxPos[axis] = max(maxPos[axis], v)
+		}
+	}
+
+	s := base.New(maxPos[0]-minPos[0]+1, maxPos[1]-minPos[1]+1, maxPos[2]-minPos[2]+1, "building_gadgets")
+	s.SetInfo(base.Info{Name: data.Name, Tool: tool})
+	base.SetExtra(s, "root", data.Extra)
+	for i, pos := range positions {
+		state, ok := states[data.StateIntArray[i]]
+		if !ok {
+			return nil, fmt.Errorf("invalid map slot %d", data.StateIntArray[i])
+		}
+		s.SetBlock(pos[0]-minPos[0], pos[1]-minPos[1], pos[2]-minPos[2], state.Clone())
+	}
+	return s, nil
+}
+
+// gzipped reports whether data starts with the gzip magic.
+func gzipped(data []byte) bool {
+	return len(data) >= 2 && data[0] == 0x1F && data[1] == 0x8B
+}
+
+// Write writes a schematic as a template of the Copy-Paste Gadget of Building
+// Gadgets, as the base64 string pasted into the template manager. Templates
+// hold no block entities or entities, and at most 128 blocks along any axis.
+func Write(w io.Writer, s base.Schematic) error {
+	width, height, length := s.Dimensions()
+	if max(width, height, length) > maxSize {
+		return fmt.Errorf("dimensions %dx%dx%d exceed the maximum of %d", width, height, length, maxSize)
+	}
+
+	data := templateNBT{
+		StateIntArray: []int32{},
+		PosIntArray:   []int32{},
+		MapIntState:   []mapEntryNBT{},
+		EndPos:        posNBT{X: int32(max(width-1, 0)), Y: int32(max(height-1, 0)), Z: int32(max(length-1, 0))},
+		Name:          s.Info().Name,
+		Extra:         base.Extra(s, "building_gadgets", "root"),
+	}
+	palette := base.NewPalette()
+	for y := range height {
+		for z := range length {
+			for x := range width {
+				state := s.Block(x, y, z)
+				if state == nil {
+					continue
+				}
+				slot := palette.Add(*state)
+				if slot > math.MaxInt16 {
+					return fmt.Errorf("more than %d block states", math.MaxInt16+1)
+				}
+				if slot == len(data.MapIntState) {
+					entry := mapEntryNBT{Slot: int16(slot), State: stateNBT{Name: state.Name, Properties: state.NBTProperties()}}
+					data.MapIntState = append(data.MapIntState, entry)
+				}
+				data.StateIntArray = append(data.StateIntArray, int32(slot))
+				data.PosIntArray = append(data.PosIntArray, int32(x<<16|y<<8|z))
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := base.WriteGzipNBT(&buf, data, 0); err != nil {
+		return err
+	}
+	enc := base64.NewEncoder(base64.StdEncoding, w)
+	if _, err := enc.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	return enc.Close()
+}
@@ -0,0 +1,55 @@
+package litematica
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/oriumgames/schem/format/internal/base"
+	"github.com/oriumgames/schem/format/internal/mcedit"
+)
+
+const (
+	// flatteningDataVersion is the first data version with flattened block
+	// states (17w47a). Palettes of older schematics hold 1.12 block states.
+	flatteningDataVersion = 1451
+	// flattenedDataVersion is the data version of schematics whose palettes
+	// were upgraded by mcedit.Flatten (1.13).
+	flattenedDataVersion = 1519
+)
+
+// ReadV4 reads a Litematica version 4 file. Version 4 files share the layout
+// of version 6 files and may hold pre-1.13 block states, which are upgraded to
+// flattened block states.
+func ReadV4(r io.Reader) (base.Schematic, error) {
+	return readLegacy(r, 4)
+}
+
+// ReadV5 reads a Litematica version 5 file. Version 5 files share the layout
+// of version 6 files and may hold pre-1.13 block states, which are upgraded to
+// flattened block states.
+func ReadV5(r io.Reader) (base.Schematic, error) {
+	return readLegacy(r, 5)
+}
+
+// readLegacy reads a Litematica file of a version before 6.
+func readLegacy(r io.Reader, version int32) (base.Schematic, error) {
+	data, err := read(r, version)
+	if err != nil {
+		return nil, err
+	}
+
+	// Upgrade pre-flattening palettes. Files written before 1.13 either hold
+	// a 1.12 data version or none at all.
+	if data.MinecraftDataVersion < flatteningDataVersion {
+		for name, region := range data.Regions {
+			for i, entry := range region.BlockStatePalette {
+				flattened := mcedit.Flatten(&base.BlockState{Name: entry.Name, Properties: entry.Properties})
+				region.BlockStatePalette[i].Name = flattened.Name
+				region.BlockStatePalette[i].Properties = flattened.Properties
+			}
+			data.Regions[name] = region
+		}
+		data.MinecraftDataVersion = flattenedDataVersion
+	}
+	return fromNBT(data, fmt.Sprintf("litematica_v%d", version))
+}
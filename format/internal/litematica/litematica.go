@@ -0,0 +1,539 @@
+package litematica
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"math/bits"
+	"time"
+
+	"github.com/oriumgames/nbt"
+	"github.com/oriumgames/schem/format/internal/base"
+)
+
+// defaultRegionName is the name of the region written for schematics that
+// were not read from a litematic.
+const defaultRegionName = "Region"
+
+// schematicNBT represents the NBT structure of a litematic. Versions 4 to 7
+// share this layout.
+type schematicNBT struct {
+	Version              int32 `nbt:"Version"`
+	SubVersion           int32 `nbt:"SubVersion,omitempty"`
+	MinecraftDataVersion int32 `nbt:"MinecraftDataVersion"`
+
+	Metadata struct {
+		Name          string         `nbt:"Name"`
+		Author        string         `nbt:"Author"`
+		Description   string         `nbt:"Description"`
+		TimeCreated   int64          `nbt:"TimeCreated"`
+		TimeModified  int64          `nbt:"TimeModified"`
+		RegionCount   int32          `nbt:"RegionCount"`
+		TotalBlocks   int32          `nbt:"TotalBlocks"`
+		TotalVolume   int32          `nbt:"TotalVolume"`
+		EnclosingSize vec3           `nbt:"EnclosingSize"`
+		Extra         map[string]any `nbt:"*"`
+	} `nbt:"Metadata"`
+
+	Regions map[string]regionNBT `nbt:"Regions"`
+
+	Extra map[string]any `nbt:"*"`
+}
+
+type regionNBT struct {
+	Position vec3 `nbt:"Position"`
+	Size     vec3 `nbt:"Size"`
+
+	BlockStatePalette []struct {
+		Name       string         `nbt:"Name"`
+		Properties map[string]any `nbt:"Properties,omitempty"`
+	} `nbt:"BlockStatePalette"`
+
+	BlockStates       []int64          `nbt:"BlockStates,array"`
+	TileEntities      []map[string]any `nbt:"TileEntities"`
+	Entities          []map[string]any `nbt:"Entities"`
+	PendingBlockTicks []map[string]any `nbt:"PendingBlockTicks,omitempty"`
+	PendingFluidTicks []map[string]any `nbt:"PendingFluidTicks,omitempty"`
+	Extra             map[string]any   `nbt:"*"`
+}
+
+// vec3 is a compound holding x, y and z coordinates.
+type vec3 struct {
+	X int32 `nbt:"x"`
+	Y int32 `nbt:"y"`
+	Z int32 `nbt:"z"`
+}
+
+// read decodes a litematic and checks that it has the version passed.
+func read(r io.Reader, version int32) (schematicNBT, error) {
+	var data schematicNBT
+
+	// Decompress gzip
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return data, fmt.Errorf("gzip decompress: %w", err)
+	}
+	defer gz.Close()
+
+	// Decode NBT
+	if err := nbt.NewDecoderWithEncoding(gz, nbt.BigEndian).Decode(&data); err != nil {
+		return data, fmt.Errorf("decode nbt: %w", err)
+	}
+
+	if data.Version != version {
+		return data, fmt.Errorf("expected version %d, got %d", version, data.Version)
+	}
+	return data, nil
+}
+
+// fromNBT converts a decoded litematic to a schematic of the format passed.
+func fromNBT(data schematicNBT, formatID string) (base.Schematic, error) {
+	// Find the first region
+	regionName := firstRegion(data.Regions)
+	if regionName == "" {
+		return nil, fmt.Errorf("no regions found in litematica file")
+	}
+	regionData := data.Regions[regionName]
+
+	// Build palette first
+	palette := make([]*base.BlockState, len(regionData.BlockStatePalette))
+	for i, p := range regionData.BlockStatePalette {
+		palette[i] = &base.BlockState{
+			Name:       p.Name,
+			Properties: p.Properties,
+		}
+	}
+
+	// Determine region dimensions (absolute)
+	regWidth := int(math.Abs(float64(regionData.Size.X)))
+	regHeight := int(math.Abs(float64(regionData.Size.Y)))
+	regLength := int(math.Abs(float64(regionData.Size.Z)))
+
+	// Calculate region origin
+	originX := getOrigin(regionData.Position.X, regionData.Size.X)
+	originY := getOrigin(regionData.Position.Y, regionData.Size.Y)
+	originZ := getOrigin(regionData.Position.Z, regionData.Size.Z)
+
+	// Decode blocks using TIGHT packing
+	bitsPerEntry := max(bits.Len(uint(len(palette)-1)), 2)
+	blockCount := regWidth * regHeight * regLength
+	blockIndices := base.UnpackLongArrayTight(regionData.BlockStates, bitsPerEntry, blockCount)
+
+	// Calculate actual bounding box from non-air blocks
+	type blockPlacement struct {
+		X, Y, Z int
+		Block   *base.BlockState
+	}
+	placements := make([]blockPlacement, 0)
+	minX, minY, minZ := math.MaxInt32, math.MaxInt32, math.MaxInt32
+	maxX, maxY, maxZ := math.MinInt32, math.MinInt32, math.MinInt32
+	hasContent := false
+
+	for y := range regHeight {
+		for z := range regLength {
+			for x := range regWidth {
+				idx := x + z*regWidth + y*regWidth*regLength
+				if idx >= len(blockIndices) {
+					continue
+				}
+				paletteIdx := blockIndices[idx]
+				if paletteIdx < 0 || paletteIdx >= len(palette) {
+					continue
+				}
+				block := palette[paletteIdx]
+				if block == nil || isAirBlock(block.Name) {
+					continue
+				}
+
+				placements = append(placements, blockPlacement{X: x, Y: y, Z: z, Block: block.Clone()})
+				if x < minX {
+					minX = x
+				}
+				if y < minY {
+					minY = y
+				}
+				if z < minZ {
+					minZ = z
+				}
+				if x > maxX {
+					maxX = x
+				}
+				if y > maxY {
+					maxY = y
+				}
+				if z > maxZ {
+					maxZ = z
+				}
+				hasContent = true
+			}
+		}
+	}
+
+	// Calculate dimensions from bounding box
+	var width, height, length int
+	if hasContent {
+		width = maxX - minX + 1
+		height = maxY - minY + 1
+		length = maxZ - minZ + 1
+	} else {
+		width = regWidth
+		height = regHeight
+		length = regLength
+		minX, minY, minZ = 0, 0, 0
+	}
+
+	// Create schematic with calculated dimensions
+	s := base.New(width, height, length, formatID)
+	s.SetDataVersion(int(data.MinecraftDataVersion))
+	s.SetInfo(base.Info{
+		Name:        data.Metadata.Name,
+		Author:      data.Metadata.Author,
+		Description: data.Metadata.Description,
+		Created:     base.TimeFromMillis(data.Metadata.TimeCreated),
+		Modified:    base.TimeFromMillis(data.Metadata.TimeModified),
+		Tool:        "Litematica",
+	})
+	s.SetMetadata("RegionName", regionName)
+	s.SetMetadata("RegionPosition", [3]int{int(regionData.Position.X), int(regionData.Position.Y), int(regionData.Position.Z)})
+	s.SetMetadata("RegionSize", [3]int{int(regionData.Size.X), int(regionData.Size.Y), int(regionData.Size.Z)})
+	if data.SubVersion != 0 {
+		s.SetMetadata("SubVersion", data.SubVersion)
+	}
+	if ticks := shiftTicks(regionData.PendingBlockTicks, -minX, -minY, -minZ); ticks != nil {
+		s.SetMetadata("PendingBlockTicks", ticks)
+	}
+	if ticks := shiftTicks(regionData.PendingFluidTicks, -minX, -minY, -minZ); ticks != nil {
+		s.SetMetadata("PendingFluidTicks", ticks)
+	}
+	base.SetExtra(s, "root", data.Extra)
+	base.SetExtra(s, "metadata", data.Metadata.Extra)
+	base.SetExtra(s, "region", regionData.Extra)
+
+	// Set offset (region origin + bounding box crop offset)
+	s.SetOffset(
+		int(originX)+minX,
+		int(originY)+minY,
+		int(originZ)+minZ,
+	)
+
+	// Set blocks using calculated offset
+	for _, p := range placements {
+		x := p.X - minX
+		y := p.Y - minY
+		z := p.Z - minZ
+		s.SetBlock(x, y, z, p.Block)
+	}
+
+	// Set tile entities (adjust for offset)
+	for _, teData := range regionData.TileEntities {
+		be := &base.BlockEntity{
+			Data: make(map[string]any),
+		}
+
+		var x, y, z int
+		if xVal, ok := teData["x"].(int32); ok {
+			x = int(xVal) - minX
+		}
+		if yVal, ok := teData["y"].(int32); ok {
+			y = int(yVal) - minY
+		}
+		if zVal, ok := teData["z"].(int32); ok {
+			z = int(zVal) - minZ
+		}
+
+		// Extract ID
+		if id, ok := teData["id"].(string); ok {
+			be.ID = id
+		}
+
+		// Copy remaining data
+		for k, v := range teData {
+			if k != "x" && k != "y" && k != "z" && k != "id" {
+				be.Data[k] = v
+			}
+		}
+
+		// Only add if within bounds
+		if x >= 0 && x < width && y >= 0 && y < height && z >= 0 && z < length {
+			s.SetBlockEntity(x, y, z, be)
+		}
+	}
+
+	// Set entities
+	for _, entData := range regionData.Entities {
+		ent := &base.Entity{
+			Data: make(map[string]any),
+		}
+
+		// Extract position and adjust for bounding box
+		if pos, ok := entData["Pos"].([]any); ok && len(pos) >= 3 {
+			ent.Pos[0] = pos[0].(float64) - float64(minX)
+			ent.Pos[1] = pos[1].(float64) - float64(minY)
+			ent.Pos[2] = pos[2].(float64) - float64(minZ)
+		}
+
+		// Extract rotation
+		if rot, ok := entData["Rotation"].([]any); ok && len(rot) >= 2 {
+			ent.Rotation[0] = rot[0].(float32)
+			ent.Rotation[1] = rot[1].(float32)
+		}
+
+		// Extract motion
+		if motion, ok := entData["Motion"].([]any); ok && len(motion) >= 3 {
+			ent.Motion[0] = motion[0].(float64)
+			ent.Motion[1] = motion[1].(float64)
+			ent.Motion[2] = motion[2].(float64)
+		}
+
+		// Extract ID
+		if id, ok := entData["id"].(string); ok {
+			ent.ID = id
+		}
+
+		// Copy remaining data
+		for k, v := range entData {
+			if k != "Pos" && k != "Rotation" && k != "Motion" && k != "id" {
+				ent.Data[k] = v
+			}
+		}
+
+		s.AddEntity(ent)
+	}
+
+	return s, nil
+}
+
+// write writes a schematic as a litematic of the version passed.
+func write(w io.Writer, schem base.Schematic, version int32) error {
+	formatID := fmt.Sprintf("litematica_v%d", version)
+	width, height, length := schem.Dimensions()
+	offsetX, offsetY, offsetZ := schem.Offset()
+	meta := schem.Metadata()
+
+	// Lay out the region. A schematic read from a litematic keeps the
+	// position and size of its region, which may be larger than the blocks
+	// read from it, as long as its blocks still fit inside.
+	position := vec3{X: int32(offsetX), Y: int32(offsetY), Z: int32(offsetZ)}
+	size := vec3{X: int32(width), Y: int32(height), Z: int32(length)}
+	var shiftX, shiftY, shiftZ int
+	if pos, sz, ok := regionBox(meta); ok {
+		originX := int(getOrigin(int32(pos[0]), int32(sz[0])))
+		originY := int(getOrigin(int32(pos[1]), int32(sz[1])))
+		originZ := int(getOrigin(int32(pos[2]), int32(sz[2])))
+		if within(offsetX, width, originX, sz[0]) && within(offsetY, height, originY, sz[1]) && within(offsetZ, length, originZ, sz[2]) {
+			position = vec3{X: int32(pos[0]), Y: int32(pos[1]), Z: int32(pos[2])}
+			size = vec3{X: int32(sz[0]), Y: int32(sz[1]), Z: int32(sz[2])}
+			shiftX, shiftY, shiftZ = offsetX-originX, offsetY-originY, offsetZ-originZ
+		}
+	}
+	regWidth := int(math.Abs(float64(size.X)))
+	regHeight := int(math.Abs(float64(size.Y)))
+	regLength := int(math.Abs(float64(size.Z)))
+
+	// Build palette, counting non-air blocks as they are added
+	palette := base.NewPaletteWithAir()
+	blockIndices := make([]int, regWidth*regHeight*regLength)
+	totalBlocks := 0
+
+	for y := range height {
+		for z := range length {
+			for x := range width {
+				block := schem.Block(x, y, z)
+				if block == nil {
+					continue
+				}
+				idx := (x + shiftX) + (z+shiftZ)*regWidth + (y+shiftY)*regWidth*regLength
+				blockIndices[idx] = palette.Add(*block)
+				if !isAirBlock(block.Name) {
+					totalBlocks++
+				}
+			}
+		}
+	}
+
+	// Pack blocks using TIGHT packing
+	bitsPerEntry := max(bits.Len(uint(palette.Size()-1)), 2)
+	packedBlocks := base.PackLongArrayTight(blockIndices, bitsPerEntry)
+
+	// Build region
+	region := regionNBT{
+		Position:          position,
+		Size:              size,
+		BlockStates:       packedBlocks,
+		PendingBlockTicks: shiftTicks(ticksOf(meta["PendingBlockTicks"]), shiftX, shiftY, shiftZ),
+		PendingFluidTicks: shiftTicks(ticksOf(meta["PendingFluidTicks"]), shiftX, shiftY, shiftZ),
+		Extra:             base.Extra(schem, formatID, "region"),
+	}
+
+	// Encode palette
+	region.BlockStatePalette = make([]struct {
+		Name       string         `nbt:"Name"`
+		Properties map[string]any `nbt:"Properties,omitempty"`
+	}, palette.Size())
+
+	for i, block := range palette.Blocks() {
+		region.BlockStatePalette[i].Name = block.Name
+		region.BlockStatePalette[i].Properties = block.NBTProperties()
+	}
+
+	// Encode tile entities
+	for y := range height {
+		for z := range length {
+			for x := range width {
+				be := schem.BlockEntity(x, y, z)
+				if be == nil {
+					continue
+				}
+
+				teData := make(map[string]any)
+				teData["x"] = int32(x + shiftX)
+				teData["y"] = int32(y + shiftY)
+				teData["z"] = int32(z + shiftZ)
+				teData["id"] = be.ID
+				maps.Copy(teData, be.Data)
+				region.TileEntities = append(region.TileEntities, teData)
+			}
+		}
+	}
+
+	// Encode entities
+	for _, ent := range schem.Entities() {
+		entData := make(map[string]any)
+		entData["Pos"] = []float64{ent.Pos[0] + float64(shiftX), ent.Pos[1] + float64(shiftY), ent.Pos[2] + float64(shiftZ)}
+		entData["Rotation"] = []float32{ent.Rotation[0], ent.Rotation[1]}
+		entData["Motion"] = []float64{ent.Motion[0], ent.Motion[1], ent.Motion[2]}
+		entData["id"] = ent.ID
+		maps.Copy(entData, ent.Data)
+		region.Entities = append(region.Entities, entData)
+	}
+
+	regionName, _ := meta["RegionName"].(string)
+	if regionName == "" {
+		regionName = defaultRegionName
+	}
+
+	// Build main structure
+	data := schematicNBT{
+		Version:              version,
+		MinecraftDataVersion: int32(schem.DataVersion()),
+		Regions:              map[string]regionNBT{regionName: region},
+		Extra:                base.Extra(schem, formatID, "root"),
+	}
+	data.Metadata.Extra = base.Extra(schem, formatID, "metadata")
+	if subVersion, ok := meta["SubVersion"].(int32); ok && schem.Format() == formatID {
+		data.SubVersion = subVersion
+	}
+
+	// Keep the creation time and stamp the modification time when the
+	// content changed since the schematic was read from a file, which is
+	// when it has a modification time. Schematics built in memory are
+	// written with the times of their info, so that their output is stable.
+	info := schem.Info()
+	modified := info.Modified
+	if base.Changed(schem) && !modified.IsZero() {
+		modified = time.Now()
+	}
+	created := info.Created
+	if created.IsZero() {
+		created = modified
+	}
+	if modified.IsZero() {
+		modified = created
+	}
+
+	data.Metadata.Name = info.Name
+	data.Metadata.Author = info.Author
+	data.Metadata.Description = info.Description
+	data.Metadata.TimeCreated = base.Millis(created)
+	data.Metadata.TimeModified = base.Millis(modified)
+
+	data.Metadata.RegionCount = 1
+	data.Metadata.TotalBlocks = int32(totalBlocks)
+	data.Metadata.TotalVolume = int32(regWidth * regHeight * regLength)
+	data.Metadata.EnclosingSize = vec3{X: int32(regWidth), Y: int32(regHeight), Z: int32(regLength)}
+
+	// Compress and write
+	return base.WriteGzipNBT(w, data, 0)
+}
+
+// regionBox returns the position and size of the region a schematic was
+// read from, as stored in its metadata.
+func regionBox(meta map[string]any) (pos, size [3]int, ok bool) {
+	pos, ok = meta["RegionPosition"].([3]int)
+	if !ok {
+		return pos, size, false
+	}
+	size, ok = meta["RegionSize"].([3]int)
+	return pos, size, ok
+}
+
+// within reports whether the span of n blocks starting at start lies inside
+// the region span of the size passed starting at origin.
+func within(start, n, origin, size int) bool {
+	if size < 0 {
+		size = -size
+	}
+	return start >= origin && start+n <= origin+size
+}
+
+// ticksOf returns the scheduled ticks stored in a metadata value.
+func ticksOf(v any) []map[string]any {
+	ticks, _ := v.([]map[string]any)
+	return ticks
+}
+
+// shiftTicks returns copies of scheduled ticks with their x, y and z
+// coordinates moved by the amounts passed. It returns nil if there are no
+// ticks.
+func shiftTicks(ticks []map[string]any, dx, dy, dz int) []map[string]any {
+	if len(ticks) == 0 {
+		return nil
+	}
+	shifted := make([]map[string]any, len(ticks))
+	for i, tick := range ticks {
+		t := maps.Clone(tick)
+		if x, ok := t["x"].(int32); ok {
+			t["x"] = x + int32(dx)
+		}
+		if y, ok := t["y"].(int32); ok {
+			t["y"] = y + int32(dy)
+		}
+		if z, ok := t["z"].(int32); ok {
+			t["z"] = z + int32(dz)
+		}
+		shifted[i] = t
+	}
+	return shifted
+}
+
+func getOrigin(pos, size int32) int32 {
+	if size >= 0 {
+		return pos
+	}
+	return pos + size + 1
+}
+
+// firstRegion returns the name of the region read from a file, which is the
+// first in lexicographic order. It returns an empty string if there are no
+// regions.
+func firstRegion[R any](regions map[string]R) string {
+	var first string
+	for name := range regions {
+		if first == "" || name < first {
+			first = name
+		}
+	}
+	return first
+}
+
+// isAirBlock checks if a block name is an air variant.
+func isAirBlock(name string) bool {
+	switch name {
+	case "", "minecraft:air", "minecraft:void_air", "minecraft:cave_air":
+		return true
+	default:
+		return false
+	}
+}
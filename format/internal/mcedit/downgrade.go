@@ -0,0 +1,232 @@
+package mcedit
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/oriumgames/schem/format/internal/base"
+)
+
+// maxBlockID is the highest block ID a schematic can hold, using the 4 bits
+// of AddBlocks on top of the 8 bits of Blocks.
+const maxBlockID = 0xFFF
+
+// legacyID is a legacy block ID and data value.
+type legacyID struct {
+	ID   int
+	Data byte
+}
+
+// legacyCandidate is a flattened block state along with its legacy ID.
+type legacyCandidate struct {
+	legacyID
+	Properties map[string]any
+	// essential holds the properties the data value of the candidate selects:
+	// those for which another data value of the block differs from it in
+	// that property alone. Other properties, such as the shape of stairs,
+	// were computed from the neighbours of legacy blocks.
+	essential []string
+}
+
+var (
+	// reverseLegacyBlocks maps normalized block state strings to the lowest
+	// legacy ID and data value that reads as that state.
+	reverseLegacyBlocks map[string]legacyID
+	// legacyByName holds the legacy IDs of every state of a block, ordered by
+	// ID and data value.
+	legacyByName map[string][]legacyCandidate
+)
+
+func init() {
+	candidates := make([]legacyCandidate, 0, len(legacyBlocks))
+	states := make([]string, 0, len(legacyBlocks))
+	for k, v := range legacyBlocks {
+		idStr, dataStr, ok := strings.Cut(k, ":")
+		if !ok {
+			continue
+		}
+		id, err1 := strconv.Atoi(idStr)
+		data, err2 := strconv.Atoi(dataStr)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		candidates = append(candidates, legacyCandidate{legacyID: legacyID{ID: id, Data: byte(data)}})
+		states = append(states, v)
+	}
+
+	order := make([]int, len(candidates))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		a, b := candidates[order[i]], candidates[order[j]]
+		if a.ID != b.ID {
+			return a.ID < b.ID
+		}
+		return a.Data < b.Data
+	})
+
+	reverseLegacyBlocks = make(map[string]legacyID, len(candidates))
+	legacyByName = make(map[string][]legacyCandidate)
+	for _, i := range order {
+		// Normalize the block state string for consistent key matching
+		state := base.ParseBlockState(states[i])
+		key := state.String()
+		if _, ok := reverseLegacyBlocks[key]; !ok {
+			reverseLegacyBlocks[key] = candidates[i].legacyID
+		}
+		c := candidates[i]
+		c.Properties = state.Properties
+		legacyByName[state.Name] = append(legacyByName[state.Name], c)
+	}
+	for _, states := range legacyByName {
+		for i := range states {
+			states[i].essential = essentialProperties(states[i], states)
+		}
+	}
+}
+
+// essentialProperties returns the properties of a candidate for which
+// another candidate of the same block differs in that property alone.
+func essentialProperties(c legacyCandidate, candidates []legacyCandidate) []string {
+	var essential []string
+	for k, v := range c.Properties {
+		for _, other := range candidates {
+			if fmt.Sprint(other.Properties[k]) != fmt.Sprint(v) && differsOnlyIn(c.Properties, other.Properties, k) {
+				essential = append(essential, k)
+				break
+			}
+		}
+	}
+	return essential
+}
+
+// differsOnlyIn reports whether two sets of properties hold the same values
+// for every property but key.
+func differsOnlyIn(a, b map[string]any, key string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if w, ok := b[k]; k != key && (!ok || fmt.Sprint(v) != fmt.Sprint(w)) {
+			return false
+		}
+	}
+	return true
+}
+
+// lookupLegacy returns the legacy ID and data value of a block state. States
+// without an exact match fall back to a state of the same block with the same
+// essential properties, sharing the most other property values, so that
+// properties legacy blocks did not store, such as waterlogged or the shape of
+// stairs, are ignored. States whose essential properties match no legacy
+// state, such as a facing a block could not have, have no legacy ID.
+func lookupLegacy(state *base.BlockState) (legacyID, bool) {
+	if id, ok := reverseLegacyBlocks[state.String()]; ok {
+		return id, true
+	}
+	best, bestScore := -1, -1
+	candidates := legacyByName[state.Name]
+	for i, c := range candidates {
+		if !matchesEssential(state, c) {
+			continue
+		}
+		score := 0
+		for k, v := range c.Properties {
+			if value, ok := state.Properties[k]; ok && fmt.Sprint(value) == fmt.Sprint(v) {
+				score++
+			}
+		}
+		if score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+	if best < 0 {
+		return legacyID{}, false
+	}
+	return candidates[best].legacyID, true
+}
+
+// matchesEssential reports whether a block state holds the values of the
+// essential properties of a candidate. Properties the state does not set
+// match any value.
+func matchesEssential(state *base.BlockState, c legacyCandidate) bool {
+	for _, k := range c.essential {
+		if value, ok := state.Properties[k]; ok && fmt.Sprint(value) != fmt.Sprint(c.Properties[k]) {
+			return false
+		}
+	}
+	return true
+}
+
+// downgrader looks up the legacy IDs of the block states of a schematic. Mod
+// blocks are given IDs above the vanilla ones in the order they are looked
+// up, keeping those of the mapping the schematic was read with, until the
+// IDs run out.
+type downgrader struct {
+	mapping map[string]int
+	nextID  int
+}
+
+// newDowngrader returns a downgrader for a schematic.
+func newDowngrader(s base.Schematic) *downgrader {
+	d := &downgrader{mapping: make(map[string]int), nextID: 256}
+	if names, ok := s.Metadata()["SchematicaMapping"].(map[string]int); ok {
+		maps.Copy(d.mapping, names)
+	}
+	for _, id := range d.mapping {
+		d.nextID = max(d.nextID, id+1)
+	}
+	return d
+}
+
+// lookup returns the legacy ID and data value of a block state, giving mod
+// blocks without one the next free ID. It returns false if the state has no
+// legacy equivalent or no ID is left for the mod block.
+func (d *downgrader) lookup(state *base.BlockState) (legacyID, bool) {
+	if !isModBlock(state.Name) {
+		return lookupLegacy(state)
+	}
+	var legacy legacyID
+	id, ok := d.mapping[state.Name]
+	if !ok {
+		if d.nextID > maxBlockID {
+			return legacy, false
+		}
+		id = d.nextID
+		d.mapping[state.Name] = id
+		d.nextID++
+	}
+	legacy.ID = id
+	if v, isInt := state.Properties["data"].(int32); isInt {
+		legacy.Data = byte(v) & 0xF
+	}
+	return legacy, true
+}
+
+// UnmappedStates returns the block states of a schematic that have no legacy
+// equivalent, or are mod blocks left without an ID once the IDs above the
+// vanilla ones run out, and are written as air, sorted.
+func UnmappedStates(s base.Schematic) []string {
+	width, height, length := s.Dimensions()
+	d := newDowngrader(s)
+	unmapped := make(map[string]struct{})
+	for y := range height {
+		for z := range length {
+			for x := range width {
+				state := s.Block(x, y, z)
+				if state == nil {
+					continue
+				}
+				if _, ok := d.lookup(state); !ok {
+					unmapped[state.String()] = struct{}{}
+				}
+			}
+		}
+	}
+	return slices.Sorted(maps.Keys(unmapped))
+}
@@ -0,0 +1,374 @@
+package mcedit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/oriumgames/schem/format/internal/base"
+)
+
+// legacyProp is a property of a 1.12 block that is stored in its data value,
+// as the index of its value in values shifted left by shift. Empty values
+// are data values not used by the property.
+type legacyProp struct {
+	name   string
+	values []string
+	shift  uint
+}
+
+// legacyBlock is a 1.12 block: its ID and the properties stored in its data
+// value. Properties not stored in the data value, such as the shape of
+// stairs, are computed from the neighbours of the block.
+type legacyBlock struct {
+	id    int
+	props []legacyProp
+	// upper holds the properties stored for the upper half of doors, if the
+	// block is a door.
+	upper []legacyProp
+}
+
+// Value lists shared by many 1.12 blocks.
+var (
+	boolValues   = []string{"false", "true"}
+	facings      = []string{"down", "up", "north", "south", "west", "east"}
+	horizontals  = []string{"south", "west", "north", "east"}
+	wallFacings  = []string{"", "", "north", "south", "west", "east"}
+	torchFacings = []string{"", "east", "west", "south", "north", "up"}
+	woodTypes    = []string{"oak", "spruce", "birch", "jungle", "acacia", "dark_oak"}
+	dyeColors    = []string{
+		"white", "orange", "magenta", "light_blue", "yellow", "lime", "pink", "gray",
+		"silver", "cyan", "purple", "blue", "brown", "green", "red", "black",
+	}
+	slabVariants = []string{"stone", "sand", "wood_old", "cobblestone", "brick", "stone_brick", "nether_brick", "quartz"}
+	railShapes   = []string{"north_south", "east_west", "ascending_east", "ascending_west", "ascending_north", "ascending_south"}
+)
+
+// numbers returns the values of an integer property ranging from first to
+// last.
+func numbers(first, last int) []string {
+	values := make([]string, 0, last-first+1)
+	for i := first; i <= last; i++ {
+		values = append(values, strconv.Itoa(i))
+	}
+	return values
+}
+
+// Property layouts shared by many 1.12 blocks.
+var (
+	colorProps    = []legacyProp{{"color", dyeColors, 0}}
+	facingProps   = []legacyProp{{"facing", facings, 0}}
+	wallProps     = []legacyProp{{"facing", wallFacings, 0}}
+	rotationProps = []legacyProp{{"rotation", numbers(0, 15), 0}}
+	levelProps    = []legacyProp{{"level", numbers(0, 15), 0}}
+	powerProps    = []legacyProp{{"power", numbers(0, 15), 0}}
+	axisProps     = []legacyProp{{"axis", []string{"y", "x", "z"}, 2}}
+	stairsProps   = []legacyProp{{"facing", []string{"east", "west", "south", "north"}, 0}, {"half", []string{"bottom", "top"}, 2}}
+	gateProps     = []legacyProp{{"facing", horizontals, 0}, {"open", boolValues, 2}, {"powered", boolValues, 3}}
+	trapdoorProps = []legacyProp{{"facing", []string{"north", "south", "west", "east"}, 0}, {"open", boolValues, 2}, {"half", []string{"bottom", "top"}, 3}}
+	buttonProps   = []legacyProp{{"facing", []string{"down", "east", "west", "south", "north", "up"}, 0}, {"powered", boolValues, 3}}
+	pistonProps   = []legacyProp{{"facing", facings, 0}, {"extended", boolValues, 3}}
+	commandProps  = []legacyProp{{"facing", facings, 0}, {"conditional", boolValues, 3}}
+	railProps     = []legacyProp{{"shape", railShapes, 0}, {"powered", boolValues, 3}}
+	repeaterProps = []legacyProp{{"facing", horizontals, 0}, {"delay", numbers(1, 4), 2}}
+	compareProps  = []legacyProp{{"facing", horizontals, 0}, {"mode", []string{"compare", "subtract"}, 2}, {"powered", boolValues, 3}}
+	plateProps    = []legacyProp{{"powered", boolValues, 0}}
+	leavesProps   = func(variants ...string) []legacyProp {
+		return []legacyProp{{"variant", variants, 0}, {"decayable", []string{"true", "false"}, 2}, {"check_decay", boolValues, 3}}
+	}
+	logProps = func(variants ...string) []legacyProp {
+		return []legacyProp{{"variant", variants, 0}, {"axis", []string{"y", "x", "z", "none"}, 2}}
+	}
+	mushroomProps = []legacyProp{{"variant", []string{
+		"all_inside", "north_west", "north", "north_east", "west", "center", "east", "south_west",
+		"south", "south_east", "stem", "", "", "", "all_outside", "all_stem",
+	}, 0}}
+	doorProps = []legacyProp{{"facing", []string{"east", "south", "west", "north"}, 0}, {"open", boolValues, 2}, {"half", []string{"lower", "upper"}, 3}}
+	upperDoor = []legacyProp{{"hinge", []string{"left", "right"}, 0}, {"powered", boolValues, 1}, {"half", []string{"lower", "upper"}, 3}}
+)
+
+// legacyIDs maps the names of the blocks of 1.12 to their ID and the layout
+// of their data value.
+var legacyIDs = map[string]legacyBlock{
+	"minecraft:air":                           {id: 0},
+	"minecraft:stone":                         {1, []legacyProp{{"variant", []string{"stone", "granite", "smooth_granite", "diorite", "smooth_diorite", "andesite", "smooth_andesite"}, 0}}, nil},
+	"minecraft:grass":                         {id: 2},
+	"minecraft:dirt":                          {3, []legacyProp{{"variant", []string{"dirt", "coarse_dirt", "podzol"}, 0}}, nil},
+	"minecraft:cobblestone":                   {id: 4},
+	"minecraft:planks":                        {5, []legacyProp{{"variant", woodTypes, 0}}, nil},
+	"minecraft:sapling":                       {6, []legacyProp{{"type", woodTypes, 0}, {"stage", numbers(0, 1), 3}}, nil},
+	"minecraft:bedrock":                       {id: 7},
+	"minecraft:flowing_water":                 {8, levelProps, nil},
+	"minecraft:water":                         {9, levelProps, nil},
+	"minecraft:flowing_lava":                  {10, levelProps, nil},
+	"minecraft:lava":                          {11, levelProps, nil},
+	"minecraft:sand":                          {12, []legacyProp{{"variant", []string{"sand", "red_sand"}, 0}}, nil},
+	"minecraft:gravel":                        {id: 13},
+	"minecraft:gold_ore":                      {id: 14},
+	"minecraft:iron_ore":                      {id: 15},
+	"minecraft:coal_ore":                      {id: 16},
+	"minecraft:log":                           {17, logProps("oak", "spruce", "birch", "jungle"), nil},
+	"minecraft:leaves":                        {18, leavesProps("oak", "spruce", "birch", "jungle"), nil},
+	"minecraft:sponge":                        {19, []legacyProp{{"wet", boolValues, 0}}, nil},
+	"minecraft:glass":                         {id: 20},
+	"minecraft:lapis_ore":                     {id: 21},
+	"minecraft:lapis_block":                   {id: 22},
+	"minecraft:dispenser":                     {23, []legacyProp{{"facing", facings, 0}, {"triggered", boolValues, 3}}, nil},
+	"minecraft:sandstone":                     {24, []legacyProp{{"type", []string{"sandstone", "chiseled_sandstone", "smooth_sandstone"}, 0}}, nil},
+	"minecraft:noteblock":                     {id: 25},
+	"minecraft:bed":                           {26, []legacyProp{{"facing", horizontals, 0}, {"occupied", boolValues, 2}, {"part", []string{"foot", "head"}, 3}}, nil},
+	"minecraft:golden_rail":                   {27, railProps, nil},
+	"minecraft:detector_rail":                 {28, railProps, nil},
+	"minecraft:sticky_piston":                 {29, pistonProps, nil},
+	"minecraft:web":                           {id: 30},
+	"minecraft:tallgrass":                     {31, []legacyProp{{"type", []string{"dead_bush", "tall_grass", "fern"}, 0}}, nil},
+	"minecraft:deadbush":                      {id: 32},
+	"minecraft:piston":                        {33, pistonProps, nil},
+	"minecraft:piston_head":                   {34, []legacyProp{{"facing", facings, 0}, {"type", []string{"normal", "sticky"}, 3}}, nil},
+	"minecraft:wool":                          {35, colorProps, nil},
+	"minecraft:piston_extension":              {36, []legacyProp{{"facing", facings, 0}, {"type", []string{"normal", "sticky"}, 3}}, nil},
+	"minecraft:yellow_flower":                 {id: 37},
+	"minecraft:red_flower":                    {38, []legacyProp{{"type", []string{"poppy", "blue_orchid", "allium", "houstonia", "red_tulip", "orange_tulip", "white_tulip", "pink_tulip", "oxeye_daisy"}, 0}}, nil},
+	"minecraft:brown_mushroom":                {id: 39},
+	"minecraft:red_mushroom":                  {id: 40},
+	"minecraft:gold_block":                    {id: 41},
+	"minecraft:iron_block":                    {id: 42},
+	"minecraft:double_stone_slab":             {43, []legacyProp{{"variant", slabVariants, 0}, {"seamless", boolValues, 3}}, nil},
+	"minecraft:stone_slab":                    {44, []legacyProp{{"variant", slabVariants, 0}, {"half", []string{"bottom", "top"}, 3}}, nil},
+	"minecraft:brick_block":                   {id: 45},
+	"minecraft:tnt":                           {46, []legacyProp{{"explode", boolValues, 0}}, nil},
+	"minecraft:bookshelf":                     {id: 47},
+	"minecraft:mossy_cobblestone":             {id: 48},
+	"minecraft:obsidian":                      {id: 49},
+	"minecraft:torch":                         {50, []legacyProp{{"facing", torchFacings, 0}}, nil},
+	"minecraft:fire":                          {51, []legacyProp{{"age", numbers(0, 15), 0}}, nil},
+	"minecraft:mob_spawner":                   {id: 52},
+	"minecraft:oak_stairs":                    {53, stairsProps, nil},
+	"minecraft:chest":                         {54, wallProps, nil},
+	"minecraft:redstone_wire":                 {55, powerProps, nil},
+	"minecraft:diamond_ore":                   {id: 56},
+	"minecraft:diamond_block":                 {id: 57},
+	"minecraft:crafting_table":                {id: 58},
+	"minecraft:wheat":                         {59, []legacyProp{{"age", numbers(0, 7), 0}}, nil},
+	"minecraft:farmland":                      {60, []legacyProp{{"moisture", numbers(0, 7), 0}}, nil},
+	"minecraft:furnace":                       {61, wallProps, nil},
+	"minecraft:lit_furnace":                   {62, wallProps, nil},
+	"minecraft:standing_sign":                 {63, rotationProps, nil},
+	"minecraft:wooden_door":                   {64, doorProps, upperDoor},
+	"minecraft:ladder":                        {65, wallProps, nil},
+	"minecraft:rail":                          {66, []legacyProp{{"shape", append(railShapes[:6:6], "south_east", "south_west", "north_west", "north_east"), 0}}, nil},
+	"minecraft:stone_stairs":                  {67, stairsProps, nil},
+	"minecraft:wall_sign":                     {68, wallProps, nil},
+	"minecraft:lever":                         {69, []legacyProp{{"facing", []string{"down_x", "east", "west", "south", "north", "up_z", "up_x", "down_z"}, 0}, {"powered", boolValues, 3}}, nil},
+	"minecraft:stone_pressure_plate":          {70, plateProps, nil},
+	"minecraft:iron_door":                     {71, doorProps, upperDoor},
+	"minecraft:wooden_pressure_plate":         {72, plateProps, nil},
+	"minecraft:redstone_ore":                  {id: 73},
+	"minecraft:lit_redstone_ore":              {id: 74},
+	"minecraft:unlit_redstone_torch":          {75, []legacyProp{{"facing", torchFacings, 0}}, nil},
+	"minecraft:redstone_torch":                {76, []legacyProp{{"facing", torchFacings, 0}}, nil},
+	"minecraft:stone_button":                  {77, buttonProps, nil},
+	"minecraft:snow_layer":                    {78, []legacyProp{{"layers", numbers(1, 8), 0}}, nil},
+	"minecraft:ice":                           {id: 79},
+	"minecraft:snow":                          {id: 80},
+	"minecraft:cactus":                        {81, []legacyProp{{"age", numbers(0, 15), 0}}, nil},
+	"minecraft:clay":                          {id: 82},
+	"minecraft:reeds":                         {83, []legacyProp{{"age", numbers(0, 15), 0}}, nil},
+	"minecraft:jukebox":                       {84, []legacyProp{{"has_record", boolValues, 0}}, nil},
+	"minecraft:fence":                         {id: 85},
+	"minecraft:pumpkin":                       {86, []legacyProp{{"facing", horizontals, 0}}, nil},
+	"minecraft:netherrack":                    {id: 87},
+	"minecraft:soul_sand":                     {id: 88},
+	"minecraft:glowstone":                     {id: 89},
+	"minecraft:portal":                        {90, []legacyProp{{"axis", []string{"", "x", "z"}, 0}}, nil},
+	"minecraft:lit_pumpkin":                   {91, []legacyProp{{"facing", horizontals, 0}}, nil},
+	"minecraft:cake":                          {92, []legacyProp{{"bites", numbers(0, 6), 0}}, nil},
+	"minecraft:unpowered_repeater":            {93, repeaterProps, nil},
+	"minecraft:powered_repeater":              {94, repeaterProps, nil},
+	"minecraft:stained_glass":                 {95, colorProps, nil},
+	"minecraft:trapdoor":                      {96, trapdoorProps, nil},
+	"minecraft:monster_egg":                   {97, []legacyProp{{"variant", []string{"stone", "cobblestone", "stone_brick", "mossy_brick", "cracked_brick", "chiseled_brick"}, 0}}, nil},
+	"minecraft:stonebrick":                    {98, []legacyProp{{"variant", []string{"stonebrick", "mossy_stonebrick", "cracked_stonebrick", "chiseled_stonebrick"}, 0}}, nil},
+	"minecraft:brown_mushroom_block":          {99, mushroomProps, nil},
+	"minecraft:red_mushroom_block":            {100, mushroomProps, nil},
+	"minecraft:iron_bars":                     {id: 101},
+	"minecraft:glass_pane":                    {id: 102},
+	"minecraft:melon_block":                   {id: 103},
+	"minecraft:pumpkin_stem":                  {104, []legacyProp{{"age", numbers(0, 7), 0}}, nil},
+	"minecraft:melon_stem":                    {105, []legacyProp{{"age", numbers(0, 7), 0}}, nil},
+	"minecraft:vine":                          {106, []legacyProp{{"south", boolValues, 0}, {"west", boolValues, 1}, {"north", boolValues, 2}, {"east", boolValues, 3}}, nil},
+	"minecraft:fence_gate":                    {107, gateProps, nil},
+	"minecraft:brick_stairs":                  {108, stairsProps, nil},
+	"minecraft:stone_brick_stairs":            {109, stairsProps, nil},
+	"minecraft:mycelium":                      {id: 110},
+	"minecraft:waterlily":                     {id: 111},
+	"minecraft:nether_brick":                  {id: 112},
+	"minecraft:nether_brick_fence":            {id: 113},
+	"minecraft:nether_brick_stairs":           {114, stairsProps, nil},
+	"minecraft:nether_wart":                   {115, []legacyProp{{"age", numbers(0, 3), 0}}, nil},
+	"minecraft:enchanting_table":              {id: 116},
+	"minecraft:brewing_stand":                 {117, []legacyProp{{"has_bottle_0", boolValues, 0}, {"has_bottle_1", boolValues, 1}, {"has_bottle_2", boolValues, 2}}, nil},
+	"minecraft:cauldron":                      {118, []legacyProp{{"level", numbers(0, 3), 0}}, nil},
+	"minecraft:end_portal":                    {id: 119},
+	"minecraft:end_portal_frame":              {120, []legacyProp{{"facing", horizontals, 0}, {"eye", boolValues, 2}}, nil},
+	"minecraft:end_stone":                     {id: 121},
+	"minecraft:dragon_egg":                    {id: 122},
+	"minecraft:redstone_lamp":                 {id: 123},
+	"minecraft:lit_redstone_lamp":             {id: 124},
+	"minecraft:double_wooden_slab":            {125, []legacyProp{{"variant", woodTypes, 0}}, nil},
+	"minecraft:wooden_slab":                   {126, []legacyProp{{"variant", woodTypes, 0}, {"half", []string{"bottom", "top"}, 3}}, nil},
+	"minecraft:cocoa":                         {127, []legacyProp{{"facing", horizontals, 0}, {"age", numbers(0, 2), 2}}, nil},
+	"minecraft:sandstone_stairs":              {128, stairsProps, nil},
+	"minecraft:emerald_ore":                   {id: 129},
+	"minecraft:ender_chest":                   {130, wallProps, nil},
+	"minecraft:tripwire_hook":                 {131, []legacyProp{{"facing", horizontals, 0}, {"attached", boolValues, 2}, {"powered", boolValues, 3}}, nil},
+	"minecraft:tripwire":                      {132, []legacyProp{{"powered", boolValues, 0}, {"attached", boolValues, 2}, {"disarmed", boolValues, 3}}, nil},
+	"minecraft:emerald_block":                 {id: 133},
+	"minecraft:spruce_stairs":                 {134, stairsProps, nil},
+	"minecraft:birch_stairs":                  {135, stairsProps, nil},
+	"minecraft:jungle_stairs":                 {136, stairsProps, nil},
+	"minecraft:command_block":                 {137, commandProps, nil},
+	"minecraft:beacon":                        {id: 138},
+	"minecraft:cobblestone_wall":              {139, []legacyProp{{"variant", []string{"cobblestone", "mossy_cobblestone"}, 0}}, nil},
+	"minecraft:flower_pot":                    {140, []legacyProp{{"legacy_data", numbers(0, 15), 0}}, nil},
+	"minecraft:carrots":                       {141, []legacyProp{{"age", numbers(0, 7), 0}}, nil},
+	"minecraft:potatoes":                      {142, []legacyProp{{"age", numbers(0, 7), 0}}, nil},
+	"minecraft:wooden_button":                 {143, buttonProps, nil},
+	"minecraft:skull":                         {144, []legacyProp{{"facing", facings, 0}, {"nodrop", boolValues, 3}}, nil},
+	"minecraft:anvil":                         {145, []legacyProp{{"facing", horizontals, 0}, {"damage", numbers(0, 2), 2}}, nil},
+	"minecraft:trapped_chest":                 {146, wallProps, nil},
+	"minecraft:light_weighted_pressure_plate": {147, powerProps, nil},
+	"minecraft:heavy_weighted_pressure_plate": {148, powerProps, nil},
+	"minecraft:unpowered_comparator":          {149, compareProps, nil},
+	"minecraft:powered_comparator":            {150, compareProps, nil},
+	"minecraft:daylight_detector":             {151, powerProps, nil},
+	"minecraft:redstone_block":                {id: 152},
+	"minecraft:quartz_ore":                    {id: 153},
+	"minecraft:hopper":                        {154, []legacyProp{{"facing", []string{"down", "", "north", "south", "west", "east"}, 0}, {"enabled", []string{"true", "false"}, 3}}, nil},
+	"minecraft:quartz_block":                  {155, []legacyProp{{"variant", []string{"default", "chiseled", "lines_y", "lines_x", "lines_z"}, 0}}, nil},
+	"minecraft:quartz_stairs":                 {156, stairsProps, nil},
+	"minecraft:activator_rail":                {157, railProps, nil},
+	"minecraft:dropper":                       {158, []legacyProp{{"facing", facings, 0}, {"triggered", boolValues, 3}}, nil},
+	"minecraft:stained_hardened_clay":         {159, colorProps, nil},
+	"minecraft:stained_glass_pane":            {160, colorProps, nil},
+	"minecraft:leaves2":                       {161, leavesProps("acacia", "dark_oak"), nil},
+	"minecraft:log2":                          {162, logProps("acacia", "dark_oak"), nil},
+	"minecraft:acacia_stairs":                 {163, stairsProps, nil},
+	"minecraft:dark_oak_stairs":               {164, stairsProps, nil},
+	"minecraft:slime":                         {id: 165},
+	"minecraft:barrier":                       {id: 166},
+	"minecraft:iron_trapdoor":                 {167, trapdoorProps, nil},
+	"minecraft:prismarine":                    {168, []legacyProp{{"variant", []string{"prismarine", "prismarine_bricks", "dark_prismarine"}, 0}}, nil},
+	"minecraft:sea_lantern":                   {id: 169},
+	"minecraft:hay_block":                     {170, axisProps, nil},
+	"minecraft:carpet":                        {171, colorProps, nil},
+	"minecraft:hardened_clay":                 {id: 172},
+	"minecraft:coal_block":                    {id: 173},
+	"minecraft:packed_ice":                    {id: 174},
+	"minecraft:double_plant":                  {175, []legacyProp{{"variant", []string{"sunflower", "syringa", "double_grass", "double_fern", "double_rose", "paeonia"}, 0}, {"half", []string{"lower", "upper"}, 3}}, nil},
+	"minecraft:standing_banner":               {176, rotationProps, nil},
+	"minecraft:wall_banner":                   {177, wallProps, nil},
+	"minecraft:daylight_detector_inverted":    {178, powerProps, nil},
+	"minecraft:red_sandstone":                 {179, []legacyProp{{"type", []string{"red_sandstone", "chiseled_red_sandstone", "smooth_red_sandstone"}, 0}}, nil},
+	"minecraft:red_sandstone_stairs":          {180, stairsProps, nil},
+	"minecraft:double_stone_slab2":            {181, []legacyProp{{"variant", []string{"red_sandstone"}, 0}, {"seamless", boolValues, 3}}, nil},
+	"minecraft:stone_slab2":                   {182, []legacyProp{{"variant", []string{"red_sandstone"}, 0}, {"half", []string{"bottom", "top"}, 3}}, nil},
+	"minecraft:spruce_fence_gate":             {183, gateProps, nil},
+	"minecraft:birch_fence_gate":              {184, gateProps, nil},
+	"minecraft:jungle_fence_gate":             {185, gateProps, nil},
+	"minecraft:dark_oak_fence_gate":           {186, gateProps, nil},
+	"minecraft:acacia_fence_gate":             {187, gateProps, nil},
+	"minecraft:spruce_fence":                  {id: 188},
+	"minecraft:birch_fence":                   {id: 189},
+	"minecraft:jungle_fence":                  {id: 190},
+	"minecraft:dark_oak_fence":                {id: 191},
+	"minecraft:acacia_fence":                  {id: 192},
+	"minecraft:spruce_door":                   {193, doorProps, upperDoor},
+	"minecraft:birch_door":                    {194, doorProps, upperDoor},
+	"minecraft:jungle_door":                   {195, doorProps, upperDoor},
+	"minecraft:acacia_door":                   {196, doorProps, upperDoor},
+	"minecraft:dark_oak_door":                 {197, doorProps, upperDoor},
+	"minecraft:end_rod":                       {198, facingProps, nil},
+	"minecraft:chorus_plant":                  {id: 199},
+	"minecraft:chorus_flower":                 {200, []legacyProp{{"age", numbers(0, 5), 0}}, nil},
+	"minecraft:purpur_block":                  {id: 201},
+	"minecraft:purpur_pillar":                 {202, axisProps, nil},
+	"minecraft:purpur_stairs":                 {203, stairsProps, nil},
+	"minecraft:purpur_double_slab":            {id: 204},
+	"minecraft:purpur_slab":                   {205, []legacyProp{{"half", []string{"bottom", "top"}, 3}}, nil},
+	"minecraft:end_bricks":                    {id: 206},
+	"minecraft:beetroots":                     {207, []legacyProp{{"age", numbers(0, 3), 0}}, nil},
+	"minecraft:grass_path":                    {id: 208},
+	"minecraft:end_gateway":                   {id: 209},
+	"minecraft:repeating_command_block":       {210, commandProps, nil},
+	"minecraft:chain_command_block":           {211, commandProps, nil},
+	"minecraft:frosted_ice":                   {212, []legacyProp{{"age", numbers(0, 3), 0}}, nil},
+	"minecraft:magma":                         {id: 213},
+	"minecraft:nether_wart_block":             {id: 214},
+	"minecraft:red_nether_brick":              {id: 215},
+	"minecraft:bone_block":                    {216, axisProps, nil},
+	"minecraft:structure_void":                {id: 217},
+	"minecraft:observer":                      {218, []legacyProp{{"facing", facings, 0}, {"powered", boolValues, 3}}, nil},
+	"minecraft:concrete":                      {251, colorProps, nil},
+	"minecraft:concrete_powder":               {252, colorProps, nil},
+	"minecraft:structure_block":               {255, []legacyProp{{"mode", []string{"save", "load", "corner", "data"}, 0}}, nil},
+}
+
+func init() {
+	// Shulker boxes and glazed terracotta come in every colour, with
+	// consecutive IDs.
+	for i, color := range dyeColors {
+		legacyIDs["minecraft:"+color+"_shulker_box"] = legacyBlock{id: 219 + i, props: facingProps}
+		legacyIDs["minecraft:"+color+"_glazed_terracotta"] = legacyBlock{id: 235 + i, props: []legacyProp{{"facing", horizontals, 0}}}
+	}
+}
+
+// Flatten upgrades a 1.12 block state, such as those found in the palettes of
+// schematics written before 1.13, to a flattened block state. The properties
+// stored in the data value of the block select its ID:Data entry in the
+// legacy block table. Other properties, which 1.12 computes from the
+// neighbours of the block, are kept if the flattened block has them too.
+// Property values are returned as strings, as stored in block state NBT.
+// Blocks unknown to 1.12, such as those of mods, are returned unchanged.
+func Flatten(state *base.BlockState) *base.BlockState {
+	name := state.Name
+	if !strings.Contains(name, ":") {
+		name = "minecraft:" + name
+	}
+	block, ok := legacyIDs[name]
+	if !ok {
+		return state.Clone()
+	}
+	props := block.props
+	if block.upper != nil && fmt.Sprint(state.Properties["half"]) == "upper" {
+		props = block.upper
+	}
+
+	data := 0
+	stored := make(map[string]bool, len(props))
+	for _, prop := range props {
+		stored[prop.name] = true
+		v, ok := state.Properties[prop.name]
+		if !ok {
+			continue
+		}
+		for i, value := range prop.values {
+			if value != "" && value == fmt.Sprint(v) {
+				data |= i << prop.shift
+				break
+			}
+		}
+	}
+
+	flattened := legacyState(block.id, byte(data), nil)
+	if flattened == nil {
+		return state.Clone()
+	}
+	for k, v := range flattened.Properties {
+		if legacy, ok := state.Properties[k]; ok && !stored[k] {
+			v = legacy
+		}
+		flattened.Properties[k] = fmt.Sprint(v)
+	}
+	return flattened
+}
@@ -5,7 +5,7 @@ import (
 	"fmt"
 	"io"
 	"maps"
-	"strconv"
+	"math"
 	"strings"
 
 	"github.com/oriumgames/nbt"
@@ -13,40 +13,23 @@ import (
 )
 
 type mceditNBT struct {
-	Width        int16            `nbt:"Width"`
-	Height       int16            `nbt:"Height"`
-	Length       int16            `nbt:"Length"`
-	Materials    string           `nbt:"Materials"`
-	Blocks       []byte           `nbt:"Blocks,array"`
-	Data         []byte           `nbt:"Data,array"`
-	Entities     []map[string]any `nbt:"Entities"`
-	TileEntities []map[string]any `nbt:"TileEntities"`
-	TileTicks    []map[string]any `nbt:"TileTicks"`
-	WEOffsetX    int32            `nbt:"WEOffsetX"`
-	WEOffsetY    int32            `nbt:"WEOffsetY"`
-	WEOffsetZ    int32            `nbt:"WEOffsetZ"`
-	Extra        map[string]any   `nbt:"*"`
-}
-
-var reverseLegacyBlocks map[string]struct{ ID, Data byte }
-
-func init() {
-	reverseLegacyBlocks = make(map[string]struct{ ID, Data byte })
-	for k, v := range legacyBlocks {
-		parts := strings.Split(k, ":")
-		if len(parts) != 2 {
-			continue
-		}
-		id, err1 := strconv.Atoi(parts[0])
-		data, err2 := strconv.Atoi(parts[1])
-		if err1 != nil || err2 != nil {
-			continue
-		}
-
-		// Normalize the block state string for consistent key matching
-		state := base.ParseBlockState(v)
-		reverseLegacyBlocks[state.String()] = struct{ ID, Data byte }{byte(id), byte(data)}
-	}
+	Width             int16            `nbt:"Width"`
+	Height            int16            `nbt:"Height"`
+	Length            int16            `nbt:"Length"`
+	Materials         string           `nbt:"Materials"`
+	Blocks            []byte           `nbt:"Blocks,array"`
+	AddBlocks         []byte           `nbt:"AddBlocks,array,omitempty"`
+	Add               []byte           `nbt:"Add,array,omitempty"`
+	Data              []byte           `nbt:"Data,array"`
+	SchematicaMapping map[string]any   `nbt:"SchematicaMapping,omitempty"`
+	ExtendedMetadata  map[string]any   `nbt:"ExtendedMetadata,omitempty"`
+	Entities          []map[string]any `nbt:"Entities"`
+	TileEntities      []map[string]any `nbt:"TileEntities"`
+	TileTicks         []map[string]any `nbt:"TileTicks"`
+	WEOffsetX         int32            `nbt:"WEOffsetX"`
+	WEOffsetY         int32            `nbt:"WEOffsetY"`
+	WEOffsetZ         int32            `nbt:"WEOffsetZ"`
+	Extra             map[string]any   `nbt:"*"`
 }
 
 // Read reads an MCEdit/Schematica legacy schematic.
@@ -74,11 +57,33 @@ func Read(r io.Reader) (base.Schematic, error) {
 	s.SetDataVersion(1519)
 	s.SetOffset(int(data.WEOffsetX), int(data.WEOffsetY), int(data.WEOffsetZ))
 	s.SetMetadata("Materials", data.Materials)
+	if len(data.ExtendedMetadata) > 0 {
+		s.SetMetadata("ExtendedMetadata", data.ExtendedMetadata)
+	}
+	base.SetExtra(s, "root", data.Extra)
 
 	expectedLen := width * height * length
 	if len(data.Blocks) != expectedLen || len(data.Data) != expectedLen {
 		return nil, fmt.Errorf("block data mismatch: expected %d bytes, got %d blocks and %d data", expectedLen, len(data.Blocks), len(data.Data))
 	}
+	addBits, err := readAddBits(data, expectedLen)
+	if err != nil {
+		return nil, err
+	}
+
+	// Schematica maps the IDs of the blocks to their names, which is needed
+	// for mod blocks as their IDs differ between worlds.
+	mapping := make(map[int]string, len(data.SchematicaMapping))
+	if len(data.SchematicaMapping) > 0 {
+		names := make(map[string]int, len(data.SchematicaMapping))
+		for name, v := range data.SchematicaMapping {
+			if id, ok := v.(int16); ok {
+				mapping[int(id)] = name
+				names[name] = int(id)
+			}
+		}
+		s.SetMetadata("SchematicaMapping", names)
+	}
 
 	// MCEdit format standard layout: Index = (y * Length + z) * Width + x
 	for y := range height {
@@ -90,24 +95,13 @@ func Read(r io.Reader) (base.Schematic, error) {
 					break
 				}
 
-				id := data.Blocks[idx]
-				meta := data.Data[idx]
-
-				key := fmt.Sprintf("%d:%d", id, meta)
-
-				blockStr, ok := legacyBlocks[key]
-				if !ok {
-					// Fallback: try to find base block without meta
-					if baseStr, ok := legacyBlocks[fmt.Sprintf("%d:0", id)]; ok {
-						blockStr = baseStr
-					} else {
-						// Fallback to air if mapping not found
-						continue
-					}
+				id := int(data.Blocks[idx])
+				if addBits != nil {
+					id |= addBits[idx] << 8
+				}
+				if state := legacyState(id, data.Data[idx], mapping); state != nil {
+					s.SetBlock(x, y, z, state)
 				}
-
-				state := base.ParseBlockState(blockStr)
-				s.SetBlock(x, y, z, state)
 			}
 		}
 	}
@@ -172,13 +166,19 @@ func Read(r io.Reader) (base.Schematic, error) {
 	return s, nil
 }
 
-// Write writes a schematic in MCEdit legacy format.
+// Write writes a schematic in MCEdit legacy format. Block states without a
+// legacy ID are written as air, see UnmappedStates.
 func Write(w io.Writer, s base.Schematic) error {
 	width, height, length := s.Dimensions()
+	if max(width, height, length) > math.MaxInt16 {
+		return fmt.Errorf("dimensions %dx%dx%d exceed the maximum of %d", width, height, length, math.MaxInt16)
+	}
 	count := width * height * length
 	blocks := make([]byte, count)
 	data := make([]byte, count)
+	var addBlocks []byte
 
+	d := newDowngrader(s)
 	for y := range height {
 		for z := range length {
 			for x := range width {
@@ -189,9 +189,23 @@ func Write(w io.Writer, s base.Schematic) error {
 					continue
 				}
 
-				if mapped, ok := reverseLegacyBlocks[state.String()]; ok {
-					blocks[idx] = mapped.ID
-					data[idx] = mapped.Data
+				legacy, ok := d.lookup(state)
+				if !ok {
+					continue
+				}
+
+				blocks[idx] = byte(legacy.ID)
+				data[idx] = legacy.Data
+				if legacy.ID > 0xFF {
+					if addBlocks == nil {
+						addBlocks = make([]byte, (count+1)/2)
+					}
+					// Even indices are stored in the high nibble.
+					if idx&1 == 0 {
+						addBlocks[idx>>1] |= byte(legacy.ID>>8) << 4
+					} else {
+						addBlocks[idx>>1] |= byte(legacy.ID >> 8)
+					}
 				}
 			}
 		}
@@ -203,7 +217,18 @@ func Write(w io.Writer, s base.Schematic) error {
 		Length:    int16(length),
 		Materials: "Alpha",
 		Blocks:    blocks,
+		AddBlocks: addBlocks,
 		Data:      data,
+		Extra:     base.Extra(s, "mcedit", "root"),
+	}
+	if len(d.mapping) > 0 {
+		nbtData.SchematicaMapping = make(map[string]any, len(d.mapping))
+		for name, id := range d.mapping {
+			nbtData.SchematicaMapping[name] = int16(id)
+		}
+	}
+	if extended, ok := s.Metadata()["ExtendedMetadata"].(map[string]any); ok && len(extended) > 0 {
+		nbtData.ExtendedMetadata = extended
 	}
 
 	ox, oy, oz := s.Offset()
@@ -240,12 +265,66 @@ func Write(w io.Writer, s base.Schematic) error {
 		nbtData.Entities = append(nbtData.Entities, tag)
 	}
 
-	gz := gzip.NewWriter(w)
-	defer gz.Close()
+	return base.WriteGzipNBT(w, nbtData, 0)
+}
 
-	if err := nbt.NewEncoderWithEncoding(gz, nbt.BigEndian).Encode(nbtData); err != nil {
-		return fmt.Errorf("encode nbt: %w", err)
+// readAddBits returns the high 4 bits of the ID of every block, stored either
+// in Schematica's AddBlocks nibble array or in MCEdit's Add byte array. It
+// returns nil if the schematic holds neither.
+func readAddBits(data mceditNBT, count int) ([]int, error) {
+	switch {
+	case len(data.AddBlocks) > 0:
+		if len(data.AddBlocks) < (count+1)/2 {
+			return nil, fmt.Errorf("add blocks mismatch: expected %d bytes, got %d", (count+1)/2, len(data.AddBlocks))
+		}
+		bits := make([]int, count)
+		for i := range bits {
+			// Even indices are stored in the high nibble.
+			if i&1 == 0 {
+				bits[i] = int(data.AddBlocks[i>>1]>>4) & 0xF
+			} else {
+				bits[i] = int(data.AddBlocks[i>>1]) & 0xF
+			}
+		}
+		return bits, nil
+	case len(data.Add) > 0:
+		if len(data.Add) < count {
+			return nil, fmt.Errorf("add mismatch: expected %d bytes, got %d", count, len(data.Add))
+		}
+		bits := make([]int, count)
+		for i := range bits {
+			bits[i] = int(data.Add[i]) & 0xF
+		}
+		return bits, nil
+	default:
+		return nil, nil
 	}
+}
+
+// legacyState returns the block state of a legacy block ID and data value, or
+// nil if it is unknown. Mod blocks named by the Schematica mapping keep their
+// data value as a data property.
+func legacyState(id int, data byte, mapping map[int]string) *base.BlockState {
+	if name, ok := mapping[id]; ok && isModBlock(name) {
+		state := &base.BlockState{Name: name}
+		if data != 0 {
+			state.Properties = map[string]any{"data": int32(data)}
+		}
+		return state
+	}
+
+	blockStr, ok := legacyBlocks[fmt.Sprintf("%d:%d", id, data)]
+	if !ok {
+		// Fallback: try to find base block without meta
+		if blockStr, ok = legacyBlocks[fmt.Sprintf("%d:0", id)]; !ok {
+			// Fallback to air if mapping not found
+			return nil
+		}
+	}
+	return base.ParseBlockState(blockStr)
+}
 
-	return nil
+// isModBlock reports whether a block name is outside the minecraft namespace.
+func isModBlock(name string) bool {
+	return !strings.HasPrefix(name, "minecraft:")
 }
@@ -0,0 +1,285 @@
+package mesh
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/oriumgames/schem/format/internal/base"
+)
+
+const (
+	glbMagic   = 0x46546C67
+	glbVersion = 2
+	chunkJSON  = 0x4E4F534A
+	chunkBIN   = 0x004E4942
+
+	componentFloat  = 5126
+	componentUint32 = 5125
+	targetArray     = 34962
+	targetElement   = 34963
+	filterNearest   = 9728
+)
+
+type gltfDoc struct {
+	Asset       gltfAsset        `json:"asset"`
+	Scene       int              `json:"scene"`
+	Scenes      []gltfScene      `json:"scenes"`
+	Nodes       []gltfNode       `json:"nodes"`
+	Meshes      []gltfMesh       `json:"meshes,omitempty"`
+	Materials   []gltfMaterial   `json:"materials,omitempty"`
+	Accessors   []gltfAccessor   `json:"accessors,omitempty"`
+	BufferViews []gltfBufferView `json:"bufferViews,omitempty"`
+	Buffers     []gltfBuffer     `json:"buffers,omitempty"`
+	Images      []gltfImage      `json:"images,omitempty"`
+	Samplers    []gltfSampler    `json:"samplers,omitempty"`
+	Textures    []gltfTexture    `json:"textures,omitempty"`
+}
+
+type gltfAsset struct {
+	Version   string `json:"version"`
+	Generator string `json:"generator,omitempty"`
+}
+
+type gltfScene struct {
+	Nodes []int `json:"nodes"`
+}
+
+type gltfNode struct {
+	Mesh *int `json:"mesh,omitempty"`
+}
+
+type gltfMesh struct {
+	Primitives []gltfPrimitive `json:"primitives"`
+}
+
+type gltfPrimitive struct {
+	Attributes map[string]int `json:"attributes"`
+	Indices    int            `json:"indices"`
+	Material   int            `json:"material"`
+}
+
+type gltfMaterial struct {
+	PBR         gltfPBR `json:"pbrMetallicRoughness"`
+	AlphaMode   string  `json:"alphaMode,omitempty"`
+	DoubleSided bool    `json:"doubleSided,omitempty"`
+}
+
+type gltfPBR struct {
+	BaseColorFactor  [4]float64   `json:"baseColorFactor"`
+	BaseColorTexture *gltfTexInfo `json:"baseColorTexture,omitempty"`
+	MetallicFactor   float64      `json:"metallicFactor"`
+	RoughnessFactor  float64      `json:"roughnessFactor"`
+}
+
+type gltfTexInfo struct {
+	Index int `json:"index"`
+}
+
+type gltfAccessor struct {
+	BufferView    int       `json:"bufferView"`
+	ComponentType int       `json:"componentType"`
+	Count         int       `json:"count"`
+	Type          string    `json:"type"`
+	Min           []float32 `json:"min,omitempty"`
+	Max           []float32 `json:"max,omitempty"`
+}
+
+type gltfBufferView struct {
+	Buffer     int `json:"buffer"`
+	ByteOffset int `json:"byteOffset"`
+	ByteLength int `json:"byteLength"`
+	Target     int `json:"target,omitempty"`
+}
+
+type gltfBuffer struct {
+	ByteLength int `json:"byteLength"`
+}
+
+type gltfImage struct {
+	BufferView int    `json:"bufferView"`
+	MimeType   string `json:"mimeType"`
+}
+
+type gltfSampler struct {
+	MagFilter int `json:"magFilter"`
+	MinFilter int `json:"minFilter"`
+}
+
+type gltfTexture struct {
+	Sampler int `json:"sampler"`
+	Source  int `json:"source"`
+}
+
+// WriteGLB writes the mesh of a schematic as a binary glTF 2.0 file. The
+// texture image of the atlas, if any, is embedded in the file.
+func WriteGLB(w io.Writer, s base.Schematic, opts Options) error {
+	m := build(s, opts)
+	doc := gltfDoc{
+		Asset:  gltfAsset{Version: "2.0", Generator: "schem"},
+		Scenes: []gltfScene{{Nodes: []int{0}}},
+		Nodes:  []gltfNode{{}},
+	}
+	var bin bytes.Buffer
+
+	// addView appends data to the binary buffer as a new buffer view.
+	addView := func(data []byte, target int) int {
+		for bin.Len()%4 != 0 {
+			bin.WriteByte(0)
+		}
+		doc.BufferViews = append(doc.BufferViews, gltfBufferView{ByteOffset: bin.Len(), ByteLength: len(data), Target: target})
+		bin.Write(data)
+		return len(doc.BufferViews) - 1
+	}
+	addAccessor := func(a gltfAccessor) int {
+		doc.Accessors = append(doc.Accessors, a)
+		return len(doc.Accessors) - 1
+	}
+
+	textured := false
+	for _, mat := range m.materials {
+		textured = textured || mat.textured
+	}
+	if textured && len(opts.Atlas.Image) > 0 {
+		view := addView(opts.Atlas.Image, 0)
+		doc.Images = []gltfImage{{BufferView: view, MimeType: "image/png"}}
+		doc.Samplers = []gltfSampler{{MagFilter: filterNearest, MinFilter: filterNearest}}
+		doc.Textures = []gltfTexture{{}}
+	}
+
+	for _, mat := range m.materials {
+		c := mat.color
+		gm := gltfMaterial{PBR: gltfPBR{
+			BaseColorFactor: [4]float64{linear(c.R), linear(c.G), linear(c.B), float64(c.A) / 255},
+			RoughnessFactor: 1,
+		}}
+		if c.A < 255 {
+			gm.AlphaMode = "BLEND"
+		}
+		if mat.textured && len(doc.Textures) > 0 {
+			gm.PBR.BaseColorTexture = &gltfTexInfo{}
+			gm.AlphaMode = "MASK"
+		}
+		doc.Materials = append(doc.Materials, gm)
+	}
+
+	var primitives []gltfPrimitive
+	for _, p := range m.primitives {
+		if len(p.indices) == 0 {
+			continue
+		}
+		lo, hi := bounds(p.positions)
+		attrs := map[string]int{
+			"POSITION": addAccessor(gltfAccessor{
+				BufferView: addView(floats3(p.positions), targetArray), ComponentType: componentFloat,
+				Count: len(p.positions), Type: "VEC3", Min: lo[:], Max: hi[:],
+			}),
+			"NORMAL": addAccessor(gltfAccessor{
+				BufferView: addView(floats3(p.normals), targetArray), ComponentType: componentFloat,
+				Count: len(p.normals), Type: "VEC3",
+			}),
+		}
+		if m.materials[p.material].textured {
+			attrs["TEXCOORD_0"] = addAccessor(gltfAccessor{
+				BufferView: addView(floats2(p.uvs), targetArray), ComponentType: componentFloat,
+				Count: len(p.uvs), Type: "VEC2",
+			})
+		}
+		indices := make([]byte, 4*len(p.indices))
+		for i, idx := range p.indices {
+			binary.LittleEndian.PutUint32(indices[i*4:], idx)
+		}
+		primitives = append(primitives, gltfPrimitive{
+			Attributes: attrs,
+			Indices: addAccessor(gltfAccessor{
+				BufferView: addView(indices, targetElement), ComponentType: componentUint32,
+				Count: len(p.indices), Type: "SCALAR",
+			}),
+			Material: p.material,
+		})
+	}
+	if len(primitives) > 0 {
+		doc.Meshes = []gltfMesh{{Primitives: primitives}}
+		meshIndex := 0
+		doc.Nodes[0].Mesh = &meshIndex
+	}
+	for bin.Len()%4 != 0 {
+		bin.WriteByte(0)
+	}
+	if bin.Len() > 0 {
+		doc.Buffers = []gltfBuffer{{ByteLength: bin.Len()}}
+	}
+
+	js, err := json.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("encode gltf: %w", err)
+	}
+	for len(js)%4 != 0 {
+		js = append(js, ' ')
+	}
+
+	var out bytes.Buffer
+	total := 12 + 8 + len(js)
+	if bin.Len() > 0 {
+		total += 8 + bin.Len()
+	}
+	for _, v := range []uint32{glbMagic, glbVersion, uint32(total), uint32(len(js)), chunkJSON} {
+		_ = binary.Write(&out, binary.LittleEndian, v)
+	}
+	out.Write(js)
+	if bin.Len() > 0 {
+		_ = binary.Write(&out, binary.LittleEndian, uint32(bin.Len()))
+		_ = binary.Write(&out, binary.LittleEndian, uint32(chunkBIN))
+		out.Write(bin.Bytes())
+	}
+	if _, err := w.Write(out.Bytes()); err != nil {
+		return fmt.Errorf("write data: %w", err)
+	}
+	return nil
+}
+
+// linear converts an sRGB colour channel to the linear value glTF expects,
+// rounded to keep the JSON short.
+func linear(v uint8) float64 {
+	c := float64(v) / 255
+	if c <= 0.04045 {
+		c /= 12.92
+	} else {
+		c = math.Pow((c+0.055)/1.055, 2.4)
+	}
+	return math.Round(c*1e4) / 1e4
+}
+
+func bounds(positions [][3]float32) (lo, hi [3]float32) {
+	lo, hi = positions[0], positions[0]
+	for _, p := range positions {
+		for i := range 3 {
+			lo[i] = min(lo[i], p[i])
+			hi[i] = max(hi[i], p[i])
+		}
+	}
+	return lo, hi
+}
+
+func floats3(vs [][3]float32) []byte {
+	out := make([]byte, 0, len(vs)*12)
+	for _, v := range vs {
+		for _, f := range v {
+			out = binary.LittleEndian.AppendUint32(out, math.Float32bits(f))
+		}
+	}
+	return out
+}
+
+func floats2(vs [][2]float32) []byte {
+	out := make([]byte, 0, len(vs)*8)
+	for _, v := range vs {
+		for _, f := range v {
+			out = binary.LittleEndian.AppendUint32(out, math.Float32bits(f))
+		}
+	}
+	return out
+}
@@ -0,0 +1,296 @@
+package mesh
+
+import (
+	"image/color"
+
+	"github.com/oriumgames/schem/format/internal/base"
+)
+
+// UVRect is a region of a texture atlas, in texture coordinates ranging from
+// 0 to 1 with the origin at the top left of the image.
+type UVRect struct {
+	U0, V0, U1, V1 float32
+}
+
+// Atlas maps blocks to regions of a texture image.
+type Atlas struct {
+	// Image is the PNG encoded texture image, embedded in glTF files.
+	Image []byte
+	// Path is the path of the texture image referenced by MTL files.
+	Path string
+	// Regions maps blocks to the region of the image holding their texture.
+	// Keys are block names or block state strings, as for colour tables.
+	Regions map[string]UVRect
+}
+
+// region returns the region of the atlas holding the texture of a block.
+func (a *Atlas) region(state *base.BlockState) (UVRect, bool) {
+	if a == nil {
+		return UVRect{}, false
+	}
+	if len(state.Properties) > 0 {
+		if r, ok := a.Regions[state.String()]; ok {
+			return r, true
+		}
+	}
+	r, ok := a.Regions[state.Name]
+	return r, ok
+}
+
+// Options configures how a schematic is turned into a mesh.
+type Options struct {
+	// Colors maps blocks to the colour of their material. Blocks missing from
+	// the table are grey. If nil, the default colour table is used.
+	Colors base.ColorTable
+	// Atlas maps blocks to regions of a texture image. Blocks found in the
+	// atlas are textured instead of coloured. If nil, every block is
+	// coloured.
+	Atlas *Atlas
+}
+
+// material is a material of a mesh: either a colour or a texture.
+type material struct {
+	color    color.RGBA
+	textured bool
+}
+
+// primitive holds the triangles of a mesh that share a material.
+type primitive struct {
+	material  int
+	positions [][3]float32
+	normals   [][3]float32
+	uvs       [][2]float32
+	indices   []uint32
+}
+
+// mesh is a triangle mesh split into primitives by material.
+type mesh struct {
+	materials  []material
+	primitives []*primitive
+	lookup     map[material]int
+}
+
+// primitive returns the primitive of a material, adding it if needed.
+func (m *mesh) primitive(mat material) *primitive {
+	if m.lookup == nil {
+		m.lookup = make(map[material]int)
+	}
+	idx, ok := m.lookup[mat]
+	if !ok {
+		idx = len(m.materials)
+		m.lookup[mat] = idx
+		m.materials = append(m.materials, mat)
+		m.primitives = append(m.primitives, &primitive{material: idx})
+	}
+	return m.primitives[idx]
+}
+
+// directions are the six face directions, as normal vectors.
+var directions = [6][3]int{{1, 0, 0}, {-1, 0, 0}, {0, 1, 0}, {0, -1, 0}, {0, 0, 1}, {0, 0, -1}}
+
+// faceCorners returns the corners of a face of a box, counter-clockwise when
+// seen from outside, starting at the bottom left.
+func faceCorners(b box, dir int) [4][3]float32 {
+	x0, y0, z0 := b.min[0], b.min[1], b.min[2]
+	x1, y1, z1 := b.max[0], b.max[1], b.max[2]
+	switch dir {
+	case 0:
+		return [4][3]float32{{x1, y0, z1}, {x1, y0, z0}, {x1, y1, z0}, {x1, y1, z1}}
+	case 1:
+		return [4][3]float32{{x0, y0, z0}, {x0, y0, z1}, {x0, y1, z1}, {x0, y1, z0}}
+	case 2:
+		return [4][3]float32{{x0, y1, z0}, {x0, y1, z1}, {x1, y1, z1}, {x1, y1, z0}}
+	case 3:
+		return [4][3]float32{{x0, y0, z0}, {x1, y0, z0}, {x1, y0, z1}, {x0, y0, z1}}
+	case 4:
+		return [4][3]float32{{x0, y0, z1}, {x1, y0, z1}, {x1, y1, z1}, {x0, y1, z1}}
+	default:
+		return [4][3]float32{{x1, y0, z0}, {x0, y0, z0}, {x0, y1, z0}, {x1, y1, z0}}
+	}
+}
+
+// addFace adds the face of a box facing dir to the primitive, with the box
+// offset by pos. uv is the texture region of the face.
+func (p *primitive) addFace(b box, dir int, pos [3]float32, uv UVRect) {
+	corners := faceCorners(b, dir)
+	normal := [3]float32{float32(directions[dir][0]), float32(directions[dir][1]), float32(directions[dir][2])}
+	uvs := [4][2]float32{{uv.U0, uv.V1}, {uv.U1, uv.V1}, {uv.U1, uv.V0}, {uv.U0, uv.V0}}
+	start := uint32(len(p.positions))
+	for i, c := range corners {
+		p.positions = append(p.positions, [3]float32{c[0] + pos[0], c[1] + pos[1], c[2] + pos[2]})
+		p.normals = append(p.normals, normal)
+		p.uvs = append(p.uvs, uvs[i])
+	}
+	p.indices = append(p.indices, start, start+1, start+2, start, start+2, start+3)
+}
+
+// cell is a block of the schematic prepared for meshing.
+type cell struct {
+	name     string
+	boxes    []box
+	full     bool
+	opaque   bool
+	material material
+	uv       UVRect
+}
+
+// build turns a schematic into a mesh. Faces between a block and an opaque
+// full neighbour, or a translucent full neighbour of the same kind, are left
+// out. Coloured faces of full blocks are merged greedily into larger
+// rectangles; textured faces are kept per block so the texture is not
+// stretched.
+func build(s base.Schematic, opts Options) *mesh {
+	colors := opts.Colors
+	if colors == nil {
+		colors = base.DefaultColors()
+	}
+	width, height, length := s.Dimensions()
+	cells := make([]*cell, width*height*length)
+	index := func(x, y, z int) int { return (y*length+z)*width + x }
+	cache := make(map[string]*cell)
+
+	for y := range height {
+		for z := range length {
+			for x := range width {
+				state := s.Block(x, y, z)
+				if state == nil || base.IsEmpty(state.Name) {
+					continue
+				}
+				key := state.String()
+				c, ok := cache[key]
+				if !ok {
+					c = &cell{name: state.Name}
+					c.boxes, c.full = shapeOf(state)
+					if uv, ok := opts.Atlas.region(state); ok {
+						c.material, c.uv = material{color: color.RGBA{R: 255, G: 255, B: 255, A: 255}, textured: true}, uv
+					} else {
+						clr, ok := colors.Color(state)
+						if !ok {
+							clr = base.UnknownColor
+						}
+						c.material, c.uv = material{color: clr}, UVRect{U1: 1, V1: 1}
+					}
+					c.opaque = c.full && c.material.color.A == 255
+					cache[key] = c
+				}
+				cells[index(x, y, z)] = c
+			}
+		}
+	}
+
+	at := func(x, y, z int) *cell {
+		if x < 0 || y < 0 || z < 0 || x >= width || y >= height || z >= length {
+			return nil
+		}
+		return cells[index(x, y, z)]
+	}
+	// hidden reports whether the face of a full block c facing the
+	// neighbour n is hidden.
+	hidden := func(c, n *cell) bool {
+		return n != nil && n.full && (n.opaque || n.name == c.name)
+	}
+
+	m := &mesh{}
+	size := [3]int{width, height, length}
+	for dir, d := range directions {
+		// The axis the faces point along, and the two axes spanning them.
+		axis := 0
+		for d[axis] == 0 {
+			axis++
+		}
+		u, v := (axis+1)%3, (axis+2)%3
+		mask := make([]*cell, size[u]*size[v])
+		for slice := range size[axis] {
+			for j := range size[v] {
+				for i := range size[u] {
+					var p [3]int
+					p[axis], p[u], p[v] = slice, i, j
+					c := at(p[0], p[1], p[2])
+					mask[j*size[u]+i] = nil
+					if c == nil {
+						continue
+					}
+					n := at(p[0]+d[0], p[1]+d[1], p[2]+d[2])
+					if !c.full {
+						addPartial(m, c, dir, p, n, hidden)
+						continue
+					}
+					if !hidden(c, n) {
+						mask[j*size[u]+i] = c
+					}
+				}
+			}
+			greedy(m, mask, size[u], size[v], func(i, j, w, h int) box {
+				var b box
+				b.min[axis], b.max[axis] = float32(slice), float32(slice+1)
+				b.min[u], b.max[u] = float32(i), float32(i+w)
+				b.min[v], b.max[v] = float32(j), float32(j+h)
+				return b
+			}, dir)
+		}
+	}
+	return m
+}
+
+// addPartial adds the faces of a block that does not fill its space. Faces
+// on the boundary of the block are hidden by full neighbours like those of
+// full blocks.
+func addPartial(m *mesh, c *cell, dir int, pos [3]int, n *cell, hidden func(c, n *cell) bool) {
+	d := directions[dir]
+	offset := [3]float32{float32(pos[0]), float32(pos[1]), float32(pos[2])}
+	for _, b := range c.boxes {
+		onBoundary := false
+		for axis := range 3 {
+			if (d[axis] > 0 && b.max[axis] == 1) || (d[axis] < 0 && b.min[axis] == 0) {
+				onBoundary = true
+			}
+		}
+		if onBoundary && n != nil && n.full && n.opaque {
+			continue
+		}
+		m.primitive(c.material).addFace(b, dir, offset, c.uv)
+	}
+}
+
+// greedy merges the faces of the mask into rectangles of equal material and
+// adds them to the mesh. rect converts a rectangle of the mask into the box
+// whose face is added.
+func greedy(m *mesh, mask []*cell, w, h int, rect func(i, j, w, h int) box, dir int) {
+	for j := range h {
+		for i := 0; i < w; {
+			c := mask[j*w+i]
+			if c == nil {
+				i++
+				continue
+			}
+			same := func(o *cell) bool {
+				return o != nil && o.material == c.material && (!c.material.textured || o == c)
+			}
+			width := 1
+			if !c.material.textured {
+				for i+width < w && same(mask[j*w+i+width]) {
+					width++
+				}
+			}
+			height := 1
+			if !c.material.textured {
+			grow:
+				for j+height < h {
+					for k := range width {
+						if !same(mask[(j+height)*w+i+k]) {
+							break grow
+						}
+					}
+					height++
+				}
+			}
+			for dj := range height {
+				for di := range width {
+					mask[(j+dj)*w+i+di] = nil
+				}
+			}
+			m.primitive(c.material).addFace(rect(i, j, width, height), dir, [3]float32{}, c.uv)
+			i += width
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package mesh
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/oriumgames/schem/format/internal/base"
+)
+
+// WriteOBJ writes the mesh of a schematic as a Wavefront OBJ file to obj and
+// its materials to mtl. mtlName is the name of the MTL file referenced by the
+// OBJ file.
+func WriteOBJ(obj, mtl io.Writer, mtlName string, s base.Schematic, opts Options) error {
+	m := build(s, opts)
+
+	mw := bufio.NewWriter(mtl)
+	for i, mat := range m.materials {
+		fmt.Fprintf(mw, "newmtl %s\n", materialName(i))
+		fmt.Fprintf(mw, "Kd %s %s %s\n", channel(mat.color.R), channel(mat.color.G), channel(mat.color.B))
+		if mat.color.A < 255 {
+			fmt.Fprintf(mw, "d %s\n", channel(mat.color.A))
+		}
+		if mat.textured {
+			fmt.Fprintf(mw, "map_Kd %s\n", opts.Atlas.Path)
+		}
+		mw.WriteString("\n")
+	}
+	if err := mw.Flush(); err != nil {
+		return fmt.Errorf("write mtl: %w", err)
+	}
+
+	ow := bufio.NewWriter(obj)
+	if mtlName != "" {
+		fmt.Fprintf(ow, "mtllib %s\n", mtlName)
+	}
+	for _, p := range m.primitives {
+		for _, pos := range p.positions {
+			fmt.Fprintf(ow, "v %s %s %s\n", number(pos[0]), number(pos[1]), number(pos[2]))
+		}
+	}
+	for _, p := range m.primitives {
+		for _, uv := range p.uvs {
+			// OBJ texture coordinates have their origin at the bottom left.
+			fmt.Fprintf(ow, "vt %s %s\n", number(uv[0]), number(1-uv[1]))
+		}
+	}
+	for _, n := range directions {
+		fmt.Fprintf(ow, "vn %d %d %d\n", n[0], n[1], n[2])
+	}
+
+	offset := 1
+	for _, p := range m.primitives {
+		fmt.Fprintf(ow, "usemtl %s\n", materialName(p.material))
+		for i := 0; i+2 < len(p.indices); i += 3 {
+			ow.WriteString("f")
+			for _, idx := range p.indices[i : i+3] {
+				v := offset + int(idx)
+				fmt.Fprintf(ow, " %d/%d/%d", v, v, normalIndex(p.normals[idx]))
+			}
+			ow.WriteString("\n")
+		}
+		offset += len(p.positions)
+	}
+	if err := ow.Flush(); err != nil {
+		return fmt.Errorf("write obj: %w", err)
+	}
+	return nil
+}
+
+// materialName returns the name of the material at the index passed.
+func materialName(i int) string {
+	return "m" + strconv.Itoa(i)
+}
+
+// normalIndex returns the OBJ index of a face normal.
+func normalIndex(n [3]float32) int {
+	for i, d := range directions {
+		if float32(d[0]) == n[0] && float32(d[1]) == n[1] && float32(d[2]) == n[2] {
+			return i + 1
+		}
+	}
+	return 1
+}
+
+// channel formats a colour channel as a number between 0 and 1.
+func channel(v uint8) string {
+	return strconv.FormatFloat(float64(v)/255, 'f', 4, 64)
+}
+
+// number formats a coordinate with as few digits as needed.
+func number(v float32) string {
+	return strconv.FormatFloat(float64(v), 'f', -1, 32)
+}
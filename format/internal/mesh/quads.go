@@ -0,0 +1,37 @@
+package mesh
+
+import (
+	"image/color"
+
+	"github.com/oriumgames/schem/format/internal/base"
+)
+
+// Quad is a face of the mesh of a schematic.
+type Quad struct {
+	// Corners are the corners of the face, counter-clockwise when seen from
+	// outside.
+	Corners [4][3]float32
+	// Normal is the direction the face points in.
+	Normal [3]int
+	// Color is the colour of the block the face belongs to.
+	Color color.RGBA
+}
+
+// Quads returns the faces of the mesh of a schematic, coloured from the
+// colour table passed, for use by renderers.
+func Quads(s base.Schematic, colors base.ColorTable) []Quad {
+	m := build(s, Options{Colors: colors})
+	var quads []Quad
+	for _, p := range m.primitives {
+		c := m.materials[p.material].color
+		for i := 0; i+3 < len(p.positions); i += 4 {
+			q := Quad{Color: c}
+			copy(q.Corners[:], p.positions[i:i+4])
+			for axis := range 3 {
+				q.Normal[axis] = int(p.normals[i][axis])
+			}
+			quads = append(quads, q)
+		}
+	}
+	return quads
+}
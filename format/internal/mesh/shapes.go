@@ -0,0 +1,197 @@
+package mesh
+
+import (
+	"strings"
+
+	"github.com/oriumgames/schem/format/internal/base"
+)
+
+// box is an axis-aligned box within a block, in block units.
+type box struct {
+	min, max [3]float32
+}
+
+var fullBox = box{max: [3]float32{1, 1, 1}}
+
+// px converts a number of texture pixels to block units.
+func px(n float32) float32 {
+	return n / 16
+}
+
+// shapeOf returns the boxes making up the simplified shape of a block, and
+// whether the block fills its whole space. Blocks without a known shape are
+// full cubes.
+func shapeOf(state *base.BlockState) ([]box, bool) {
+	_, path, found := strings.Cut(state.Name, ":")
+	if !found {
+		path = state.Name
+	}
+	prop := func(key string) string {
+		v, _ := state.Properties[key].(string)
+		return v
+	}
+
+	switch {
+	case strings.HasSuffix(path, "_slab"):
+		switch prop("type") {
+		case "top":
+			return []box{{min: [3]float32{0, 0.5, 0}, max: [3]float32{1, 1, 1}}}, false
+		case "double":
+			return []box{fullBox}, true
+		default:
+			return []box{{max: [3]float32{1, 0.5, 1}}}, false
+		}
+	case strings.HasSuffix(path, "_stairs"):
+		return stairs(prop("facing"), prop("half")), false
+	case strings.HasSuffix(path, "_fence_gate"):
+		if facing := prop("facing"); facing == "east" || facing == "west" {
+			return []box{{min: [3]float32{px(7), px(5), 0}, max: [3]float32{px(9), 1, 1}}}, false
+		}
+		return []box{{min: [3]float32{0, px(5), px(7)}, max: [3]float32{1, 1, px(9)}}}, false
+	case strings.HasSuffix(path, "_fence"):
+		return post(px(6), 1, px(7), px(6), px(15), state, "true"), false
+	case strings.HasSuffix(path, "_wall") && !strings.HasSuffix(path, "_sign") && !strings.HasSuffix(path, "_banner"):
+		return post(px(4), 1, px(5), 0, px(14), state, "low", "tall"), false
+	case strings.HasSuffix(path, "_pane") || path == "iron_bars":
+		return post(px(7), 1, px(7), 0, 1, state, "true"), false
+	case strings.HasSuffix(path, "_carpet"):
+		return []box{{max: [3]float32{1, px(1), 1}}}, false
+	case strings.HasSuffix(path, "_pressure_plate"):
+		return []box{{min: [3]float32{px(1), 0, px(1)}, max: [3]float32{px(15), px(1), px(15)}}}, false
+	case path == "snow":
+		layers := 1
+		if v := prop("layers"); len(v) == 1 && v[0] >= '1' && v[0] <= '8' {
+			layers = int(v[0] - '0')
+		}
+		if layers == 8 {
+			return []box{fullBox}, true
+		}
+		return []box{{max: [3]float32{1, float32(layers) / 8, 1}}}, false
+	case strings.HasSuffix(path, "_trapdoor"):
+		if prop("open") == "true" {
+			return []box{panel(opposite(prop("facing")), px(3))}, false
+		}
+		if prop("half") == "top" {
+			return []box{{min: [3]float32{0, px(13), 0}, max: [3]float32{1, 1, 1}}}, false
+		}
+		return []box{{max: [3]float32{1, px(3), 1}}}, false
+	case strings.HasSuffix(path, "_door"):
+		return []box{panel(opposite(prop("facing")), px(3))}, false
+	case strings.Contains(path, "torch") || strings.HasSuffix(path, "_rod"):
+		return []box{{min: [3]float32{px(7), 0, px(7)}, max: [3]float32{px(9), px(10), px(9)}}}, false
+	case isPlant(path):
+		return []box{{min: [3]float32{px(4), 0, px(4)}, max: [3]float32{px(12), px(12), px(12)}}}, false
+	}
+	return []box{fullBox}, true
+}
+
+// stairs returns the boxes of a stairs block: a slab and a step on the side
+// it faces.
+func stairs(facing, half string) []box {
+	slab := box{max: [3]float32{1, 0.5, 1}}
+	step := box{min: [3]float32{0, 0.5, 0}, max: [3]float32{1, 1, 1}}
+	if half == "top" {
+		slab = box{min: [3]float32{0, 0.5, 0}, max: [3]float32{1, 1, 1}}
+		step = box{max: [3]float32{1, 0.5, 1}}
+	}
+	switch facing {
+	case "east":
+		step.min[0] = 0.5
+	case "west":
+		step.max[0] = 0.5
+	case "south":
+		step.min[2] = 0.5
+	default:
+		step.max[2] = 0.5
+	}
+	return []box{slab, step}
+}
+
+// post returns the boxes of a post of the width passed, centred in the block,
+// with an arm of width armWidth towards every side whose property holds one
+// of the values passed.
+func post(width, height, armWidth, armBottom, armTop float32, state *base.BlockState, values ...string) []box {
+	lo, hi := (1-width)/2, (1+width)/2
+	boxes := []box{{min: [3]float32{lo, 0, lo}, max: [3]float32{hi, height, hi}}}
+	armLo, armHi := (1-armWidth)/2, (1+armWidth)/2
+	for _, side := range []string{"north", "south", "east", "west"} {
+		v, _ := state.Properties[side].(string)
+		if !containsString(values, v) {
+			continue
+		}
+		arm := box{min: [3]float32{armLo, armBottom, armLo}, max: [3]float32{armHi, armTop, armHi}}
+		switch side {
+		case "north":
+			arm.min[2], arm.max[2] = 0, lo
+		case "south":
+			arm.min[2], arm.max[2] = hi, 1
+		case "east":
+			arm.min[0], arm.max[0] = hi, 1
+		case "west":
+			arm.min[0], arm.max[0] = 0, lo
+		}
+		boxes = append(boxes, arm)
+	}
+	return boxes
+}
+
+// panel returns a full-height panel of the thickness passed against the side
+// of the block passed.
+func panel(side string, thickness float32) box {
+	b := fullBox
+	switch side {
+	case "north":
+		b.max[2] = thickness
+	case "south":
+		b.min[2] = 1 - thickness
+	case "east":
+		b.min[0] = 1 - thickness
+	default:
+		b.max[0] = thickness
+	}
+	return b
+}
+
+// opposite returns the horizontal direction opposite to the one passed.
+func opposite(facing string) string {
+	switch facing {
+	case "north":
+		return "south"
+	case "south":
+		return "north"
+	case "east":
+		return "west"
+	default:
+		return "east"
+	}
+}
+
+// plantNames are the names, or parts of names, of small plants.
+var plantNames = []string{
+	"sapling", "tulip", "orchid", "dandelion", "poppy", "allium", "azure_bluet", "oxeye_daisy",
+	"cornflower", "lily_of_the_valley", "wither_rose", "short_grass", "fern", "dead_bush",
+	"brown_mushroom", "red_mushroom", "crimson_fungus", "warped_fungus", "crimson_roots",
+	"warped_roots", "nether_sprouts", "sweet_berry_bush", "torchflower",
+}
+
+// isPlant reports whether a block is a small plant.
+func isPlant(path string) bool {
+	if strings.HasSuffix(path, "_block") || strings.HasSuffix(path, "_stem") {
+		return false
+	}
+	for _, name := range plantNames {
+		if strings.Contains(path, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,392 @@
+package render
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"sort"
+
+	"github.com/oriumgames/schem/format/internal/base"
+	"github.com/oriumgames/schem/format/internal/mesh"
+)
+
+// View selects how a schematic is rendered.
+type View int
+
+const (
+	// TopDown renders a map of the schematic seen from straight above, with
+	// blocks shaded by their height relative to the block north of them, as
+	// on in-game maps.
+	TopDown View = iota
+	// Isometric renders the schematic from the yaw and pitch of the options,
+	// with an orthographic projection.
+	Isometric
+)
+
+const (
+	defaultSize = 256
+	// DefaultYaw and DefaultPitch are the camera angles used when both are
+	// zero, looking down on the south-east corner.
+	DefaultYaw   = 135
+	DefaultPitch = 30
+)
+
+// Options configures how a schematic is rendered.
+type Options struct {
+	// View selects the top-down or isometric view.
+	View View
+	// Yaw and Pitch are the camera angles of the isometric view in degrees,
+	// as Axiom's ThumbnailYaw and ThumbnailPitch: a yaw of 0 looks south and
+	// grows clockwise, a positive pitch looks down. If both are zero,
+	// DefaultYaw and DefaultPitch are used.
+	Yaw, Pitch float32
+	// Size is the largest width or height of the image in pixels. If zero,
+	// 256 is used. Top-down images use a whole number of pixels per block, so
+	// they may be smaller, or sample the blocks of schematics wider or longer
+	// than Size.
+	Size int
+	// Colors maps blocks to colours. If nil, the default colour table is used.
+	Colors base.ColorTable
+	// Background is the colour of pixels showing no block. The zero value is
+	// transparent.
+	Background color.RGBA
+}
+
+func (opts Options) size() int {
+	if opts.Size <= 0 {
+		return defaultSize
+	}
+	return opts.Size
+}
+
+func (opts Options) colors() base.ColorTable {
+	if opts.Colors == nil {
+		return base.DefaultColors()
+	}
+	return opts.Colors
+}
+
+// Render renders a schematic into an image.
+func Render(s base.Schematic, opts Options) *image.RGBA {
+	if opts.View == Isometric {
+		return isometric(s, opts)
+	}
+	return topDown(s, opts)
+}
+
+// WritePNG renders a schematic and writes the image as a PNG.
+func WritePNG(w io.Writer, s base.Schematic, opts Options) error {
+	if err := png.Encode(w, Render(s, opts)); err != nil {
+		return fmt.Errorf("encode png: %w", err)
+	}
+	return nil
+}
+
+// topDown renders the top-down map view.
+func topDown(s base.Schematic, opts Options) *image.RGBA {
+	width, height, length := s.Dimensions()
+	colors := opts.colors()
+	cache := make(map[string]color.RGBA)
+	colorOf := func(state *base.BlockState) color.RGBA {
+		key := state.String()
+		c, ok := cache[key]
+		if !ok {
+			if c, ok = colors.Color(state); !ok {
+				c = base.UnknownColor
+			}
+			cache[key] = c
+		}
+		return c
+	}
+
+	// surface returns the colour of a column, blending translucent blocks
+	// over the blocks below them, and the height of its top block.
+	surface := func(x, z int) ([4]float64, int) {
+		var acc [4]float64
+		top := -1
+		for y := height - 1; y >= 0 && acc[3] < 1; y-- {
+			state := s.Block(x, y, z)
+			if state == nil || base.IsEmpty(state.Name) {
+				continue
+			}
+			if top < 0 {
+				top = y
+			}
+			c := colorOf(state)
+			a := float64(c.A) / 255 * (1 - acc[3])
+			acc[0] += float64(c.R) * a
+			acc[1] += float64(c.G) * a
+			acc[2] += float64(c.B) * a
+			acc[3] += a
+		}
+		return acc, top
+	}
+
+	tops := make([]int, width*length)
+	surfaces := make([][4]float64, width*length)
+	for z := range length {
+		for x := range width {
+			surfaces[z*width+x], tops[z*width+x] = surface(x, z)
+		}
+	}
+
+	bg := opts.Background
+	pixels := make([]color.RGBA, width*length)
+	for z := range length {
+		for x := range width {
+			acc, top := surfaces[z*width+x], tops[z*width+x]
+			c := bg
+			if top >= 0 {
+				shade := 0.86
+				if z > 0 && tops[(z-1)*width+x] >= 0 {
+					switch north := tops[(z-1)*width+x]; {
+					case top > north:
+						shade = 1
+					case top < north:
+						shade = 0.71
+					}
+				}
+				c = blend(bg, acc, shade)
+			}
+			pixels[z*width+x] = c
+		}
+	}
+
+	size, longest := opts.size(), max(width, length)
+	if longest > size && min(width, length) > 0 {
+		// Schematics larger than the image are sampled, taking the block
+		// nearest to the top left corner of every pixel.
+		imgWidth, imgLength := max(width*size/longest, 1), max(length*size/longest, 1)
+		img := image.NewRGBA(image.Rect(0, 0, imgWidth, imgLength))
+		for py := range imgLength {
+			for px := range imgWidth {
+				img.SetRGBA(px, py, pixels[py*length/imgLength*width+px*width/imgWidth])
+			}
+		}
+		return img
+	}
+	scale := size / max(longest, 1)
+	img := image.NewRGBA(image.Rect(0, 0, width*scale, length*scale))
+	for z := range length {
+		for x := range width {
+			for py := range scale {
+				for px := range scale {
+					img.SetRGBA(x*scale+px, z*scale+py, pixels[z*width+x])
+				}
+			}
+		}
+	}
+	return img
+}
+
+// blend composites a premultiplied colour, shaded by the factor passed, over
+// the background.
+func blend(bg color.RGBA, acc [4]float64, shade float64) color.RGBA {
+	bgA := float64(bg.A) / 255
+	outA := acc[3] + bgA*(1-acc[3])
+	if outA == 0 {
+		return color.RGBA{}
+	}
+	channel := func(v float64, b uint8) uint8 {
+		return clamp((v*shade + float64(b)*bgA*(1-acc[3])) / outA)
+	}
+	return color.RGBA{
+		R: channel(acc[0], bg.R),
+		G: channel(acc[1], bg.G),
+		B: channel(acc[2], bg.B),
+		A: clamp(outA * 255),
+	}
+}
+
+// camera projects world positions onto the image plane.
+type camera struct {
+	right, up, forward [3]float64
+}
+
+func newCamera(yawDeg, pitchDeg float64) camera {
+	yaw, pitch := yawDeg*math.Pi/180, pitchDeg*math.Pi/180
+	f := [3]float64{-math.Sin(yaw) * math.Cos(pitch), -math.Sin(pitch), math.Cos(yaw) * math.Cos(pitch)}
+	r := normalize([3]float64{-f[2], 0, f[0]})
+	u := cross(r, f)
+	return camera{right: r, up: u, forward: f}
+}
+
+// project returns the screen position and depth of a point. Smaller depths
+// are closer to the camera.
+func (c camera) project(p [3]float64) (x, y, depth float64) {
+	return dot(p, c.right), dot(p, c.up), dot(p, c.forward)
+}
+
+// triangle is a projected triangle in pixel space.
+type triangle struct {
+	x, y, z [3]float64
+	color   color.RGBA
+}
+
+// isometric renders the isometric view.
+func isometric(s base.Schematic, opts Options) *image.RGBA {
+	size := opts.size()
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	if opts.Background.A > 0 {
+		for i := 0; i < len(img.Pix); i += 4 {
+			img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = opts.Background.R, opts.Background.G, opts.Background.B, opts.Background.A
+		}
+	}
+
+	yaw, pitch := float64(opts.Yaw), float64(opts.Pitch)
+	if yaw == 0 && pitch == 0 {
+		yaw, pitch = DefaultYaw, DefaultPitch
+	}
+	cam := newCamera(yaw, pitch)
+
+	// Fit the bounding box of the schematic into the image.
+	width, height, length := s.Dimensions()
+	minX, minY, maxX, maxY := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for _, corner := range [8][3]float64{
+		{0, 0, 0}, {float64(width), 0, 0}, {0, float64(height), 0}, {0, 0, float64(length)},
+		{float64(width), float64(height), 0}, {float64(width), 0, float64(length)},
+		{0, float64(height), float64(length)}, {float64(width), float64(height), float64(length)},
+	} {
+		x, y, _ := cam.project(corner)
+		minX, maxX = min(minX, x), max(maxX, x)
+		minY, maxY = min(minY, y), max(maxY, y)
+	}
+	margin := float64(size) / 32
+	scale := (float64(size) - 2*margin) / max(maxX-minX, maxY-minY, 1e-9)
+	offX := (float64(size) - (maxX-minX)*scale) / 2
+	offY := (float64(size) - (maxY-minY)*scale) / 2
+
+	var opaque, translucent []triangle
+	for _, q := range mesh.Quads(s, opts.colors()) {
+		normal := [3]float64{float64(q.Normal[0]), float64(q.Normal[1]), float64(q.Normal[2])}
+		if dot(normal, cam.forward) >= 0 {
+			// The face points away from the camera.
+			continue
+		}
+		c := shadeColor(q.Color, q.Normal)
+		var pts [4][3]float64
+		for i, corner := range q.Corners {
+			x, y, z := cam.project([3]float64{float64(corner[0]), float64(corner[1]), float64(corner[2])})
+			pts[i] = [3]float64{offX + (x-minX)*scale, offY + (maxY-y)*scale, z}
+		}
+		for _, idx := range [2][3]int{{0, 1, 2}, {0, 2, 3}} {
+			t := triangle{color: c}
+			for i, k := range idx {
+				t.x[i], t.y[i], t.z[i] = pts[k][0], pts[k][1], pts[k][2]
+			}
+			if c.A == 255 {
+				opaque = append(opaque, t)
+			} else {
+				translucent = append(translucent, t)
+			}
+		}
+	}
+
+	depth := make([]float64, size*size)
+	for i := range depth {
+		depth[i] = math.Inf(1)
+	}
+	for _, t := range opaque {
+		rasterize(img, depth, t, true)
+	}
+	// Translucent triangles are blended back to front, behind opaque ones.
+	sort.SliceStable(translucent, func(i, j int) bool {
+		return translucent[i].z[0]+translucent[i].z[1]+translucent[i].z[2] > translucent[j].z[0]+translucent[j].z[1]+translucent[j].z[2]
+	})
+	for _, t := range translucent {
+		rasterize(img, depth, t, false)
+	}
+	return img
+}
+
+// rasterize draws a triangle, testing every pixel against the depth buffer.
+// Opaque triangles write to the depth buffer, others are blended.
+func rasterize(img *image.RGBA, depth []float64, t triangle, opaque bool) {
+	size := img.Bounds().Dx()
+	x0 := max(int(math.Floor(min(t.x[0], t.x[1], t.x[2]))), 0)
+	x1 := min(int(math.Ceil(max(t.x[0], t.x[1], t.x[2]))), size-1)
+	y0 := max(int(math.Floor(min(t.y[0], t.y[1], t.y[2]))), 0)
+	y1 := min(int(math.Ceil(max(t.y[0], t.y[1], t.y[2]))), size-1)
+	area := edge(t.x[0], t.y[0], t.x[1], t.y[1], t.x[2], t.y[2])
+	if area == 0 {
+		return
+	}
+	for py := y0; py <= y1; py++ {
+		for px := x0; px <= x1; px++ {
+			cx, cy := float64(px)+0.5, float64(py)+0.5
+			w0 := edge(t.x[1], t.y[1], t.x[2], t.y[2], cx, cy) / area
+			w1 := edge(t.x[2], t.y[2], t.x[0], t.y[0], cx, cy) / area
+			w2 := 1 - w0 - w1
+			const eps = -1e-9
+			if w0 < eps || w1 < eps || w2 < eps {
+				continue
+			}
+			z := w0*t.z[0] + w1*t.z[1] + w2*t.z[2]
+			i := py*size + px
+			if z >= depth[i]-1e-6 {
+				continue
+			}
+			if opaque {
+				depth[i] = z
+				img.SetRGBA(px, py, t.color)
+				continue
+			}
+			img.SetRGBA(px, py, over(t.color, img.RGBAAt(px, py)))
+		}
+	}
+}
+
+// over composites the colour src over dst.
+func over(src, dst color.RGBA) color.RGBA {
+	sa, da := float64(src.A)/255, float64(dst.A)/255
+	outA := sa + da*(1-sa)
+	if outA == 0 {
+		return color.RGBA{}
+	}
+	channel := func(s, d uint8) uint8 {
+		return clamp((float64(s)*sa + float64(d)*da*(1-sa)) / outA)
+	}
+	return color.RGBA{R: channel(src.R, dst.R), G: channel(src.G, dst.G), B: channel(src.B, dst.B), A: clamp(outA * 255)}
+}
+
+// shadeColor darkens a colour depending on the direction of the face, as the
+// game does: top faces are brightest and bottom faces darkest.
+func shadeColor(c color.RGBA, normal [3]int) color.RGBA {
+	shade := 1.0
+	switch {
+	case normal[1] < 0:
+		shade = 0.5
+	case normal[0] != 0:
+		shade = 0.6
+	case normal[2] != 0:
+		shade = 0.8
+	}
+	return color.RGBA{R: clamp(float64(c.R) * shade), G: clamp(float64(c.G) * shade), B: clamp(float64(c.B) * shade), A: c.A}
+}
+
+func edge(ax, ay, bx, by, cx, cy float64) float64 {
+	return (bx-ax)*(cy-ay) - (by-ay)*(cx-ax)
+}
+
+func clamp(v float64) uint8 {
+	return uint8(math.Round(min(max(v, 0), 255)))
+}
+
+func dot(a, b [3]float64) float64 {
+	return a[0]*b[0] + a[1]*b[1] + a[2]*b[2]
+}
+
+func cross(a, b [3]float64) [3]float64 {
+	return [3]float64{a[1]*b[2] - a[2]*b[1], a[2]*b[0] - a[0]*b[2], a[0]*b[1] - a[1]*b[0]}
+}
+
+func normalize(v [3]float64) [3]float64 {
+	l := math.Sqrt(dot(v, v))
+	if l == 0 {
+		return [3]float64{1, 0, 0}
+	}
+	return [3]float64{v[0] / l, v[1] / l, v[2] / l}
+}
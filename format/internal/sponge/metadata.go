@@ -0,0 +1,58 @@
+package sponge
+
+import (
+	"github.com/oriumgames/schem/format/internal/base"
+)
+
+// readMetadataCompound fills the Info of s from the free-form Metadata
+// compound of v1 and v2 schematics. Keys not defined by the specification are
+// kept as extra tags of the "metadata" scope.
+func readMetadataCompound(s base.Schematic, meta map[string]any) {
+	var info base.Info
+	extra := make(map[string]any)
+	for k, v := range meta {
+		switch k {
+		case "Name":
+			info.Name, _ = v.(string)
+		case "Author":
+			info.Author, _ = v.(string)
+		case "Date":
+			if date, ok := v.(int64); ok {
+				info.Created = base.TimeFromMillis(date)
+			}
+		case "RequiredMods":
+			info.RequiredMods = base.StringList(v)
+		default:
+			extra[k] = v
+		}
+	}
+	s.SetInfo(info)
+	base.SetExtra(s, "metadata", extra)
+}
+
+// metadataCompound builds the free-form Metadata compound of v1 and v2
+// schematics from the Info of s and the extra tags kept when s was read in the
+// format passed.
+func metadataCompound(s base.Schematic, formatID string) map[string]any {
+	meta := base.Extra(s, formatID, "metadata")
+	if meta == nil {
+		meta = make(map[string]any)
+	}
+	info := s.Info()
+	if info.Name != "" {
+		meta["Name"] = info.Name
+	}
+	if info.Author != "" {
+		meta["Author"] = info.Author
+	}
+	if !info.Created.IsZero() {
+		meta["Date"] = base.Millis(info.Created)
+	}
+	if len(info.RequiredMods) > 0 {
+		meta["RequiredMods"] = info.RequiredMods
+	}
+	if len(meta) == 0 {
+		return nil
+	}
+	return meta
+}
@@ -0,0 +1,127 @@
+package sponge
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/oriumgames/schem/format/internal/base"
+)
+
+// BiomeLayout selects how biomes are stored in a Sponge schematic.
+type BiomeLayout int
+
+const (
+	// BiomesDefault stores biomes in 2D for v2 and in 3D for v3 schematics.
+	BiomesDefault BiomeLayout = iota
+	// Biomes3D stores a biome for every block. It is only supported by v3.
+	Biomes3D
+	// Biomes2D stores a single biome per column, chosen by the BiomeSampling
+	// of the Options. In v3 schematics, the biome is repeated for every block
+	// of the column.
+	Biomes2D
+	// BiomesNone leaves out biomes altogether.
+	BiomesNone
+)
+
+// BiomeSampling selects the biome of a column when writing 2D biomes.
+type BiomeSampling int
+
+const (
+	// SampleBottom uses the lowest biome of the column.
+	SampleBottom BiomeSampling = iota
+	// SampleTop uses the highest biome of the column.
+	SampleTop
+	// SampleMostCommon uses the biome found most often in the column. Ties are
+	// resolved in favour of the biome that reached the count first, scanning
+	// from the bottom up.
+	SampleMostCommon
+)
+
+// PaletteOrder selects the order of the block and biome palettes.
+type PaletteOrder int
+
+const (
+	// PaletteFirstAppearance orders palette entries by their first appearance,
+	// iterating in Y, Z, X order, with air first for block palettes.
+	PaletteFirstAppearance PaletteOrder = iota
+	// PaletteSorted orders palette entries lexicographically.
+	PaletteSorted
+)
+
+// Options configures how a schematic is written as a Sponge schematic. The
+// zero value writes a v3 schematic with 3D biomes.
+type Options struct {
+	// Version is the schematic version written: 1, 2 or 3. Zero defaults
+	// to 3.
+	Version int
+	// Biomes is the layout biomes are stored in.
+	Biomes BiomeLayout
+	// BiomeSampling is the rule used to choose the biome of a column when
+	// biomes are stored in 2D.
+	BiomeSampling BiomeSampling
+	// PaletteOrder is the order of the block and biome palettes.
+	PaletteOrder PaletteOrder
+	// SkipEntities leaves out the entities of the schematic.
+	SkipEntities bool
+	// SkipBlockEntities leaves out the block entities of the schematic.
+	SkipBlockEntities bool
+	// CompressionLevel is the gzip compression level, as defined by the
+	// compress/gzip package. Zero uses gzip.DefaultCompression.
+	CompressionLevel int
+}
+
+// Write writes a schematic as a Sponge schematic according to the options
+// passed.
+func Write(w io.Writer, s base.Schematic, opts Options) error {
+	switch opts.Version {
+	case 1:
+		if opts.Biomes == Biomes2D || opts.Biomes == Biomes3D {
+			return fmt.Errorf("version 1 does not store biomes")
+		}
+		return writeV1(w, s, opts)
+	case 2:
+		if opts.Biomes == Biomes3D {
+			return fmt.Errorf("version 2 does not support 3D biomes")
+		}
+		return writeV2(w, s, opts)
+	case 0, 3:
+		return writeV3(w, s, opts)
+	default:
+		return fmt.Errorf("unsupported version %d", opts.Version)
+	}
+}
+
+// columnBiome returns the biome of the column at x, z according to the
+// sampling rule passed, or an empty string if the column has no biome.
+func columnBiome(s base.Schematic, x, z int, sampling BiomeSampling) string {
+	_, height, _ := s.Dimensions()
+	switch sampling {
+	case SampleTop:
+		for y := height - 1; y >= 0; y-- {
+			if biome := s.Biome(x, y, z); biome != "" {
+				return biome
+			}
+		}
+	case SampleMostCommon:
+		counts := make(map[string]int)
+		best := ""
+		for y := range height {
+			biome := s.Biome(x, y, z)
+			if biome == "" {
+				continue
+			}
+			counts[biome]++
+			if counts[biome] > counts[best] {
+				best = biome
+			}
+		}
+		return best
+	default:
+		for y := range height {
+			if biome := s.Biome(x, y, z); biome != "" {
+				return biome
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,201 @@
+package sponge
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"strings"
+
+	"github.com/oriumgames/schem/format/internal/base"
+)
+
+// maxDimension is the largest width, height or length a Sponge schematic can
+// hold. Dimensions are stored as unsigned shorts in a signed TAG_Short.
+const maxDimension = math.MaxUint16
+
+// readDimensions converts the stored dimensions of a schematic, which are
+// unsigned shorts, to ints.
+func readDimensions(w, h, l int16) (width, height, length int, err error) {
+	width, height, length = int(uint16(w)), int(uint16(h)), int(uint16(l))
+	if width == 0 || height == 0 || length == 0 {
+		return 0, 0, 0, fmt.Errorf("invalid dimensions: %dx%dx%d", width, height, length)
+	}
+	return width, height, length, nil
+}
+
+// writeDimensions converts the dimensions of s to the unsigned shorts stored in
+// a schematic. An error is returned if they do not fit.
+func writeDimensions(s base.Schematic) (w, h, l int16, err error) {
+	width, height, length := s.Dimensions()
+	if width <= 0 || height <= 0 || length <= 0 || width > maxDimension || height > maxDimension || length > maxDimension {
+		return 0, 0, 0, fmt.Errorf("dimensions %dx%dx%d out of range: must be between 1 and %d", width, height, length, maxDimension)
+	}
+	return int16(uint16(width)), int16(uint16(height)), int16(uint16(length)), nil
+}
+
+// readDataVersion validates the DataVersion of a v2 or v3 schematic, which is
+// required by the specification.
+func readDataVersion(v int32) (int, error) {
+	if v <= 0 {
+		return 0, fmt.Errorf("missing or invalid DataVersion %d", v)
+	}
+	return int(v), nil
+}
+
+// writeDataVersion returns the DataVersion of s for a v2 or v3 schematic. An
+// error is returned if s does not have one, as the block states could not be
+// interpreted when reading the schematic.
+func writeDataVersion(s base.Schematic) (int32, error) {
+	v := s.DataVersion()
+	if v <= 0 || v > math.MaxInt32 {
+		return 0, fmt.Errorf("schematic has no valid DataVersion (%d)", v)
+	}
+	return int32(v), nil
+}
+
+// readPalette converts a palette mapping block state strings to indices into
+// a lookup by index. PaletteMax is not relied upon, as it is optional and
+// often inaccurate.
+func readPalette(entries map[string]int32) map[int]*base.BlockState {
+	palette := make(map[int]*base.BlockState, len(entries))
+	for state, idx := range entries {
+		palette[int(idx)] = base.ParseBlockState(state)
+	}
+	return palette
+}
+
+// intTriple converts a decoded TAG_Int_Array or list of ints of length 3, such
+// as a block entity position, to three ints.
+func intTriple(v any) (x, y, z int, ok bool) {
+	switch pos := v.(type) {
+	case [3]int32:
+		return int(pos[0]), int(pos[1]), int(pos[2]), true
+	case []int32:
+		if len(pos) == 3 {
+			return int(pos[0]), int(pos[1]), int(pos[2]), true
+		}
+	case []any:
+		if len(pos) == 3 {
+			x, okX := pos[0].(int32)
+			y, okY := pos[1].(int32)
+			z, okZ := pos[2].(int32)
+			return int(x), int(y), int(z), okX && okY && okZ
+		}
+	}
+	return 0, 0, 0, false
+}
+
+// readBlockEntity converts a block entity compound of a schematic. The
+// position is stored in Pos and the ID in Id, as defined by the specification.
+func readBlockEntity(data map[string]any) (*base.BlockEntity, error) {
+	x, y, z, ok := intTriple(data["Pos"])
+	if !ok {
+		return nil, fmt.Errorf("block entity has invalid Pos %v", data["Pos"])
+	}
+	be := &base.BlockEntity{X: x, Y: y, Z: z, Data: make(map[string]any, len(data))}
+	be.ID, _ = data["Id"].(string)
+	for k, v := range data {
+		if k != "Pos" && k != "Id" {
+			be.Data[k] = v
+		}
+	}
+	return be, nil
+}
+
+// Metadata keys of the WorldEdit compound found in the Metadata of v3
+// schematics.
+const (
+	// worldEditVersionKey holds the WorldEdit version as a string.
+	worldEditVersionKey = "WorldEditVersion"
+	// worldEditPlatformKey holds the platform the schematic was saved on.
+	worldEditPlatformKey = "WorldEditPlatform"
+	// worldEditOriginKey holds the world position the schematic was copied
+	// from as a [3]int.
+	worldEditOriginKey = "WorldEditOrigin"
+	// worldEditPlatformsKey holds the versions of the WorldEdit platforms
+	// present, keyed by platform name, as a map[string]string.
+	worldEditPlatformsKey = "WorldEditPlatforms"
+)
+
+// readWorldEdit records the WorldEdit compound of the metadata of a v3
+// schematic in the metadata of s. The compound itself is kept as an extra tag,
+// so that it is written back in full.
+func readWorldEdit(s base.Schematic, meta map[string]any) {
+	we, ok := meta["WorldEdit"].(map[string]any)
+	if !ok {
+		return
+	}
+	version, _ := we["Version"].(string)
+	if version != "" {
+		s.SetMetadata(worldEditVersionKey, version)
+	}
+	if platform, ok := we["EditingPlatform"].(string); ok {
+		s.SetMetadata(worldEditPlatformKey, platform)
+	}
+	if x, y, z, ok := intTriple(we["Origin"]); ok {
+		s.SetMetadata(worldEditOriginKey, [3]int{x, y, z})
+	}
+	if platforms, ok := we["Platforms"].(map[string]any); ok {
+		versions := make(map[string]string, len(platforms))
+		for name, p := range platforms {
+			platform, _ := p.(map[string]any)
+			versions[name], _ = platform["Version"].(string)
+		}
+		s.SetMetadata(worldEditPlatformsKey, versions)
+	}
+
+	info := s.Info()
+	if info.Tool == "" {
+		info.Tool = strings.TrimSpace("WorldEdit " + version)
+		s.SetInfo(info)
+	}
+}
+
+// writeWorldEdit updates the WorldEdit compound in the metadata compound
+// passed from the metadata of s, if s holds WorldEdit metadata.
+func writeWorldEdit(s base.Schematic, meta map[string]any) map[string]any {
+	m := s.Metadata()
+	we, _ := meta["WorldEdit"].(map[string]any)
+	we = cloneCompound(we)
+
+	if version, ok := m[worldEditVersionKey].(string); ok {
+		we["Version"] = version
+	}
+	if platform, ok := m[worldEditPlatformKey].(string); ok {
+		we["EditingPlatform"] = platform
+	}
+	if origin, ok := m[worldEditOriginKey].([3]int); ok {
+		we["Origin"] = [3]int32{int32(origin[0]), int32(origin[1]), int32(origin[2])}
+	}
+	if versions, ok := m[worldEditPlatformsKey].(map[string]string); ok {
+		platforms, _ := we["Platforms"].(map[string]any)
+		platforms = cloneCompound(platforms)
+		for name, version := range versions {
+			platform, _ := platforms[name].(map[string]any)
+			platform = cloneCompound(platform)
+			if _, ok := platform["Name"]; !ok {
+				platform["Name"] = name
+			}
+			platform["Version"] = version
+			platforms[name] = platform
+		}
+		we["Platforms"] = platforms
+	}
+
+	if len(we) == 0 {
+		return meta
+	}
+	if meta == nil {
+		meta = make(map[string]any)
+	}
+	meta["WorldEdit"] = we
+	return meta
+}
+
+// cloneCompound returns a shallow copy of a compound, or an empty compound if
+// it is nil.
+func cloneCompound(c map[string]any) map[string]any {
+	out := make(map[string]any, len(c))
+	maps.Copy(out, c)
+	return out
+}
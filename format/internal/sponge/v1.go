@@ -1,7 +1,6 @@
 package sponge
 
 import (
-	"bytes"
 	"compress/gzip"
 	"fmt"
 	"io"
@@ -14,15 +13,15 @@ import (
 // v1NBT is the NBT structure for Sponge Schematic Version 1
 type v1NBT struct {
 	Version      int32            `nbt:"Version"`
-	DataVersion  int32            `nbt:"DataVersion"`
+	DataVersion  int32            `nbt:"DataVersion,omitempty"` // Not part of the v1 specification
 	Width        int16            `nbt:"Width"`
 	Height       int16            `nbt:"Height"`
 	Length       int16            `nbt:"Length"`
-	Offset       []int32          `nbt:"Offset,omitempty"`
+	Offset       []int32          `nbt:"Offset,array,omitempty"`
 	Metadata     map[string]any   `nbt:"Metadata,omitempty"`
 	PaletteMax   int32            `nbt:"PaletteMax"`
 	Palette      map[string]int32 `nbt:"Palette"`
-	BlockData    []byte           `nbt:"BlockData"`
+	BlockData    []byte           `nbt:"BlockData,array"`
 	TileEntities []map[string]any `nbt:"TileEntities,omitempty"`
 	Extra        map[string]any   `nbt:"*"`
 }
@@ -47,9 +46,9 @@ func ReadV1(r io.Reader) (base.Schematic, error) {
 	}
 
 	// Validate dimensions
-	width, height, length := int(data.Width), int(data.Height), int(data.Length)
-	if width <= 0 || height <= 0 || length <= 0 {
-		return nil, fmt.Errorf("invalid dimensions: %dx%dx%d", width, height, length)
+	width, height, length, err := readDimensions(data.Width, data.Height, data.Length)
+	if err != nil {
+		return nil, err
 	}
 
 	// Create schematic
@@ -62,36 +61,30 @@ func ReadV1(r io.Reader) (base.Schematic, error) {
 	}
 
 	// Set metadata
-	for k, v := range data.Metadata {
-		s.SetMetadata(k, v)
-	}
+	readMetadataCompound(s, data.Metadata)
+	base.SetExtra(s, "root", data.Extra)
 
 	// Build palette (inverted: string -> index)
-	palette := make([]*base.BlockState, data.PaletteMax+1)
-	for blockName, idx := range data.Palette {
-		if int(idx) < len(palette) {
-			palette[idx] = base.ParseBlockState(blockName)
-		}
-	}
+	palette := readPalette(data.Palette)
 
 	// Decode blocks
-	blockCount := int(data.Width) * int(data.Height) * int(data.Length)
+	blockCount := width * height * length
 	blockIndices, err := base.DecodeVarIntArray(data.BlockData, blockCount)
 	if err != nil {
 		return nil, fmt.Errorf("decode block data: %w", err)
 	}
 
 	// Set blocks
-	for y := 0; y < int(data.Height); y++ {
-		for z := 0; z < int(data.Length); z++ {
-			for x := 0; x < int(data.Width); x++ {
-				idx := x + z*int(data.Width) + y*int(data.Width)*int(data.Length)
+	for y := 0; y < height; y++ {
+		for z := 0; z < length; z++ {
+			for x := 0; x < width; x++ {
+				idx := x + z*width + y*width*length
 				if idx >= len(blockIndices) {
 					continue
 				}
 				paletteIdx := blockIndices[idx]
-				if paletteIdx >= 0 && paletteIdx < len(palette) && palette[paletteIdx] != nil {
-					s.SetBlock(x, y, z, palette[paletteIdx].Clone())
+				if state := palette[paletteIdx]; state != nil {
+					s.SetBlock(x, y, z, state.Clone())
 				}
 			}
 		}
@@ -99,27 +92,9 @@ func ReadV1(r io.Reader) (base.Schematic, error) {
 
 	// Set tile entities (v1 uses "TileEntities" not "BlockEntities")
 	for _, teData := range data.TileEntities {
-		be := &base.BlockEntity{
-			Data: make(map[string]any),
-		}
-
-		// Extract position
-		if pos, ok := teData["Pos"].([3]int32); ok {
-			be.X = int(pos[0])
-			be.Y = int(pos[1])
-			be.Z = int(pos[2])
-		}
-
-		// Extract ID
-		if id, ok := teData["Id"].(string); ok {
-			be.ID = id
-		}
-
-		// Copy remaining data
-		for k, v := range teData {
-			if k != "Pos" && k != "Id" {
-				be.Data[k] = v
-			}
+		be, err := readBlockEntity(teData)
+		if err != nil {
+			return nil, err
 		}
 
 		s.SetBlockEntity(be.X, be.Y, be.Z, be)
@@ -130,6 +105,16 @@ func ReadV1(r io.Reader) (base.Schematic, error) {
 
 // WriteV1 writes a schematic as Sponge Schematic v1.
 func WriteV1(w io.Writer, s base.Schematic) error {
+	return writeV1(w, s, Options{Version: 1})
+}
+
+// writeV1 writes a schematic as Sponge Schematic v1 according to the
+// options passed.
+func writeV1(w io.Writer, s base.Schematic, opts Options) error {
+	w16, h16, l16, err := writeDimensions(s)
+	if err != nil {
+		return err
+	}
 	width, height, length := s.Dimensions()
 	offsetX, offsetY, offsetZ := s.Offset()
 
@@ -151,6 +136,10 @@ func WriteV1(w io.Writer, s base.Schematic) error {
 		}
 	}
 
+	if opts.PaletteOrder == PaletteSorted {
+		palette.Sort(blockIndices)
+	}
+
 	// Build palette map
 	paletteMap := make(map[string]int32)
 	for i, block := range palette.Blocks() {
@@ -161,49 +150,37 @@ func WriteV1(w io.Writer, s base.Schematic) error {
 	data := v1NBT{
 		Version:     1,
 		DataVersion: int32(s.DataVersion()),
-		Width:       int16(width),
-		Height:      int16(height),
-		Length:      int16(length),
+		Width:       w16,
+		Height:      h16,
+		Length:      l16,
 		Offset:      []int32{int32(offsetX), int32(offsetY), int32(offsetZ)},
 		PaletteMax:  int32(palette.Size() - 1),
 		Palette:     paletteMap,
 		BlockData:   base.EncodeVarIntArray(blockIndices),
-		Metadata:    s.Metadata(),
+		Metadata:    metadataCompound(s, "sponge_v1"),
+		Extra:       base.Extra(s, "sponge_v1", "root"),
 	}
 
 	// Encode tile entities
-	for y := range height {
-		for z := range length {
-			for x := range width {
-				be := s.BlockEntity(x, y, z)
-				if be == nil {
-					continue
+	if !opts.SkipBlockEntities {
+		for y := range height {
+			for z := range length {
+				for x := range width {
+					be := s.BlockEntity(x, y, z)
+					if be == nil {
+						continue
+					}
+
+					teData := make(map[string]any)
+					teData["Pos"] = [3]int32{int32(x), int32(y), int32(z)}
+					teData["Id"] = be.ID
+					maps.Copy(teData, be.Data)
+					data.TileEntities = append(data.TileEntities, teData)
 				}
-
-				teData := make(map[string]any)
-				teData["Pos"] = []int32{int32(x), int32(y), int32(z)}
-				teData["Id"] = be.ID
-				maps.Copy(teData, be.Data)
-				data.TileEntities = append(data.TileEntities, teData)
 			}
 		}
 	}
 
-	// Wrap in root tag
-	root := struct {
-		Schematic v1NBT `nbt:"Schematic"`
-	}{Schematic: data}
-
 	// Compress and write
-	var buf bytes.Buffer
-	gz := gzip.NewWriter(&buf)
-	if err := nbt.NewEncoderWithEncoding(gz, nbt.BigEndian).Encode(root); err != nil {
-		return fmt.Errorf("encode nbt: %w", err)
-	}
-	if err := gz.Close(); err != nil {
-		return fmt.Errorf("close gzip: %w", err)
-	}
-
-	_, err := w.Write(buf.Bytes())
-	return err
+	return base.WriteGzipNBT(w, data, opts.CompressionLevel)
 }
@@ -1,7 +1,6 @@
 package sponge
 
 import (
-	"bytes"
 	"compress/gzip"
 	"fmt"
 	"io"
@@ -50,14 +49,18 @@ func ReadV2(r io.Reader) (base.Schematic, error) {
 	}
 
 	// Validate dimensions
-	width, height, length := int(data.Width), int(data.Height), int(data.Length)
-	if width <= 0 || height <= 0 || length <= 0 {
-		return nil, fmt.Errorf("invalid dimensions: %dx%dx%d", width, height, length)
+	width, height, length, err := readDimensions(data.Width, data.Height, data.Length)
+	if err != nil {
+		return nil, err
 	}
 
 	// Create schematic
 	s := base.New(width, height, length, "sponge_v2")
-	s.SetDataVersion(int(data.DataVersion))
+	dataVersion, err := readDataVersion(data.DataVersion)
+	if err != nil {
+		return nil, err
+	}
+	s.SetDataVersion(dataVersion)
 
 	// Set offset
 	if len(data.Offset) >= 3 {
@@ -65,36 +68,30 @@ func ReadV2(r io.Reader) (base.Schematic, error) {
 	}
 
 	// Set metadata
-	for k, v := range data.Metadata {
-		s.SetMetadata(k, v)
-	}
+	readMetadataCompound(s, data.Metadata)
+	base.SetExtra(s, "root", data.Extra)
 
 	// Build palette
-	palette := make([]*base.BlockState, data.PaletteMax+1)
-	for blockName, idx := range data.Palette {
-		if int(idx) < len(palette) {
-			palette[idx] = base.ParseBlockState(blockName)
-		}
-	}
+	palette := readPalette(data.Palette)
 
 	// Decode blocks
-	blockCount := int(data.Width) * int(data.Height) * int(data.Length)
+	blockCount := width * height * length
 	blockIndices, err := base.DecodeVarIntArray(data.BlockData, blockCount)
 	if err != nil {
 		return nil, fmt.Errorf("decode block data: %w", err)
 	}
 
 	// Set blocks
-	for y := 0; y < int(data.Height); y++ {
-		for z := 0; z < int(data.Length); z++ {
-			for x := 0; x < int(data.Width); x++ {
-				idx := x + z*int(data.Width) + y*int(data.Width)*int(data.Length)
+	for y := 0; y < height; y++ {
+		for z := 0; z < length; z++ {
+			for x := 0; x < width; x++ {
+				idx := x + z*width + y*width*length
 				if idx >= len(blockIndices) {
 					continue
 				}
 				paletteIdx := blockIndices[idx]
-				if paletteIdx >= 0 && paletteIdx < len(palette) && palette[paletteIdx] != nil {
-					s.SetBlock(x, y, z, palette[paletteIdx].Clone())
+				if state := palette[paletteIdx]; state != nil {
+					s.SetBlock(x, y, z, state.Clone())
 				}
 			}
 		}
@@ -102,27 +99,9 @@ func ReadV2(r io.Reader) (base.Schematic, error) {
 
 	// Set block entities
 	for _, beData := range data.BlockEntities {
-		be := &base.BlockEntity{
-			Data: make(map[string]any),
-		}
-
-		// Extract position
-		if pos, ok := beData["Pos"].([3]int32); ok {
-			be.X = int(pos[0])
-			be.Y = int(pos[1])
-			be.Z = int(pos[2])
-		}
-
-		// Extract ID
-		if id, ok := beData["Id"].(string); ok {
-			be.ID = id
-		}
-
-		// Copy remaining data
-		for k, v := range beData {
-			if k != "Pos" && k != "Id" {
-				be.Data[k] = v
-			}
+		be, err := readBlockEntity(beData)
+		if err != nil {
+			return nil, err
 		}
 
 		s.SetBlockEntity(be.X, be.Y, be.Z, be)
@@ -130,28 +109,25 @@ func ReadV2(r io.Reader) (base.Schematic, error) {
 
 	// Decode biomes (2D in v2)
 	if len(data.BiomeData) > 0 {
-		biomePalette := make([]string, data.BiomePaletteMax+1)
+		biomePalette := make(map[int]string, len(data.BiomePalette))
 		for biomeName, idx := range data.BiomePalette {
-			if int(idx) < len(biomePalette) {
-				biomePalette[idx] = biomeName
-			}
+			biomePalette[int(idx)] = biomeName
 		}
 
-		biomeCount := int(data.Width) * int(data.Length)
+		biomeCount := width * length
 		biomeIndices, err := base.DecodeVarIntArray(data.BiomeData, biomeCount)
 		if err != nil {
 			return nil, fmt.Errorf("decode biome data: %w", err)
 		}
 
-		for z := 0; z < int(data.Length); z++ {
-			for x := 0; x < int(data.Width); x++ {
-				idx := x + z*int(data.Width)
+		for z := 0; z < length; z++ {
+			for x := 0; x < width; x++ {
+				idx := x + z*width
 				if idx >= len(biomeIndices) {
 					continue
 				}
-				biomeIdx := biomeIndices[idx]
-				if biomeIdx >= 0 && biomeIdx < len(biomePalette) {
-					s.SetBiome(x, 0, z, biomePalette[biomeIdx])
+				if biome, ok := biomePalette[biomeIndices[idx]]; ok {
+					s.SetBiome(x, 0, z, biome)
 				}
 			}
 		}
@@ -203,6 +179,20 @@ func ReadV2(r io.Reader) (base.Schematic, error) {
 
 // WriteV2 writes a schematic as Sponge Schematic v2.
 func WriteV2(w io.Writer, s base.Schematic) error {
+	return writeV2(w, s, Options{Version: 2})
+}
+
+// writeV2 writes a schematic as Sponge Schematic v2 according to the
+// options passed.
+func writeV2(w io.Writer, s base.Schematic, opts Options) error {
+	w16, h16, l16, err := writeDimensions(s)
+	if err != nil {
+		return err
+	}
+	dataVersion, err := writeDataVersion(s)
+	if err != nil {
+		return err
+	}
 	width, height, length := s.Dimensions()
 	offsetX, offsetY, offsetZ := s.Offset()
 
@@ -224,6 +214,10 @@ func WriteV2(w io.Writer, s base.Schematic) error {
 		}
 	}
 
+	if opts.PaletteOrder == PaletteSorted {
+		palette.Sort(blockIndices)
+	}
+
 	// Build palette map
 	paletteMap := make(map[string]int32)
 	for i, block := range palette.Blocks() {
@@ -233,31 +227,34 @@ func WriteV2(w io.Writer, s base.Schematic) error {
 	// Build NBT structure
 	data := v2NBT{
 		Version:     2,
-		DataVersion: int32(s.DataVersion()),
-		Width:       int16(width),
-		Height:      int16(height),
-		Length:      int16(length),
+		DataVersion: dataVersion,
+		Width:       w16,
+		Height:      h16,
+		Length:      l16,
 		Offset:      []int32{int32(offsetX), int32(offsetY), int32(offsetZ)},
 		PaletteMax:  int32(palette.Size() - 1),
 		Palette:     paletteMap,
 		BlockData:   base.EncodeVarIntArray(blockIndices),
-		Metadata:    s.Metadata(),
+		Metadata:    metadataCompound(s, "sponge_v2"),
+		Extra:       base.Extra(s, "sponge_v2", "root"),
 	}
 
 	// Encode block entities
-	for y := range height {
-		for z := range length {
-			for x := range width {
-				be := s.BlockEntity(x, y, z)
-				if be == nil {
-					continue
+	if !opts.SkipBlockEntities {
+		for y := range height {
+			for z := range length {
+				for x := range width {
+					be := s.BlockEntity(x, y, z)
+					if be == nil {
+						continue
+					}
+
+					beData := make(map[string]any)
+					beData["Pos"] = [3]int32{int32(x), int32(y), int32(z)}
+					beData["Id"] = be.ID
+					maps.Copy(beData, be.Data)
+					data.BlockEntities = append(data.BlockEntities, beData)
 				}
-
-				beData := make(map[string]any)
-				beData["Pos"] = []int32{int32(x), int32(y), int32(z)}
-				beData["Id"] = be.ID
-				maps.Copy(beData, be.Data)
-				data.BlockEntities = append(data.BlockEntities, beData)
 			}
 		}
 	}
@@ -267,18 +264,23 @@ func WriteV2(w io.Writer, s base.Schematic) error {
 	biomeIndices := make([]int, width*length)
 	hasBiomes := false
 
-	for z := range length {
-		for x := range width {
-			idx := x + z*width
-			biome := s.Biome(x, 0, z)
-			if biome != "" {
-				hasBiomes = true
-				biomeIndices[idx] = biomePalette.Add(base.BlockState{Name: biome})
+	if opts.Biomes != BiomesNone {
+		for z := range length {
+			for x := range width {
+				idx := x + z*width
+				biome := columnBiome(s, x, z, opts.BiomeSampling)
+				if biome != "" {
+					hasBiomes = true
+					biomeIndices[idx] = biomePalette.Add(base.BlockState{Name: biome})
+				}
 			}
 		}
 	}
 
 	if hasBiomes {
+		if opts.PaletteOrder == PaletteSorted {
+			biomePalette.Sort(biomeIndices)
+		}
 		biomePaletteMap := make(map[string]int32)
 		for i, block := range biomePalette.Blocks() {
 			biomePaletteMap[block.Name] = int32(i)
@@ -289,26 +291,18 @@ func WriteV2(w io.Writer, s base.Schematic) error {
 	}
 
 	// Encode entities
-	for _, ent := range s.Entities() {
-		entData := make(map[string]any)
-		entData["Pos"] = []float64{ent.Pos[0], ent.Pos[1], ent.Pos[2]}
-		entData["Rotation"] = []float32{ent.Rotation[0], ent.Rotation[1]}
-		entData["Motion"] = []float64{ent.Motion[0], ent.Motion[1], ent.Motion[2]}
-		entData["Id"] = ent.ID
-		maps.Copy(entData, ent.Data)
-		data.Entities = append(data.Entities, entData)
+	if !opts.SkipEntities {
+		for _, ent := range s.Entities() {
+			entData := make(map[string]any)
+			entData["Pos"] = []float64{ent.Pos[0], ent.Pos[1], ent.Pos[2]}
+			entData["Rotation"] = []float32{ent.Rotation[0], ent.Rotation[1]}
+			entData["Motion"] = []float64{ent.Motion[0], ent.Motion[1], ent.Motion[2]}
+			entData["Id"] = ent.ID
+			maps.Copy(entData, ent.Data)
+			data.Entities = append(data.Entities, entData)
+		}
 	}
 
 	// Compress and write
-	var buf bytes.Buffer
-	gz := gzip.NewWriter(&buf)
-	if err := nbt.NewEncoderWithEncoding(gz, nbt.BigEndian).Encode(data); err != nil {
-		return fmt.Errorf("encode nbt: %w", err)
-	}
-	if err := gz.Close(); err != nil {
-		return fmt.Errorf("close gzip: %w", err)
-	}
-
-	_, err := w.Write(buf.Bytes())
-	return err
+	return base.WriteGzipNBT(w, data, opts.CompressionLevel)
 }
@@ -1,7 +1,6 @@
 package sponge
 
 import (
-	"bytes"
 	"compress/gzip"
 	"fmt"
 	"io"
@@ -17,27 +16,31 @@ type v3NBT struct {
 	DataVersion int32 `nbt:"DataVersion"`
 
 	Metadata struct {
-		Name        string `nbt:"Name,omitempty"`
-		Author      string `nbt:"Author,omitempty"`
-		Date        int64  `nbt:"Date,omitempty"`
-		Description string `nbt:"Description,omitempty"`
+		Name         string         `nbt:"Name,omitempty"`
+		Author       string         `nbt:"Author,omitempty"`
+		Date         int64          `nbt:"Date,omitempty"`
+		Description  string         `nbt:"Description,omitempty"`
+		RequiredMods []string       `nbt:"RequiredMods,omitempty"`
+		Extra        map[string]any `nbt:"*"`
 	} `nbt:"Metadata"`
 
 	Width  int16 `nbt:"Width"`
 	Height int16 `nbt:"Height"`
 	Length int16 `nbt:"Length"`
 
-	Offset []int32 `nbt:"Offset,omitempty"`
+	Offset []int32 `nbt:"Offset,array,omitempty"`
 
 	Blocks struct {
 		Palette       map[string]int32 `nbt:"Palette"`
-		Data          []byte           `nbt:"Data"`
+		Data          []byte           `nbt:"Data,array"`
 		BlockEntities []map[string]any `nbt:"BlockEntities,omitempty"`
-	} `nbt:"Blocks"`
+		Extra         map[string]any   `nbt:"*"`
+	} `nbt:"Blocks,omitempty"`
 
 	Biomes struct {
-		Palette []string `nbt:"Palette,omitempty"`
-		Data    []byte   `nbt:"Data,omitempty"`
+		Palette map[string]int32 `nbt:"Palette,omitempty"`
+		Data    []byte           `nbt:"Data,array,omitempty"`
+		Extra   map[string]any   `nbt:"*"`
 	} `nbt:"Biomes,omitempty"`
 
 	Entities []map[string]any `nbt:"Entities,omitempty"`
@@ -68,14 +71,18 @@ func ReadV3(r io.Reader) (base.Schematic, error) {
 	}
 
 	// Validate dimensions
-	width, height, length := int(data.Width), int(data.Height), int(data.Length)
-	if width <= 0 || height <= 0 || length <= 0 {
-		return nil, fmt.Errorf("invalid dimensions: %dx%dx%d", width, height, length)
+	width, height, length, err := readDimensions(data.Width, data.Height, data.Length)
+	if err != nil {
+		return nil, err
 	}
 
 	// Create schematic
 	s := base.New(width, height, length, "sponge_v3")
-	s.SetDataVersion(int(data.DataVersion))
+	dataVersion, err := readDataVersion(data.DataVersion)
+	if err != nil {
+		return nil, err
+	}
+	s.SetDataVersion(dataVersion)
 
 	// Set offset
 	if len(data.Offset) >= 3 {
@@ -83,40 +90,42 @@ func ReadV3(r io.Reader) (base.Schematic, error) {
 	}
 
 	// Set metadata
-	s.SetMetadata("Name", data.Metadata.Name)
-	s.SetMetadata("Author", data.Metadata.Author)
-	s.SetMetadata("Date", data.Metadata.Date)
-	s.SetMetadata("Description", data.Metadata.Description)
-
-	// Decode blocks
-	blockCount := int(data.Width) * int(data.Height) * int(data.Length)
-	blockIndices, err := base.DecodeVarIntArray(data.Blocks.Data, blockCount)
-	if err != nil {
-		return nil, fmt.Errorf("decode block data: %w", err)
-	}
-
-	// Build palette
-	palette := make([]*base.BlockState, len(data.Blocks.Palette))
-	for stateStr, id := range data.Blocks.Palette {
-		if int(id) >= len(palette) {
-			newPalette := make([]*base.BlockState, int(id)+1)
-			copy(newPalette, palette)
-			palette = newPalette
+	s.SetInfo(base.Info{
+		Name:         data.Metadata.Name,
+		Author:       data.Metadata.Author,
+		Description:  data.Metadata.Description,
+		Created:      base.TimeFromMillis(data.Metadata.Date),
+		RequiredMods: data.Metadata.RequiredMods,
+	})
+	base.SetExtra(s, "metadata", data.Metadata.Extra)
+	readWorldEdit(s, data.Metadata.Extra)
+	base.SetExtra(s, "root", data.Extra)
+	base.SetExtra(s, "blocks", data.Blocks.Extra)
+	base.SetExtra(s, "biomes", data.Biomes.Extra)
+
+	// Decode blocks. Blocks are optional, such as in entity-only schematics.
+	if data.Blocks.Data != nil {
+		blockCount := width * height * length
+		blockIndices, err := base.DecodeVarIntArray(data.Blocks.Data, blockCount)
+		if err != nil {
+			return nil, fmt.Errorf("decode block data: %w", err)
 		}
-		palette[id] = base.ParseBlockState(stateStr)
-	}
 
-	// Set blocks
-	for y := 0; y < int(data.Height); y++ {
-		for z := 0; z < int(data.Length); z++ {
-			for x := 0; x < int(data.Width); x++ {
-				idx := x + z*int(data.Width) + y*int(data.Width)*int(data.Length)
-				if idx >= len(blockIndices) {
-					continue
-				}
-				paletteIdx := blockIndices[idx]
-				if paletteIdx >= 0 && paletteIdx < len(palette) && palette[paletteIdx] != nil {
-					s.SetBlock(x, y, z, palette[paletteIdx].Clone())
+		// Build palette
+		palette := readPalette(data.Blocks.Palette)
+
+		// Set blocks
+		for y := 0; y < height; y++ {
+			for z := 0; z < length; z++ {
+				for x := 0; x < width; x++ {
+					idx := x + z*width + y*width*length
+					if idx >= len(blockIndices) {
+						continue
+					}
+					paletteIdx := blockIndices[idx]
+					if state := palette[paletteIdx]; state != nil {
+						s.SetBlock(x, y, z, state.Clone())
+					}
 				}
 			}
 		}
@@ -124,27 +133,9 @@ func ReadV3(r io.Reader) (base.Schematic, error) {
 
 	// Set block entities
 	for _, beData := range data.Blocks.BlockEntities {
-		be := &base.BlockEntity{
-			Data: make(map[string]any),
-		}
-
-		// Extract position
-		if pos, ok := beData["Pos"].([3]int32); ok {
-			be.X = int(pos[0])
-			be.Y = int(pos[1])
-			be.Z = int(pos[2])
-		}
-
-		// Extract ID
-		if id, ok := beData["Id"].(string); ok {
-			be.ID = id
-		}
-
-		// Copy remaining data
-		for k, v := range beData {
-			if k != "Pos" && k != "Id" {
-				be.Data[k] = v
-			}
+		be, err := readBlockEntity(beData)
+		if err != nil {
+			return nil, err
 		}
 
 		s.SetBlockEntity(be.X, be.Y, be.Z, be)
@@ -152,22 +143,26 @@ func ReadV3(r io.Reader) (base.Schematic, error) {
 
 	// Decode biomes (3D)
 	if len(data.Biomes.Data) > 0 && len(data.Biomes.Palette) > 0 {
-		biomeCount := int(data.Width) * int(data.Height) * int(data.Length)
+		biomePalette := make(map[int]string, len(data.Biomes.Palette))
+		for biome, idx := range data.Biomes.Palette {
+			biomePalette[int(idx)] = biome
+		}
+
+		biomeCount := width * height * length
 		biomeIndices, err := base.DecodeVarIntArray(data.Biomes.Data, biomeCount)
 		if err != nil {
 			return nil, fmt.Errorf("decode biome data: %w", err)
 		}
 
-		for y := 0; y < int(data.Height); y++ {
-			for z := 0; z < int(data.Length); z++ {
-				for x := 0; x < int(data.Width); x++ {
-					idx := x + z*int(data.Width) + y*int(data.Width)*int(data.Length)
+		for y := 0; y < height; y++ {
+			for z := 0; z < length; z++ {
+				for x := 0; x < width; x++ {
+					idx := x + z*width + y*width*length
 					if idx >= len(biomeIndices) {
 						continue
 					}
-					biomeIdx := biomeIndices[idx]
-					if biomeIdx >= 0 && biomeIdx < len(data.Biomes.Palette) {
-						s.SetBiome(x, y, z, data.Biomes.Palette[biomeIdx])
+					if biome, ok := biomePalette[biomeIndices[idx]]; ok {
+						s.SetBiome(x, y, z, biome)
 					}
 				}
 			}
@@ -220,12 +215,27 @@ func ReadV3(r io.Reader) (base.Schematic, error) {
 
 // WriteV3 writes a schematic as Sponge Schematic v3.
 func WriteV3(w io.Writer, s base.Schematic) error {
+	return writeV3(w, s, Options{Version: 3})
+}
+
+// writeV3 writes a schematic as Sponge Schematic v3 according to the
+// options passed.
+func writeV3(w io.Writer, s base.Schematic, opts Options) error {
+	w16, h16, l16, err := writeDimensions(s)
+	if err != nil {
+		return err
+	}
+	dataVersion, err := writeDataVersion(s)
+	if err != nil {
+		return err
+	}
 	width, height, length := s.Dimensions()
 	offsetX, offsetY, offsetZ := s.Offset()
 
 	// Build palette
 	palette := base.NewPaletteWithAir()
 	blockIndices := make([]int, width*height*length)
+	hasBlocks := false
 
 	for y := range height {
 		for z := range length {
@@ -235,36 +245,36 @@ func WriteV3(w io.Writer, s base.Schematic) error {
 				if block == nil {
 					blockIndices[idx] = 0
 				} else {
+					hasBlocks = true
 					blockIndices[idx] = palette.Add(*block)
 				}
 			}
 		}
 	}
 
+	if opts.PaletteOrder == PaletteSorted {
+		palette.Sort(blockIndices)
+	}
+
 	// Build NBT structure
 	data := v3NBT{
 		Version:     3,
-		DataVersion: int32(s.DataVersion()),
-		Width:       int16(width),
-		Height:      int16(height),
-		Length:      int16(length),
+		DataVersion: dataVersion,
+		Width:       w16,
+		Height:      h16,
+		Length:      l16,
 		Offset:      []int32{int32(offsetX), int32(offsetY), int32(offsetZ)},
+		Extra:       base.Extra(s, "sponge_v3", "root"),
 	}
 
 	// Metadata
-	meta := s.Metadata()
-	if name, ok := meta["Name"].(string); ok {
-		data.Metadata.Name = name
-	}
-	if author, ok := meta["Author"].(string); ok {
-		data.Metadata.Author = author
-	}
-	if date, ok := meta["Date"].(int64); ok {
-		data.Metadata.Date = date
-	}
-	if desc, ok := meta["Description"].(string); ok {
-		data.Metadata.Description = desc
-	}
+	data.Metadata.Extra = writeWorldEdit(s, base.Extra(s, "sponge_v3", "metadata"))
+	info := s.Info()
+	data.Metadata.Name = info.Name
+	data.Metadata.Author = info.Author
+	data.Metadata.Date = base.Millis(info.Created)
+	data.Metadata.Description = info.Description
+	data.Metadata.RequiredMods = info.RequiredMods
 
 	// Encode palette
 	data.Blocks.Palette = make(map[string]int32, palette.Size())
@@ -274,60 +284,91 @@ func WriteV3(w io.Writer, s base.Schematic) error {
 
 	// Encode block data
 	data.Blocks.Data = base.EncodeVarIntArray(blockIndices)
+	data.Blocks.Extra = base.Extra(s, "sponge_v3", "blocks")
 
 	// Encode block entities
-	for y := range height {
-		for z := range length {
-			for x := range width {
-				be := s.BlockEntity(x, y, z)
-				if be == nil {
-					continue
+	if !opts.SkipBlockEntities {
+		for y := range height {
+			for z := range length {
+				for x := range width {
+					be := s.BlockEntity(x, y, z)
+					if be == nil {
+						continue
+					}
+
+					beData := make(map[string]any)
+					beData["Pos"] = [3]int32{int32(x), int32(y), int32(z)}
+					beData["Id"] = be.ID
+					maps.Copy(beData, be.Data)
+					data.Blocks.BlockEntities = append(data.Blocks.BlockEntities, beData)
 				}
+			}
+		}
+	}
+
+	// Leave out the Blocks container of schematics without blocks, such as
+	// entity-only schematics.
+	if !hasBlocks && len(data.Blocks.BlockEntities) == 0 && len(data.Blocks.Extra) == 0 {
+		data.Blocks.Palette, data.Blocks.Data = nil, nil
+	}
 
-				beData := make(map[string]any)
-				beData["Pos"] = []int32{int32(x), int32(y), int32(z)}
-				beData["Id"] = be.ID
-				maps.Copy(beData, be.Data)
-				data.Blocks.BlockEntities = append(data.Blocks.BlockEntities, beData)
+	// Encode biomes, which are always stored in 3D. 2D biomes repeat the
+	// biome of a column for every block in it.
+	biomeAt := s.Biome
+	if opts.Biomes == Biomes2D {
+		columns := make([]string, width*length)
+		for z := range length {
+			for x := range width {
+				columns[x+z*width] = columnBiome(s, x, z, opts.BiomeSampling)
 			}
 		}
+		biomeAt = func(x, _, z int) string {
+			return columns[x+z*width]
+		}
 	}
 
-	// Encode biomes (3D)
 	biomePalette := base.NewPalette()
 	biomeIndices := make([]int, width*height*length)
 	hasBiomes := false
 
-	for y := range height {
-		for z := range length {
-			for x := range width {
-				idx := x + z*width + y*width*length
-				biome := s.Biome(x, y, z)
-				if biome != "" {
-					hasBiomes = true
-					biomeIndices[idx] = biomePalette.Add(base.BlockState{Name: biome})
+	if opts.Biomes != BiomesNone {
+		for y := range height {
+			for z := range length {
+				for x := range width {
+					idx := x + z*width + y*width*length
+					biome := biomeAt(x, y, z)
+					if biome != "" {
+						hasBiomes = true
+						biomeIndices[idx] = biomePalette.Add(base.BlockState{Name: biome})
+					}
 				}
 			}
 		}
 	}
 
 	if hasBiomes {
-		data.Biomes.Palette = make([]string, biomePalette.Size())
+		if opts.PaletteOrder == PaletteSorted {
+			biomePalette.Sort(biomeIndices)
+		}
+		data.Biomes.Palette = make(map[string]int32, biomePalette.Size())
 		for i, block := range biomePalette.Blocks() {
-			data.Biomes.Palette[i] = block.Name
+			data.Biomes.Palette[block.Name] = int32(i)
 		}
 		data.Biomes.Data = base.EncodeVarIntArray(biomeIndices)
+		data.Biomes.Extra = base.Extra(s, "sponge_v3", "biomes")
 	}
 
 	// Encode entities
-	for _, ent := range s.Entities() {
-		entData := make(map[string]any)
-		entData["Pos"] = []float64{ent.Pos[0], ent.Pos[1], ent.Pos[2]}
-		entData["Rotation"] = []float32{ent.Rotation[0], ent.Rotation[1]}
-		entData["Motion"] = []float64{ent.Motion[0], ent.Motion[1], ent.Motion[2]}
-		entData["Id"] = ent.ID
-		maps.Copy(entData, ent.Data)
-		data.Entities = append(data.Entities, entData)
+	if !opts.SkipEntities {
+		for _, ent := range s.Entities() {
+			entData := make(map[string]any)
+			entData["Pos"] = []float64{ent.Pos[0], ent.Pos[1], ent.Pos[2]}
+			entData["Rotation"] = []float32{ent.Rotation[0], ent.Rotation[1]}
+			entData["Motion"] = []float64{ent.Motion[0], ent.Motion[1], ent.Motion[2]}
+			entData["Id"] = ent.ID
+			maps.Copy(entData, ent.Data)
+			data.Entities = append(data.Entities, entData)
+		}
 	}
 
 	// Wrap in root tag
@@ -336,15 +377,5 @@ func WriteV3(w io.Writer, s base.Schematic) error {
 	}{Schematic: data}
 
 	// Compress and write
-	var buf bytes.Buffer
-	gz := gzip.NewWriter(&buf)
-	if err := nbt.NewEncoderWithEncoding(gz, nbt.BigEndian).Encode(root); err != nil {
-		return fmt.Errorf("encode nbt: %w", err)
-	}
-	if err := gz.Close(); err != nil {
-		return fmt.Errorf("close gzip: %w", err)
-	}
-
-	_, err := w.Write(buf.Bytes())
-	return err
+	return base.WriteGzipNBT(w, root, opts.CompressionLevel)
 }
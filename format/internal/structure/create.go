@@ -0,0 +1,109 @@
+package structure
+
+import (
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/oriumgames/schem/format/internal/base"
+)
+
+const (
+	// createTool is the tool Create schematics are made with.
+	createTool = "Create"
+	// createMarker is a root tag that WriteCreate adds to mark its files as
+	// Create schematics. The game and Create ignore it.
+	createMarker = "CreateSchematic"
+)
+
+// ReadCreate reads a schematic saved with Create's schematic and quill. These
+// are structure files holding the blocks of mods, the data of their block
+// entities and Create's super glue entities, which are all kept as they are.
+// The namespaces of the blocks and entities of mods are read into the
+// RequiredMods of the schematic info.
+func ReadCreate(r io.Reader) (base.Schematic, error) {
+	data, err := read(r)
+	if err != nil {
+		return nil, err
+	}
+	delete(data.Extra, createMarker)
+	s, err := fromNBT(data, "create")
+	if err != nil {
+		return nil, err
+	}
+	s.SetInfo(base.Info{RequiredMods: requiredMods(data), Tool: createTool})
+	return s, nil
+}
+
+// WriteCreate writes a schematic as a Create schematic. Like other structure
+// files, positions without a block are left out. The file is marked as a
+// Create schematic with a CreateSchematic root tag, so that it is detected as
+// one even if it holds no blocks of Create.
+func WriteCreate(w io.Writer, s base.Schematic) error {
+	extra := base.Extra(s, "create", "root")
+	if extra == nil {
+		extra = make(map[string]any, 1)
+	}
+	extra[createMarker] = uint8(1)
+	return base.WriteGzipNBT(w, toNBT(s, extra), 0)
+}
+
+// IsCreate reports whether the root compound of a structure file is that of a
+// Create schematic. Create saves schematics as plain structure files, so
+// those not written by WriteCreate are told apart by a heuristic: the blocks
+// of Create in the palette passed, which is the first palette of the file.
+func IsCreate(root map[string]any, palette []any) bool {
+	if _, ok := root[createMarker]; ok {
+		return true
+	}
+	for _, entry := range palette {
+		if entry, ok := entry.(map[string]any); ok {
+			if name, _ := entry["Name"].(string); namespace(name) == "create" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// requiredMods returns the sorted namespaces other than minecraft of the
+// blocks, block entities and entities of a structure.
+func requiredMods(data structureNBT) []string {
+	var mods []string
+	add := func(id string) {
+		if ns := namespace(id); ns != "" && ns != "minecraft" && !slices.Contains(mods, ns) {
+			mods = append(mods, ns)
+		}
+	}
+	for _, entry := range data.Palette {
+		add(entry.Name)
+	}
+	for _, palette := range data.Palettes {
+		for _, entry := range palette {
+			add(entry.Name)
+		}
+	}
+	for _, block := range data.Blocks {
+		id, _ := block.NBT["id"].(string)
+		add(id)
+	}
+	for _, entity := range data.Entities {
+		id, _ := entity.NBT["id"].(string)
+		add(id)
+	}
+	slices.Sort(mods)
+	return mods
+}
+
+// namespace returns the namespace of a resource location, which is minecraft
+// if it has none.
+func namespace(id string) string {
+	if id == "" {
+		return ""
+	}
+	ns, _, ok := strings.Cut(id, ":")
+	if !ok {
+		return "minecraft"
+	}
+	return ns
+}
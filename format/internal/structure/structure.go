@@ -0,0 +1,239 @@
+package structure
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+
+	"github.com/oriumgames/nbt"
+	"github.com/oriumgames/schem/format/internal/base"
+)
+
+type paletteEntry struct {
+	Name       string         `nbt:"Name"`
+	Properties map[string]any `nbt:"Properties,omitempty"`
+}
+
+type blockNBT struct {
+	State int32          `nbt:"state"`
+	Pos   []int32        `nbt:"pos"`
+	NBT   map[string]any `nbt:"nbt,omitempty"`
+}
+
+type entityNBT struct {
+	Pos      []float64      `nbt:"pos"`
+	BlockPos []int32        `nbt:"blockPos"`
+	NBT      map[string]any `nbt:"nbt"`
+}
+
+type structureNBT struct {
+	DataVersion int32            `nbt:"DataVersion"`
+	Size        []int32          `nbt:"size"`
+	Palette     []paletteEntry   `nbt:"palette,omitempty"`
+	Palettes    [][]paletteEntry `nbt:"palettes,omitempty"`
+	Blocks      []blockNBT       `nbt:"blocks"`
+	Entities    []entityNBT      `nbt:"entities"`
+	Extra       map[string]any   `nbt:"*"`
+}
+
+// Read reads a vanilla structure file, as saved by structure blocks. Positions
+// missing from the file are structure void and have no block. Structures with
+// several palettes, such as shipwrecks, are read with their first palette.
+func Read(r io.Reader) (base.Schematic, error) {
+	data, err := read(r)
+	if err != nil {
+		return nil, err
+	}
+	return fromNBT(data, "structure")
+}
+
+// read reads and decodes a gzip compressed structure, decoding its blocks and
+// entities one by one as each holds lists of numbers.
+func read(r io.Reader) (structureNBT, error) {
+	var data structureNBT
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return data, fmt.Errorf("gzip decompress: %w", err)
+	}
+	defer gz.Close()
+
+	raw, err := io.ReadAll(gz)
+	if err != nil {
+		return data, fmt.Errorf("read gzip data: %w", err)
+	}
+	raw, blocks, err := base.SplitList(raw, "blocks")
+	if err != nil {
+		return data, fmt.Errorf("decode nbt: %w", err)
+	}
+	raw, entities, err := base.SplitList(raw, "entities")
+	if err != nil {
+		return data, fmt.Errorf("decode nbt: %w", err)
+	}
+	if err := decodeNBT(raw, &data); err != nil {
+		return data, err
+	}
+	data.Blocks = make([]blockNBT, len(blocks))
+	for i, block := range blocks {
+		if err := decodeNBT(block, &data.Blocks[i]); err != nil {
+			return data, err
+		}
+	}
+	data.Entities = make([]entityNBT, len(entities))
+	for i, entity := range entities {
+		if err := decodeNBT(entity, &data.Entities[i]); err != nil {
+			return data, err
+		}
+	}
+	return data, nil
+}
+
+// decodeNBT decodes uncompressed big endian NBT into v.
+func decodeNBT(data []byte, v any) error {
+	if err := nbt.NewDecoderWithEncoding(bytes.NewReader(data), nbt.BigEndian).Decode(v); err != nil {
+		return fmt.Errorf("decode nbt: %w", err)
+	}
+	return nil
+}
+
+// fromNBT builds a schematic of the format passed from a decoded structure.
+func fromNBT(data structureNBT, formatID string) (*base.SchematicImpl, error) {
+	if len(data.Size) != 3 {
+		return nil, fmt.Errorf("invalid size: %v", data.Size)
+	}
+	width, height, length := int(data.Size[0]), int(data.Size[1]), int(data.Size[2])
+	if width <= 0 || height <= 0 || length <= 0 {
+		return nil, fmt.Errorf("invalid dimensions: %dx%dx%d", width, height, length)
+	}
+
+	s := base.New(width, height, length, formatID)
+	s.SetDataVersion(int(data.DataVersion))
+	base.SetExtra(s, "root", data.Extra)
+
+	palette := data.Palette
+	if len(palette) == 0 && len(data.Palettes) > 0 {
+		palette = data.Palettes[0]
+	}
+	states := make([]*base.BlockState, len(palette))
+	for i, entry := range palette {
+		states[i] = &base.BlockState{Name: entry.Name, Properties: entry.Properties}
+	}
+
+	for _, block := range data.Blocks {
+		if len(block.Pos) != 3 {
+			return nil, fmt.Errorf("invalid block position: %v", block.Pos)
+		}
+		x, y, z := int(block.Pos[0]), int(block.Pos[1]), int(block.Pos[2])
+		if x < 0 || y < 0 || z < 0 || x >= width || y >= height || z >= length {
+			return nil, fmt.Errorf("block position %v outside of %dx%dx%d", block.Pos, width, height, length)
+		}
+		if block.State < 0 || int(block.State) >= len(states) {
+			return nil, fmt.Errorf("invalid palette index %d at %v", block.State, block.Pos)
+		}
+		s.SetBlock(x, y, z, states[block.State].Clone())
+
+		if len(block.NBT) == 0 {
+			continue
+		}
+		be := &base.BlockEntity{X: x, Y: y, Z: z, Data: make(map[string]any, len(block.NBT))}
+		for k, v := range block.NBT {
+			if k == "id" {
+				be.ID, _ = v.(string)
+				continue
+			}
+			be.Data[k] = v
+		}
+		s.SetBlockEntity(x, y, z, be)
+	}
+
+	for _, raw := range data.Entities {
+		ent := &base.Entity{Data: make(map[string]any, len(raw.NBT))}
+		if len(raw.Pos) >= 3 {
+			ent.Pos = [3]float64{raw.Pos[0], raw.Pos[1], raw.Pos[2]}
+		}
+		ent.ID, _ = raw.NBT["id"].(string)
+		if rot := base.Float32List(raw.NBT["Rotation"]); len(rot) >= 2 {
+			ent.Rotation = [2]float32{rot[0], rot[1]}
+		}
+		if motion := base.Float64List(raw.NBT["Motion"]); len(motion) >= 3 {
+			ent.Motion = [3]float64{motion[0], motion[1], motion[2]}
+		}
+		if uuid, ok := raw.NBT["UUID"].([4]int32); ok {
+			ent.UUID = &uuid
+		}
+		for k, v := range raw.NBT {
+			switch k {
+			case "id", "Pos", "Rotation", "Motion", "UUID":
+				continue
+			default:
+				ent.Data[k] = v
+			}
+		}
+		s.AddEntity(ent)
+	}
+	return s, nil
+}
+
+// Write writes a schematic as a vanilla structure file. Positions without a
+// block, and structure void blocks, are left out of the file so that placing
+// the structure keeps the blocks already there.
+func Write(w io.Writer, s base.Schematic) error {
+	return base.WriteGzipNBT(w, toNBT(s, base.Extra(s, "structure", "root")), 0)
+}
+
+// toNBT builds the structure of a schematic, with the extra root tags passed.
+func toNBT(s base.Schematic, extra map[string]any) structureNBT {
+	width, height, length := s.Dimensions()
+	data := structureNBT{
+		DataVersion: int32(s.DataVersion()),
+		Size:        []int32{int32(width), int32(height), int32(length)},
+		Palette:     []paletteEntry{},
+		Blocks:      []blockNBT{},
+		Entities:    []entityNBT{},
+		Extra:       extra,
+	}
+
+	palette := base.NewPalette()
+	for y := range height {
+		for z := range length {
+			for x := range width {
+				state := s.Block(x, y, z)
+				if state == nil || state.Name == "minecraft:structure_void" {
+					continue
+				}
+				idx := palette.Add(*state)
+				if idx == len(data.Palette) {
+					entry := paletteEntry{Name: state.Name, Properties: state.NBTProperties()}
+					data.Palette = append(data.Palette, entry)
+				}
+				block := blockNBT{State: int32(idx), Pos: []int32{int32(x), int32(y), int32(z)}}
+				if be := s.BlockEntity(x, y, z); be != nil {
+					block.NBT = make(map[string]any, len(be.Data)+1)
+					maps.Copy(block.NBT, be.Data)
+					block.NBT["id"] = be.ID
+				}
+				data.Blocks = append(data.Blocks, block)
+			}
+		}
+	}
+
+	for _, ent := range s.Entities() {
+		raw := make(map[string]any, len(ent.Data)+5)
+		maps.Copy(raw, ent.Data)
+		raw["id"] = ent.ID
+		raw["Pos"] = []float64{ent.Pos[0], ent.Pos[1], ent.Pos[2]}
+		raw["Rotation"] = []float32{ent.Rotation[0], ent.Rotation[1]}
+		raw["Motion"] = []float64{ent.Motion[0], ent.Motion[1], ent.Motion[2]}
+		if ent.UUID != nil {
+			raw["UUID"] = *ent.UUID
+		}
+		data.Entities = append(data.Entities, entityNBT{
+			Pos:      []float64{ent.Pos[0], ent.Pos[1], ent.Pos[2]},
+			BlockPos: []int32{int32(math.Floor(ent.Pos[0])), int32(math.Floor(ent.Pos[1])), int32(math.Floor(ent.Pos[2]))},
+			NBT:      raw,
+		})
+	}
+	return data
+}
@@ -0,0 +1,394 @@
+package text
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// formatSNBT formats a value as SNBT. Compound entries are sorted by name, so
+// that equal values are always formatted the same way.
+func formatSNBT(v any) (string, error) {
+	b, err := appendSNBT(nil, v)
+	return string(b), err
+}
+
+// appendSNBT appends the SNBT of a value to b. Each NBT type has its own
+// notation, so that parseSNBT returns values of the types that the NBT
+// decoder returns: bytes are written as 1b, shorts as 1s, ints as 1, longs as
+// 1L, floats as 1f and doubles as 1d. Lists of bytes, ints and longs are
+// written as lists, and arrays as [B;...], [I;...] and [L;...].
+func appendSNBT(b []byte, v any) ([]byte, error) {
+	switch v := v.(type) {
+	case bool:
+		return strconv.AppendBool(b, v), nil
+	case uint8:
+		return append(strconv.AppendUint(b, uint64(v), 10), 'b'), nil
+	case int16:
+		return append(strconv.AppendInt(b, int64(v), 10), 's'), nil
+	case int32:
+		return strconv.AppendInt(b, int64(v), 10), nil
+	case int64:
+		return append(strconv.AppendInt(b, v, 10), 'L'), nil
+	case float32:
+		return append(strconv.AppendFloat(b, float64(v), 'g', -1, 32), 'f'), nil
+	case float64:
+		return append(strconv.AppendFloat(b, v, 'g', -1, 64), 'd'), nil
+	case string:
+		return strconv.AppendQuote(b, v), nil
+	}
+
+	if v == nil {
+		return nil, fmt.Errorf("nil value")
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Uint, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		// Integers without an NBT type are written as ints, or as longs if
+		// they do not fit an int.
+		if rv.CanInt() {
+			if n := rv.Int(); n >= math.MinInt32 && n <= math.MaxInt32 {
+				return strconv.AppendInt(b, n, 10), nil
+			}
+			return append(strconv.AppendInt(b, rv.Int(), 10), 'L'), nil
+		}
+		if n := rv.Uint(); n <= math.MaxInt32 {
+			return strconv.AppendUint(b, n, 10), nil
+		}
+		return append(strconv.AppendUint(b, rv.Uint(), 10), 'L'), nil
+	case reflect.Array:
+		switch rv.Type().Elem().Kind() {
+		case reflect.Uint8:
+			return appendElements(append(b, "[B;"...), rv)
+		case reflect.Int32:
+			return appendElements(append(b, "[I;"...), rv)
+		case reflect.Int64:
+			return appendElements(append(b, "[L;"...), rv)
+		}
+		return appendElements(append(b, '['), rv)
+	case reflect.Slice:
+		return appendElements(append(b, '['), rv)
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("unsupported type %T", v)
+		}
+		keys := make([]string, 0, rv.Len())
+		for _, k := range rv.MapKeys() {
+			keys = append(keys, k.String())
+		}
+		slices.Sort(keys)
+		b = append(b, '{')
+		for i, k := range keys {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			b = appendKey(b, k)
+			b = append(b, ':')
+			var err error
+			if b, err = appendSNBT(b, rv.MapIndex(reflect.ValueOf(k).Convert(rv.Type().Key())).Interface()); err != nil {
+				return nil, fmt.Errorf("%s: %w", k, err)
+			}
+		}
+		return append(b, '}'), nil
+	}
+	return nil, fmt.Errorf("unsupported type %T", v)
+}
+
+// appendElements appends the elements of a slice or array, followed by the
+// closing bracket of the list.
+func appendElements(b []byte, rv reflect.Value) ([]byte, error) {
+	for i := range rv.Len() {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		var err error
+		if b, err = appendSNBT(b, rv.Index(i).Interface()); err != nil {
+			return nil, fmt.Errorf("[%d]: %w", i, err)
+		}
+	}
+	return append(b, ']'), nil
+}
+
+// appendKey appends a compound key, quoting it unless it only holds
+// characters allowed in unquoted strings.
+func appendKey(b []byte, key string) []byte {
+	if key == "" || strings.IndexFunc(key, func(r rune) bool { return !unquoted(byte(r)) || r > 0x7F }) >= 0 {
+		return strconv.AppendQuote(b, key)
+	}
+	return append(b, key...)
+}
+
+// unquoted reports whether c may appear in unquoted strings and numbers.
+func unquoted(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c == '-' || c == '.' || c == '+'
+}
+
+// parseSNBT parses SNBT as formatted by formatSNBT. Unquoted strings and
+// numbers without a suffix, as written by hand or by Minecraft, are accepted
+// too: the latter are ints, or doubles if they hold a fraction.
+func parseSNBT(s string) (any, error) {
+	p := &snbtParser{s: s}
+	v, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.off != len(p.s) {
+		return nil, p.errorf("unexpected %q after value", p.s[p.off])
+	}
+	return v, nil
+}
+
+// snbtParser parses SNBT.
+type snbtParser struct {
+	s   string
+	off int
+}
+
+func (p *snbtParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("snbt offset %d: %s", p.off, fmt.Sprintf(format, args...))
+}
+
+func (p *snbtParser) skipSpace() {
+	for p.off < len(p.s) && strings.IndexByte(" \t\r\n", p.s[p.off]) >= 0 {
+		p.off++
+	}
+}
+
+// expect skips spaces and the character c, or returns an error if another
+// character is found.
+func (p *snbtParser) expect(c byte) error {
+	p.skipSpace()
+	if p.off >= len(p.s) {
+		return p.errorf("expected %q, got end of input", c)
+	}
+	if p.s[p.off] != c {
+		return p.errorf("expected %q, got %q", c, p.s[p.off])
+	}
+	p.off++
+	return nil
+}
+
+func (p *snbtParser) value() (any, error) {
+	p.skipSpace()
+	if p.off >= len(p.s) {
+		return nil, p.errorf("unexpected end of input")
+	}
+	switch p.s[p.off] {
+	case '{':
+		return p.compound()
+	case '[':
+		return p.list()
+	case '"':
+		return p.quoted()
+	default:
+		token := p.token()
+		if token == "" {
+			return nil, p.errorf("unexpected %q", p.s[p.off])
+		}
+		return scalar(token), nil
+	}
+}
+
+func (p *snbtParser) compound() (map[string]any, error) {
+	p.off++
+	m := make(map[string]any)
+	p.skipSpace()
+	if p.off < len(p.s) && p.s[p.off] == '}' {
+		p.off++
+		return m, nil
+	}
+	for {
+		p.skipSpace()
+		var key string
+		if p.off < len(p.s) && p.s[p.off] == '"' {
+			var err error
+			if key, err = p.quoted(); err != nil {
+				return nil, err
+			}
+		} else if key = p.token(); key == "" {
+			return nil, p.errorf("expected key")
+		}
+		if err := p.expect(':'); err != nil {
+			return nil, err
+		}
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		m[key] = v
+
+		p.skipSpace()
+		if p.off < len(p.s) && p.s[p.off] == '}' {
+			p.off++
+			return m, nil
+		}
+		if err := p.expect(','); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *snbtParser) list() (any, error) {
+	p.off++
+	var arrayType reflect.Type
+	if p.off+1 < len(p.s) && p.s[p.off+1] == ';' {
+		switch p.s[p.off] {
+		case 'B':
+			arrayType = reflect.TypeFor[uint8]()
+		case 'I':
+			arrayType = reflect.TypeFor[int32]()
+		case 'L':
+			arrayType = reflect.TypeFor[int64]()
+		default:
+			return nil, p.errorf("unknown array type %q", p.s[p.off])
+		}
+		p.off += 2
+	}
+
+	var elements []any
+	p.skipSpace()
+	if p.off < len(p.s) && p.s[p.off] == ']' {
+		p.off++
+	} else {
+		for {
+			v, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			elements = append(elements, v)
+
+			p.skipSpace()
+			if p.off < len(p.s) && p.s[p.off] == ']' {
+				p.off++
+				break
+			}
+			if err := p.expect(','); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if arrayType != nil {
+		array := reflect.New(reflect.ArrayOf(len(elements), arrayType)).Elem()
+		for i, e := range elements {
+			ev := reflect.ValueOf(e)
+			if !ev.CanInt() && !ev.CanUint() {
+				return nil, p.errorf("array element %v is not an integer", e)
+			}
+			array.Index(i).Set(ev.Convert(arrayType))
+		}
+		return array.Interface(), nil
+	}
+	return typedList(elements), nil
+}
+
+// typedList returns the elements of a list as the NBT decoder does: lists of
+// bytes, ints and longs as []byte, []int32 and []int64, and other lists as
+// []any.
+func typedList(elements []any) any {
+	if len(elements) == 0 {
+		return []any{}
+	}
+	switch elements[0].(type) {
+	case uint8:
+		if list, ok := sameType[uint8](elements); ok {
+			return list
+		}
+	case int32:
+		if list, ok := sameType[int32](elements); ok {
+			return list
+		}
+	case int64:
+		if list, ok := sameType[int64](elements); ok {
+			return list
+		}
+	}
+	return elements
+}
+
+func sameType[T any](elements []any) ([]T, bool) {
+	list := make([]T, len(elements))
+	for i, e := range elements {
+		v, ok := e.(T)
+		if !ok {
+			return nil, false
+		}
+		list[i] = v
+	}
+	return list, true
+}
+
+// quoted parses a double quoted string.
+func (p *snbtParser) quoted() (string, error) {
+	start := p.off
+	for p.off++; p.off < len(p.s); p.off++ {
+		switch p.s[p.off] {
+		case '\\':
+			p.off++
+		case '"':
+			p.off++
+			str, err := strconv.Unquote(p.s[start:p.off])
+			if err != nil {
+				return "", fmt.Errorf("snbt offset %d: invalid string: %w", start, err)
+			}
+			return str, nil
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
+
+// token parses an unquoted string or number.
+func (p *snbtParser) token() string {
+	start := p.off
+	for p.off < len(p.s) && unquoted(p.s[p.off]) {
+		p.off++
+	}
+	return p.s[start:p.off]
+}
+
+// scalar returns the value of an unquoted token: a bool, a number of the type
+// of its suffix, or a string if it is neither.
+func scalar(token string) any {
+	switch token {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	number, suffix := token[:len(token)-1], token[len(token)-1]
+	switch suffix {
+	case 'b', 'B':
+		if n, err := strconv.ParseInt(number, 10, 16); err == nil && n >= math.MinInt8 && n <= math.MaxUint8 {
+			return uint8(n)
+		}
+	case 's', 'S':
+		if n, err := strconv.ParseInt(number, 10, 16); err == nil {
+			return int16(n)
+		}
+	case 'l', 'L':
+		if n, err := strconv.ParseInt(number, 10, 64); err == nil {
+			return n
+		}
+	case 'f', 'F':
+		if f, err := strconv.ParseFloat(number, 32); err == nil {
+			return float32(f)
+		}
+	case 'd', 'D':
+		if f, err := strconv.ParseFloat(number, 64); err == nil {
+			return f
+		}
+	}
+	if n, err := strconv.ParseInt(token, 10, 32); err == nil {
+		return int32(n)
+	}
+	if strings.ContainsAny(token, ".eE") {
+		if f, err := strconv.ParseFloat(token, 64); err == nil {
+			return f
+		}
+	}
+	return token
+}
@@ -0,0 +1,742 @@
+package text
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/oriumgames/schem/format/internal/base"
+)
+
+// version is the version of the documents written.
+const version = 1
+
+// document is a schematic as a JSON document. Blocks and biomes are stored
+// as palettes and layers: each layer holds a row of palette indices per Z
+// coordinate, run-length encoded as "count*index" tokens, with -1 for
+// positions without a block or biome. NBT data is stored as SNBT.
+type document struct {
+	Version     int                        `json:"version"`
+	Format      string                     `json:"format"`
+	DataVersion int                        `json:"dataVersion"`
+	Size        [3]int                     `json:"size"`
+	Offset      [3]int                     `json:"offset"`
+	Info        infoJSON                   `json:"info"`
+	Metadata    map[string]json.RawMessage `json:"metadata"`
+	// StringProperties reports whether block properties are all strings, in
+	// which case they are read back from palette lines as strings, rather
+	// than as booleans and ints like base.ParseBlockState does.
+	StringProperties bool              `json:"stringProperties"`
+	Palette          []json.RawMessage `json:"palette"`
+	Layers           [][]string        `json:"layers"`
+	Biomes           *biomesJSON       `json:"biomes"`
+	BlockEntities    []blockEntityJSON `json:"blockEntities"`
+	Entities         []entityJSON      `json:"entities"`
+}
+
+type infoJSON struct {
+	Name         string   `json:"name,omitempty"`
+	Author       string   `json:"author,omitempty"`
+	Description  string   `json:"description,omitempty"`
+	Created      string   `json:"created,omitempty"`
+	Modified     string   `json:"modified,omitempty"`
+	Tags         []string `json:"tags,omitempty"`
+	RequiredMods []string `json:"requiredMods,omitempty"`
+	Tool         string   `json:"tool,omitempty"`
+}
+
+// typedValue is a metadata value whose type is not implied by its SNBT.
+type typedValue struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// stateJSON is a palette entry whose properties cannot be read back from its
+// BlockState.String() line.
+type stateJSON struct {
+	Name       string `json:"name"`
+	Properties string `json:"properties,omitempty"`
+}
+
+// biomesJSON holds the biomes of each column, or of each block if a column
+// holds several biomes.
+type biomesJSON struct {
+	Palette []string   `json:"palette"`
+	Columns []string   `json:"columns,omitempty"`
+	Layers  [][]string `json:"layers,omitempty"`
+}
+
+type blockEntityJSON struct {
+	Pos  [3]int `json:"pos"`
+	ID   string `json:"id"`
+	Data string `json:"data,omitempty"`
+}
+
+type entityJSON struct {
+	ID       string     `json:"id"`
+	Pos      [3]float64 `json:"pos"`
+	Rotation [2]float32 `json:"rotation"`
+	Motion   [3]float64 `json:"motion"`
+	UUID     *[4]int32  `json:"uuid,omitempty"`
+	Data     string     `json:"data,omitempty"`
+}
+
+// Read reads a schematic from a JSON document written by Write. The schematic
+// keeps the format it was written from, so that writing it with format.Write
+// converts it back to that format, along with its unknown data.
+func Read(r io.Reader) (base.Schematic, error) {
+	var doc document
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("decode json: %w", err)
+	}
+	if doc.Version != version {
+		return nil, fmt.Errorf("unsupported version %d", doc.Version)
+	}
+	width, height, length := doc.Size[0], doc.Size[1], doc.Size[2]
+	if width < 0 || height < 0 || length < 0 {
+		return nil, fmt.Errorf("invalid dimensions: %dx%dx%d", width, height, length)
+	}
+
+	formatID := doc.Format
+	if formatID == "" {
+		formatID = "json"
+	}
+	s := base.New(width, height, length, formatID)
+	s.SetDataVersion(doc.DataVersion)
+	s.SetOffset(doc.Offset[0], doc.Offset[1], doc.Offset[2])
+
+	info, err := doc.Info.info()
+	if err != nil {
+		return nil, err
+	}
+	s.SetInfo(info)
+	for key, raw := range doc.Metadata {
+		v, err := readMetadata(raw)
+		if err != nil {
+			return nil, fmt.Errorf("metadata %s: %w", key, err)
+		}
+		s.SetMetadata(key, v)
+	}
+
+	palette := make([]*base.BlockState, len(doc.Palette))
+	for i, raw := range doc.Palette {
+		if palette[i], err = readState(raw, doc.StringProperties); err != nil {
+			return nil, fmt.Errorf("palette entry %d: %w", i, err)
+		}
+	}
+	if len(doc.Layers) != height {
+		return nil, fmt.Errorf("expected %d layers, got %d", height, len(doc.Layers))
+	}
+	for y, layer := range doc.Layers {
+		err := readLayer(layer, width, length, len(palette), func(x, z, i int) {
+			s.SetBlock(x, y, z, palette[i].Clone())
+		})
+		if err != nil {
+			return nil, fmt.Errorf("layer %d: %w", y, err)
+		}
+	}
+
+	if doc.Biomes != nil {
+		if err := readBiomes(s, doc.Biomes); err != nil {
+			return nil, fmt.Errorf("biomes: %w", err)
+		}
+	}
+
+	for _, raw := range doc.BlockEntities {
+		x, y, z := raw.Pos[0], raw.Pos[1], raw.Pos[2]
+		if x < 0 || y < 0 || z < 0 || x >= width || y >= height || z >= length {
+			return nil, fmt.Errorf("block entity position %v outside of %dx%dx%d", raw.Pos, width, height, length)
+		}
+		data, err := readCompound(raw.Data)
+		if err != nil {
+			return nil, fmt.Errorf("block entity at %v: %w", raw.Pos, err)
+		}
+		s.SetBlockEntity(x, y, z, &base.BlockEntity{ID: raw.ID, Data: data})
+	}
+
+	for i, raw := range doc.Entities {
+		data, err := readCompound(raw.Data)
+		if err != nil {
+			return nil, fmt.Errorf("entity %d: %w", i, err)
+		}
+		s.AddEntity(&base.Entity{
+			ID:       raw.ID,
+			Pos:      raw.Pos,
+			Rotation: raw.Rotation,
+			Motion:   raw.Motion,
+			UUID:     raw.UUID,
+			Data:     data,
+		})
+	}
+	return s, nil
+}
+
+func (i infoJSON) info() (base.Info, error) {
+	info := base.Info{
+		Name:         i.Name,
+		Author:       i.Author,
+		Description:  i.Description,
+		Tags:         i.Tags,
+		RequiredMods: i.RequiredMods,
+		Tool:         i.Tool,
+	}
+	var err error
+	if info.Created, err = parseTime(i.Created); err != nil {
+		return info, fmt.Errorf("info created: %w", err)
+	}
+	if info.Modified, err = parseTime(i.Modified); err != nil {
+		return info, fmt.Errorf("info modified: %w", err)
+	}
+	return info, nil
+}
+
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339Nano, s)
+}
+
+// readMetadata reads a metadata value, which is either the SNBT of the value
+// or a typedValue.
+func readMetadata(raw json.RawMessage) (any, error) {
+	var snbt string
+	if err := json.Unmarshal(raw, &snbt); err == nil {
+		return parseSNBT(snbt)
+	}
+	var typed typedValue
+	if err := json.Unmarshal(raw, &typed); err != nil {
+		return nil, err
+	}
+	t, err := parseType(typed.Type)
+	if err != nil {
+		return nil, err
+	}
+	v, err := parseSNBT(typed.Value)
+	if err != nil {
+		return nil, err
+	}
+	converted, err := convert(v, t)
+	if err != nil {
+		return nil, err
+	}
+	return converted.Interface(), nil
+}
+
+// readState reads a palette entry, which is either a BlockState.String()
+// line or a stateJSON.
+func readState(raw json.RawMessage, stringProperties bool) (*base.BlockState, error) {
+	var line string
+	if err := json.Unmarshal(raw, &line); err == nil {
+		return parseState(line, stringProperties), nil
+	}
+	var entry stateJSON
+	if err := json.Unmarshal(raw, &entry); err != nil {
+		return nil, err
+	}
+	state := &base.BlockState{Name: entry.Name}
+	if entry.Properties != "" {
+		props, err := parseSNBT(entry.Properties)
+		if err != nil {
+			return nil, err
+		}
+		var ok bool
+		if state.Properties, ok = props.(map[string]any); !ok {
+			return nil, fmt.Errorf("properties are not a compound")
+		}
+	}
+	return state, nil
+}
+
+// parseState parses a BlockState.String() line. Properties are kept as strings
+// if stringProperties is set.
+func parseState(line string, stringProperties bool) *base.BlockState {
+	if !stringProperties {
+		return base.ParseBlockState(line)
+	}
+	name, props, ok := strings.Cut(line, "[")
+	state := &base.BlockState{Name: name}
+	if !ok {
+		return state
+	}
+	state.Properties = make(map[string]any)
+	for part := range strings.SplitSeq(strings.TrimSuffix(props, "]"), ",") {
+		if key, value, ok := strings.Cut(part, "="); ok {
+			state.Properties[key] = value
+		}
+	}
+	return state
+}
+
+// readLayer reads the rows of a layer, calling set for every position that
+// holds a palette index.
+func readLayer(rows []string, width, length, paletteSize int, set func(x, z, i int)) error {
+	if len(rows) != length {
+		return fmt.Errorf("expected %d rows, got %d", length, len(rows))
+	}
+	for z, row := range rows {
+		x := 0
+		for token := range strings.FieldsSeq(row) {
+			count, index := 1, token
+			if c, i, ok := strings.Cut(token, "*"); ok {
+				n, err := strconv.Atoi(c)
+				if err != nil || n <= 0 {
+					return fmt.Errorf("row %d: invalid run %q", z, token)
+				}
+				count, index = n, i
+			}
+			i, err := strconv.Atoi(index)
+			if err != nil || i < -1 || i >= paletteSize {
+				return fmt.Errorf("row %d: invalid palette index %q", z, index)
+			}
+			if x+count > width {
+				return fmt.Errorf("row %d: more than %d entries", z, width)
+			}
+			if i >= 0 {
+				for dx := range count {
+					set(x+dx, z, i)
+				}
+			}
+			x += count
+		}
+		if x != width {
+			return fmt.Errorf("row %d: expected %d entries, got %d", z, width, x)
+		}
+	}
+	return nil
+}
+
+func readBiomes(s *base.SchematicImpl, biomes *biomesJSON) error {
+	width, height, length := s.Dimensions()
+	if biomes.Layers == nil {
+		return readLayer(biomes.Columns, width, length, len(biomes.Palette), func(x, z, i int) {
+			s.SetBiome(x, -1, z, biomes.Palette[i])
+		})
+	}
+	if len(biomes.Layers) != height {
+		return fmt.Errorf("expected %d layers, got %d", height, len(biomes.Layers))
+	}
+	for y, layer := range biomes.Layers {
+		err := readLayer(layer, width, length, len(biomes.Palette), func(x, z, i int) {
+			s.SetBiome(x, y, z, biomes.Palette[i])
+		})
+		if err != nil {
+			return fmt.Errorf("layer %d: %w", y, err)
+		}
+	}
+	return nil
+}
+
+// readCompound parses the SNBT compound of a block entity or entity.
+func readCompound(snbt string) (map[string]any, error) {
+	if snbt == "" {
+		return make(map[string]any), nil
+	}
+	v, err := parseSNBT(snbt)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := v.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("data is not a compound")
+	}
+	return m, nil
+}
+
+// Write writes a schematic as a JSON document. The document holds one palette
+// entry, row, block entity or entity per line, and every value is written in
+// a canonical form, so that documents of similar schematics can be compared
+// line by line.
+func Write(w io.Writer, s base.Schematic) error {
+	width, height, length := s.Dimensions()
+	offsetX, offsetY, offsetZ := s.Offset()
+
+	// Schematics without a format are read back as documents, so they are
+	// recorded as such.
+	formatID := s.Format()
+	if formatID == "" {
+		formatID = "json"
+	}
+
+	d := &docWriter{first: true}
+	d.open("", "{")
+	d.field("version", version)
+	d.field("format", formatID)
+	d.field("dataVersion", s.DataVersion())
+	d.field("size", [3]int{width, height, length})
+	d.field("offset", [3]int{offsetX, offsetY, offsetZ})
+	d.object("info", infoFields(s.Info()))
+
+	metadata, err := metadataFields(s.Metadata())
+	if err != nil {
+		return err
+	}
+	d.object("metadata", metadata)
+
+	p := palette(s)
+	states := p.Blocks()
+	stringProperties := true
+	for _, state := range states {
+		for _, v := range state.Properties {
+			if _, ok := v.(string); !ok {
+				stringProperties = false
+			}
+		}
+	}
+	d.field("stringProperties", stringProperties)
+	entries := make([]any, len(states))
+	for i, state := range states {
+		if entries[i], err = stateEntry(state, stringProperties); err != nil {
+			return fmt.Errorf("palette entry %s: %w", state.Name, err)
+		}
+	}
+	d.list("palette", entries)
+
+	layers := make([][]string, height)
+	for y := range height {
+		layers[y] = layerRows(width, length, func(x, z int) int {
+			if state := s.Block(x, y, z); state != nil {
+				return p.Index(*state)
+			}
+			return -1
+		})
+	}
+	d.layers("layers", layers)
+
+	writeBiomes(d, s)
+
+	var blockEntities []any
+	for y := range height {
+		for z := range length {
+			for x := range width {
+				be := s.BlockEntity(x, y, z)
+				if be == nil {
+					continue
+				}
+				data, err := formatCompound(be.Data)
+				if err != nil {
+					return fmt.Errorf("block entity at %d %d %d: %w", x, y, z, err)
+				}
+				blockEntities = append(blockEntities, blockEntityJSON{Pos: [3]int{x, y, z}, ID: be.ID, Data: data})
+			}
+		}
+	}
+	d.list("blockEntities", blockEntities)
+
+	var entities []any
+	for i, ent := range s.Entities() {
+		data, err := formatCompound(ent.Data)
+		if err != nil {
+			return fmt.Errorf("entity %d: %w", i, err)
+		}
+		entities = append(entities, entityJSON{
+			ID:       ent.ID,
+			Pos:      ent.Pos,
+			Rotation: ent.Rotation,
+			Motion:   ent.Motion,
+			UUID:     ent.UUID,
+			Data:     data,
+		})
+	}
+	d.list("entities", entities)
+	d.close("}")
+	return d.writeTo(w)
+}
+
+// palette returns the blocks of a schematic by order of first appearance.
+func palette(s base.Schematic) *base.Palette {
+	width, height, length := s.Dimensions()
+	p := base.NewPalette()
+	for y := range height {
+		for z := range length {
+			for x := range width {
+				if state := s.Block(x, y, z); state != nil {
+					p.Add(*state)
+				}
+			}
+		}
+	}
+	return p
+}
+
+// stateEntry returns the palette entry of a block: its BlockState.String()
+// line if the line is read back as the same block, or a stateJSON otherwise.
+func stateEntry(state base.BlockState, stringProperties bool) (any, error) {
+	line := state.String()
+	parsed := parseState(line, stringProperties)
+	if parsed.Name == state.Name && (len(state.Properties) == 0 && len(parsed.Properties) == 0 ||
+		reflect.DeepEqual(parsed.Properties, state.Properties)) {
+		return line, nil
+	}
+	entry := stateJSON{Name: state.Name}
+	if len(state.Properties) > 0 {
+		var err error
+		if entry.Properties, err = formatSNBT(state.Properties); err != nil {
+			return nil, err
+		}
+	}
+	return entry, nil
+}
+
+// layerRows returns the run-length encoded rows of a layer, with the palette
+// index of each position returned by at.
+func layerRows(width, length int, at func(x, z int) int) []string {
+	rows := make([]string, length)
+	var b strings.Builder
+	for z := range length {
+		b.Reset()
+		for x := 0; x < width; {
+			i, count := at(x, z), 1
+			for x+count < width && at(x+count, z) == i {
+				count++
+			}
+			if b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			if count > 1 {
+				b.WriteString(strconv.Itoa(count))
+				b.WriteByte('*')
+			}
+			b.WriteString(strconv.Itoa(i))
+			x += count
+		}
+		rows[z] = b.String()
+	}
+	return rows
+}
+
+// writeBiomes writes the biomes of a schematic, per column unless a column
+// holds several biomes.
+func writeBiomes(d *docWriter, s base.Schematic) {
+	width, height, length := s.Dimensions()
+	names := base.NewPalette()
+	index := func(biome string) int {
+		if biome == "" {
+			return -1
+		}
+		return names.Add(base.BlockState{Name: biome})
+	}
+
+	columns := layerRows(width, length, func(x, z int) int { return index(s.Biome(x, 0, z)) })
+	layered := false
+	for y := 1; y < height && !layered; y++ {
+		for z := range length {
+			for x := range width {
+				if s.Biome(x, y, z) != s.Biome(x, 0, z) {
+					layered = true
+				}
+			}
+		}
+	}
+	if names.Size() == 0 && !layered {
+		return
+	}
+
+	var layers [][]string
+	if layered {
+		columns = nil
+		layers = make([][]string, height)
+		for y := range height {
+			layers[y] = layerRows(width, length, func(x, z int) int { return index(s.Biome(x, y, z)) })
+		}
+	}
+	biomes := make([]any, 0, names.Size())
+	for _, biome := range names.Blocks() {
+		biomes = append(biomes, biome.Name)
+	}
+
+	d.open(key("biomes"), "{")
+	d.list("palette", biomes)
+	if layered {
+		d.layers("layers", layers)
+	} else {
+		rows := make([]any, len(columns))
+		for i, row := range columns {
+			rows[i] = row
+		}
+		d.list("columns", rows)
+	}
+	d.close("}")
+}
+
+// infoFields returns the fields of the info that are set.
+func infoFields(info base.Info) map[string]any {
+	fields := make(map[string]any)
+	for name, v := range map[string]string{
+		"name":        info.Name,
+		"author":      info.Author,
+		"description": info.Description,
+		"created":     formatTime(info.Created),
+		"modified":    formatTime(info.Modified),
+		"tool":        info.Tool,
+	} {
+		if v != "" {
+			fields[name] = v
+		}
+	}
+	if len(info.Tags) > 0 {
+		fields["tags"] = info.Tags
+	}
+	if len(info.RequiredMods) > 0 {
+		fields["requiredMods"] = info.RequiredMods
+	}
+	return fields
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+// metadataFields returns the metadata values as SNBT, with their type if the
+// SNBT is not read back as a value of the same type.
+func metadataFields(meta map[string]any) (map[string]any, error) {
+	fields := make(map[string]any, len(meta))
+	for key, v := range meta {
+		if v == nil {
+			continue
+		}
+		snbt, err := formatSNBT(v)
+		if err != nil {
+			return nil, fmt.Errorf("metadata %s: %w", key, err)
+		}
+		parsed, err := parseSNBT(snbt)
+		if err != nil {
+			return nil, fmt.Errorf("metadata %s: %w", key, err)
+		}
+		if reflect.TypeOf(parsed) == reflect.TypeOf(v) {
+			fields[key] = snbt
+			continue
+		}
+		name, err := typeName(reflect.TypeOf(v))
+		if err != nil {
+			return nil, fmt.Errorf("metadata %s: %w", key, err)
+		}
+		fields[key] = typedValue{Type: name, Value: snbt}
+	}
+	return fields, nil
+}
+
+// formatCompound formats the NBT data of a block entity or entity.
+func formatCompound(data map[string]any) (string, error) {
+	if len(data) == 0 {
+		return "", nil
+	}
+	return formatSNBT(data)
+}
+
+// marshal encodes a value as compact JSON, without escaping HTML characters.
+func marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// docWriter writes a document with an entry per line, indented by two
+// spaces per level.
+type docWriter struct {
+	lines  []string
+	indent int
+	// first reports whether the next entry is the first of its object or
+	// list, and so follows no comma.
+	first bool
+	err   error
+}
+
+// entry writes an entry of the current object or list.
+func (d *docWriter) entry(s string) {
+	if !d.first && len(d.lines) > 0 {
+		d.lines[len(d.lines)-1] += ","
+	}
+	d.first = false
+	d.lines = append(d.lines, strings.Repeat("  ", d.indent)+s)
+}
+
+// open starts an object or list, prefixed with a key for fields.
+func (d *docWriter) open(prefix, bracket string) {
+	d.entry(prefix + bracket)
+	d.indent++
+	d.first = true
+}
+
+// close ends an object or list, on the line it was started on if it is empty.
+func (d *docWriter) close(bracket string) {
+	d.indent--
+	if d.first {
+		d.lines[len(d.lines)-1] += bracket
+	} else {
+		d.lines = append(d.lines, strings.Repeat("  ", d.indent)+bracket)
+	}
+	d.first = false
+}
+
+func key(name string) string {
+	key, _ := marshal(name)
+	return string(key) + ": "
+}
+
+// value returns the compact JSON of a value.
+func (d *docWriter) value(v any) string {
+	data, err := marshal(v)
+	if err != nil && d.err == nil {
+		d.err = fmt.Errorf("encode json: %w", err)
+	}
+	return string(data)
+}
+
+func (d *docWriter) field(name string, v any) {
+	d.entry(key(name) + d.value(v))
+}
+
+// object writes an object with a field per line, sorted by name.
+func (d *docWriter) object(name string, fields map[string]any) {
+	d.open(key(name), "{")
+	for _, k := range slices.Sorted(maps.Keys(fields)) {
+		d.field(k, fields[k])
+	}
+	d.close("}")
+}
+
+// list writes a list with an element per line.
+func (d *docWriter) list(name string, elements []any) {
+	d.open(key(name), "[")
+	for _, e := range elements {
+		d.entry(d.value(e))
+	}
+	d.close("]")
+}
+
+// layers writes layers of rows, with a row per line.
+func (d *docWriter) layers(name string, layers [][]string) {
+	d.open(key(name), "[")
+	for _, rows := range layers {
+		d.open("", "[")
+		for _, row := range rows {
+			d.entry(d.value(row))
+		}
+		d.close("]")
+	}
+	d.close("]")
+}
+
+// writeTo writes the lines of the document to w.
+func (d *docWriter) writeTo(w io.Writer) error {
+	if d.err != nil {
+		return d.err
+	}
+	_, err := io.WriteString(w, strings.Join(d.lines, "\n")+"\n")
+	return err
+}
@@ -0,0 +1,149 @@
+package text
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// basicTypes are the types metadata values may be built from, by name.
+var basicTypes = map[string]reflect.Type{
+	"any":     reflect.TypeFor[any](),
+	"bool":    reflect.TypeFor[bool](),
+	"string":  reflect.TypeFor[string](),
+	"int":     reflect.TypeFor[int](),
+	"int8":    reflect.TypeFor[int8](),
+	"int16":   reflect.TypeFor[int16](),
+	"int32":   reflect.TypeFor[int32](),
+	"int64":   reflect.TypeFor[int64](),
+	"uint":    reflect.TypeFor[uint](),
+	"uint8":   reflect.TypeFor[uint8](),
+	"uint16":  reflect.TypeFor[uint16](),
+	"uint32":  reflect.TypeFor[uint32](),
+	"uint64":  reflect.TypeFor[uint64](),
+	"float32": reflect.TypeFor[float32](),
+	"float64": reflect.TypeFor[float64](),
+}
+
+// typeName returns the name of a type as written in documents, such as int,
+// [3]int or map[string]any. Only types built from basic types, slices,
+// arrays and maps with string keys have a name.
+func typeName(t reflect.Type) (string, error) {
+	switch t.Kind() {
+	case reflect.Slice:
+		elem, err := typeName(t.Elem())
+		return "[]" + elem, err
+	case reflect.Array:
+		elem, err := typeName(t.Elem())
+		return "[" + strconv.Itoa(t.Len()) + "]" + elem, err
+	case reflect.Map:
+		if t.Key() != reflect.TypeFor[string]() {
+			return "", fmt.Errorf("unsupported type %v", t)
+		}
+		elem, err := typeName(t.Elem())
+		return "map[string]" + elem, err
+	case reflect.Interface:
+		if t.NumMethod() == 0 {
+			return "any", nil
+		}
+	default:
+		if basicTypes[t.Name()] == t {
+			return t.Name(), nil
+		}
+	}
+	return "", fmt.Errorf("unsupported type %v", t)
+}
+
+// parseType returns the type of a name returned by typeName.
+func parseType(name string) (reflect.Type, error) {
+	switch {
+	case strings.HasPrefix(name, "[]"):
+		elem, err := parseType(name[2:])
+		if err != nil {
+			return nil, err
+		}
+		return reflect.SliceOf(elem), nil
+	case strings.HasPrefix(name, "["):
+		n, elemName, ok := strings.Cut(name[1:], "]")
+		length, err := strconv.Atoi(n)
+		if !ok || err != nil || length < 0 {
+			return nil, fmt.Errorf("invalid type %q", name)
+		}
+		elem, err := parseType(elemName)
+		if err != nil {
+			return nil, err
+		}
+		return reflect.ArrayOf(length, elem), nil
+	case strings.HasPrefix(name, "map[string]"):
+		elem, err := parseType(name[len("map[string]"):])
+		if err != nil {
+			return nil, err
+		}
+		return reflect.MapOf(reflect.TypeFor[string](), elem), nil
+	}
+	if t, ok := basicTypes[name]; ok {
+		return t, nil
+	}
+	return nil, fmt.Errorf("invalid type %q", name)
+}
+
+// convert converts a value parsed from SNBT to the type t.
+func convert(v any, t reflect.Type) (reflect.Value, error) {
+	if t.Kind() == reflect.Interface {
+		return reflect.ValueOf(&v).Elem(), nil
+	}
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() {
+		return reflect.Value{}, fmt.Errorf("cannot convert nil to %v", t)
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		if rv.CanUint() {
+			return reflect.ValueOf(rv.Uint() != 0), nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		if rv.CanInt() || rv.CanUint() || rv.CanFloat() {
+			return rv.Convert(t), nil
+		}
+	case reflect.String:
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+			break
+		}
+		out := reflect.New(t).Elem()
+		if t.Kind() == reflect.Slice {
+			out = reflect.MakeSlice(t, rv.Len(), rv.Len())
+		} else if rv.Len() != t.Len() {
+			return reflect.Value{}, fmt.Errorf("cannot convert %d elements to %v", rv.Len(), t)
+		}
+		for i := range rv.Len() {
+			e, err := convert(rv.Index(i).Interface(), t.Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			out.Index(i).Set(e)
+		}
+		return out, nil
+	case reflect.Map:
+		m, ok := v.(map[string]any)
+		if !ok {
+			break
+		}
+		out := reflect.MakeMapWithSize(t, len(m))
+		for k, e := range m {
+			ev, err := convert(e, t.Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			out.SetMapIndex(reflect.ValueOf(k), ev)
+		}
+		return out, nil
+	}
+	if rv.Type() == t {
+		return rv, nil
+	}
+	return reflect.Value{}, fmt.Errorf("cannot convert %T to %v", v, t)
+}
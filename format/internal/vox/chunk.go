@@ -0,0 +1,261 @@
+package vox
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+// chunk is a chunk of a .vox file.
+type chunk struct {
+	id       string
+	content  []byte
+	children []byte
+}
+
+// readChunk reads the chunk at the start of data and returns it along with
+// the data following it.
+func readChunk(data []byte) (chunk, []byte, error) {
+	if len(data) < 12 {
+		return chunk{}, nil, fmt.Errorf("truncated chunk header")
+	}
+	id := string(data[:4])
+	contentSize := int(binary.LittleEndian.Uint32(data[4:]))
+	childrenSize := int(binary.LittleEndian.Uint32(data[8:]))
+	data = data[12:]
+	if contentSize < 0 || childrenSize < 0 || contentSize+childrenSize > len(data) {
+		return chunk{}, nil, fmt.Errorf("chunk %q exceeds the file", id)
+	}
+	return chunk{
+		id:       id,
+		content:  data[:contentSize],
+		children: data[contentSize : contentSize+childrenSize],
+	}, data[contentSize+childrenSize:], nil
+}
+
+// writeChunk appends a chunk to buf.
+func writeChunk(buf *bytes.Buffer, id string, content, children []byte) {
+	buf.WriteString(id)
+	_ = binary.Write(buf, binary.LittleEndian, uint32(len(content)))
+	_ = binary.Write(buf, binary.LittleEndian, uint32(len(children)))
+	buf.Write(content)
+	buf.Write(children)
+}
+
+// reader reads the values of a chunk.
+type reader struct {
+	data []byte
+	err  error
+}
+
+func (r *reader) int32() int32 {
+	if r.err != nil {
+		return 0
+	}
+	if len(r.data) < 4 {
+		r.err = fmt.Errorf("unexpected end of chunk")
+		return 0
+	}
+	v := int32(binary.LittleEndian.Uint32(r.data))
+	r.data = r.data[4:]
+	return v
+}
+
+func (r *reader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || len(r.data) < n {
+		r.err = fmt.Errorf("unexpected end of chunk")
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *reader) string() string {
+	return string(r.bytes(int(r.int32())))
+}
+
+func (r *reader) dict() map[string]string {
+	n := int(r.int32())
+	dict := make(map[string]string)
+	for i := 0; i < n && r.err == nil; i++ {
+		key := r.string()
+		dict[key] = r.string()
+	}
+	return dict
+}
+
+// writer writes the values of a chunk.
+type writer struct {
+	bytes.Buffer
+}
+
+func (w *writer) int32(v int32) {
+	_ = binary.Write(&w.Buffer, binary.LittleEndian, v)
+}
+
+func (w *writer) string(s string) {
+	w.int32(int32(len(s)))
+	w.WriteString(s)
+}
+
+// dict writes a dictionary of key-value pairs in the order passed.
+func (w *writer) dict(pairs ...string) {
+	w.int32(int32(len(pairs) / 2))
+	for _, s := range pairs {
+		w.string(s)
+	}
+}
+
+// node is a node of the scene graph of a .vox file.
+type node struct {
+	id string
+	// children holds the child nodes of nTRN and nGRP nodes.
+	children []int32
+	// models holds the models of nSHP nodes.
+	models []int32
+	// transform holds the transform of nTRN nodes.
+	transform transform
+}
+
+// transform places a model in the scene: a point p of a model is placed at
+// rotation * p + translation.
+type transform struct {
+	rotation    [3][3]int
+	translation [3]int
+}
+
+var identity = transform{rotation: [3][3]int{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}}}
+
+// apply returns the position of p after the transform.
+func (t transform) apply(p [3]int) [3]int {
+	var out [3]int
+	for i := range 3 {
+		out[i] = t.rotation[i][0]*p[0] + t.rotation[i][1]*p[1] + t.rotation[i][2]*p[2] + t.translation[i]
+	}
+	return out
+}
+
+// then returns the transform that applies t and then parent.
+func (t transform) then(parent transform) transform {
+	var out transform
+	for i := range 3 {
+		for j := range 3 {
+			for k := range 3 {
+				out.rotation[i][j] += parent.rotation[i][k] * t.rotation[k][j]
+			}
+		}
+	}
+	out.translation = parent.apply(t.translation)
+	return out
+}
+
+// readNode parses an nTRN, nGRP or nSHP chunk. It returns the ID of the node.
+func readNode(c chunk) (int32, node, error) {
+	r := &reader{data: c.content}
+	nodeID := r.int32()
+	r.dict()
+	n := node{id: c.id, transform: identity}
+	switch c.id {
+	case "nTRN":
+		n.children = []int32{r.int32()}
+		r.int32() // Reserved.
+		r.int32() // Layer.
+		frames := int(r.int32())
+		for i := 0; i < frames && r.err == nil; i++ {
+			frame := r.dict()
+			if i > 0 {
+				continue
+			}
+			if t, ok := frame["_t"]; ok {
+				fields := strings.Fields(t)
+				for axis := 0; axis < 3 && axis < len(fields); axis++ {
+					n.transform.translation[axis], _ = strconv.Atoi(fields[axis])
+				}
+			}
+			if rot, ok := frame["_r"]; ok {
+				v, _ := strconv.Atoi(rot)
+				n.transform.rotation = rotation(byte(v))
+			}
+		}
+	case "nGRP":
+		count := int(r.int32())
+		for i := 0; i < count && r.err == nil; i++ {
+			n.children = append(n.children, r.int32())
+		}
+	case "nSHP":
+		count := int(r.int32())
+		for i := 0; i < count && r.err == nil; i++ {
+			n.models = append(n.models, r.int32())
+			r.dict()
+		}
+	}
+	if r.err != nil {
+		return 0, node{}, fmt.Errorf("read %s: %w", c.id, r.err)
+	}
+	return nodeID, n, nil
+}
+
+// rotation decodes a rotation stored as a byte: bits 0-1 hold the column of
+// the non-zero entry of the first row, bits 2-3 that of the second row, and
+// bits 4-6 the signs of the rows.
+func rotation(b byte) [3][3]int {
+	var m [3][3]int
+	first, second := int(b&3), int(b>>2&3)
+	third := 3 - first - second
+	for row, col := range [3]int{first, second, third} {
+		if col < 0 || col > 2 {
+			return identity.rotation
+		}
+		m[row][col] = 1
+		if b>>(4+row)&1 == 1 {
+			m[row][col] = -1
+		}
+	}
+	return m
+}
+
+// defaultPalette returns the palette of files without an RGBA chunk, indexed
+// by colour index: a 6x6x6 colour cube followed by ramps of red, green, blue
+// and grey.
+func defaultPalette() [256]color.RGBA {
+	var palette [256]color.RGBA
+	levels := []uint8{0xFF, 0xCC, 0x99, 0x66, 0x33, 0x00}
+	i := 1
+	for _, r := range levels {
+		for _, g := range levels {
+			for _, b := range levels {
+				if i == 216 {
+					break
+				}
+				palette[i] = color.RGBA{R: r, G: g, B: b, A: 0xFF}
+				i++
+			}
+		}
+	}
+	ramp := []uint8{0xEE, 0xDD, 0xBB, 0xAA, 0x88, 0x77, 0x55, 0x44, 0x22, 0x11}
+	for channel := range 4 {
+		for _, v := range ramp {
+			c := color.RGBA{A: 0xFF}
+			switch channel {
+			case 0:
+				c.R = v
+			case 1:
+				c.G = v
+			case 2:
+				c.B = v
+			default:
+				c.R, c.G, c.B = v, v, v
+			}
+			palette[i] = c
+			i++
+		}
+	}
+	return palette
+}
@@ -0,0 +1,436 @@
+package vox
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+	"io"
+	"strconv"
+
+	"github.com/oriumgames/schem/format/internal/base"
+)
+
+const (
+	magic = "VOX "
+	// version is the file version written, as written by MagicaVoxel 0.99.
+	version = 150
+	// maxModelSize is the largest size of a model along any axis.
+	maxModelSize = 256
+	// dataVersion is the data version of schematics read, which is that of
+	// the block names of the default colour table.
+	dataVersion = 4665
+	// colorsKey is the metadata key of the palette colours read for blocks
+	// whose colour in the colour table differs, as a map from block state
+	// strings to RGBA values.
+	colorsKey = "Colors"
+	// maxShift is the number of bits quantize drops from a colour to leave a
+	// single colour: the 8 bits of the colour channels, then the 8 of alpha.
+	maxShift = 16
+)
+
+// Options configures how blocks are mapped to and from colours.
+type Options struct {
+	// Colors maps blocks to colours. When reading, every colour of the
+	// palette becomes the block of the nearest colour. When writing, blocks
+	// missing from the table are written in grey. If nil, the default colour
+	// table is used.
+	Colors base.ColorTable
+}
+
+func (opts Options) colors() base.ColorTable {
+	if opts.Colors == nil {
+		return base.DefaultColors()
+	}
+	return opts.Colors
+}
+
+// model is a model of a .vox file.
+type model struct {
+	size   [3]int
+	voxels []byte
+}
+
+// Read reads a MagicaVoxel .vox file using the default colour table.
+func Read(r io.Reader) (base.Schematic, error) {
+	return ReadWithOptions(r, Options{})
+}
+
+// Write writes a schematic as a MagicaVoxel .vox file using the default
+// colour table.
+func Write(w io.Writer, s base.Schematic) error {
+	return WriteWithOptions(w, s, Options{})
+}
+
+// ReadWithOptions reads a MagicaVoxel .vox file. Models are placed as in the
+// scene of the file, with the Z axis of MagicaVoxel pointing up.
+func ReadWithOptions(r io.Reader, opts Options) (base.Schematic, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("read data: %w", err)
+	}
+	if len(data) < 8 || string(data[:4]) != magic {
+		return nil, fmt.Errorf("not a vox file")
+	}
+	main, _, err := readChunk(data[8:])
+	if err != nil {
+		return nil, err
+	}
+	if main.id != "MAIN" {
+		return nil, fmt.Errorf("expected MAIN chunk, got %q", main.id)
+	}
+
+	var (
+		models  []model
+		size    [3]int
+		palette = defaultPalette()
+		nodes   = make(map[int32]node)
+	)
+	for rest := main.children; len(rest) > 0; {
+		var c chunk
+		if c, rest, err = readChunk(rest); err != nil {
+			return nil, err
+		}
+		switch c.id {
+		case "SIZE":
+			r := &reader{data: c.content}
+			size = [3]int{int(r.int32()), int(r.int32()), int(r.int32())}
+			if r.err != nil {
+				return nil, fmt.Errorf("read SIZE: %w", r.err)
+			}
+		case "XYZI":
+			r := &reader{data: c.content}
+			voxels := r.bytes(int(r.int32()) * 4)
+			if r.err != nil {
+				return nil, fmt.Errorf("read XYZI: %w", r.err)
+			}
+			models = append(models, model{size: size, voxels: voxels})
+		case "RGBA":
+			for i := 0; i < 255 && i*4+3 < len(c.content); i++ {
+				palette[i+1] = color.RGBA{R: c.content[i*4], G: c.content[i*4+1], B: c.content[i*4+2], A: c.content[i*4+3]}
+			}
+		case "nTRN", "nGRP", "nSHP":
+			id, n, err := readNode(c)
+			if err != nil {
+				return nil, err
+			}
+			nodes[id] = n
+		}
+	}
+
+	voxels := placeVoxels(models, nodes)
+	if len(voxels) == 0 {
+		s := base.New(0, 0, 0, "vox")
+		s.SetDataVersion(dataVersion)
+		return s, nil
+	}
+
+	minPos, maxPos := voxels[0].pos, voxels[0].pos
+	for _, v := range voxels {
+		for axis := range 3 {
+			minPos[axis] = min(minPos[axis], v.pos[axis])
+			maxPos[axis] = max(maxPos[axis], v.pos[axis])
+		}
+	}
+
+	// MagicaVoxel has Z pointing up, so its Y axis becomes the (negated) Z
+	// axis of the schematic.
+	s := base.New(maxPos[0]-minPos[0]+1, maxPos[2]-minPos[2]+1, maxPos[1]-minPos[1]+1, "vox")
+	s.SetDataVersion(dataVersion)
+	colors := opts.colors()
+	var states [256]*base.BlockState
+	read := make(map[string][4]uint8)
+	for _, v := range voxels {
+		if states[v.color] == nil {
+			state := colors.Nearest(palette[v.color])
+			states[v.color] = &state
+
+			// Keep the colour of the file if it is not that of the block, so
+			// that writing the schematic again reproduces it.
+			c := palette[v.color]
+			key := state.String()
+			if _, ok := read[key]; !ok {
+				if tc, _ := colors.Color(&state); tc != c {
+					read[key] = [4]uint8{c.R, c.G, c.B, c.A}
+				}
+			}
+		}
+		s.SetBlock(v.pos[0]-minPos[0], v.pos[2]-minPos[2], maxPos[1]-v.pos[1], states[v.color])
+	}
+	if len(read) > 0 {
+		s.SetMetadata(colorsKey, read)
+	}
+	return s, nil
+}
+
+// voxel is a voxel placed in the scene.
+type voxel struct {
+	pos   [3]int
+	color byte
+}
+
+// placeVoxels returns the voxels of the models placed in the scene. Files
+// without a scene graph have their models placed at the origin.
+func placeVoxels(models []model, nodes map[int32]node) []voxel {
+	var voxels []voxel
+	add := func(m model, t transform, centred bool) {
+		for i := 0; i+3 < len(m.voxels); i += 4 {
+			p := [3]int{int(m.voxels[i]), int(m.voxels[i+1]), int(m.voxels[i+2])}
+			if centred {
+				for axis := range 3 {
+					p[axis] -= m.size[axis] / 2
+				}
+			}
+			voxels = append(voxels, voxel{pos: t.apply(p), color: m.voxels[i+3]})
+		}
+	}
+
+	if _, ok := nodes[0]; !ok {
+		for _, m := range models {
+			add(m, identity, false)
+		}
+		return voxels
+	}
+
+	visited := make(map[int32]bool)
+	var walk func(id int32, t transform)
+	walk = func(id int32, t transform) {
+		n, ok := nodes[id]
+		if !ok || visited[id] {
+			return
+		}
+		visited[id] = true
+		if n.id == "nTRN" {
+			t = n.transform.then(t)
+		}
+		for _, child := range n.children {
+			walk(child, t)
+		}
+		for _, m := range n.models {
+			if m >= 0 && int(m) < len(models) {
+				add(models[m], t, true)
+			}
+		}
+	}
+	walk(0, identity)
+	return voxels
+}
+
+// WriteWithOptions writes a schematic as a MagicaVoxel .vox file. Schematics
+// larger than 256 blocks along any axis are split into several models, which
+// are placed next to each other in the scene, and parts holding only air are
+// left out.
+func WriteWithOptions(w io.Writer, s base.Schematic, opts Options) error {
+	width, height, length := s.Dimensions()
+	// The X, Y and Z axes of the schematic become the X, Z and negated Y axes
+	// of MagicaVoxel.
+	size := [3]int{width, length, height}
+
+	palette, indices := buildPalette(s, opts.colors(), readColors(s))
+
+	var models []model
+	var origins [][3]int
+	for ox := 0; ox < size[0]; ox += maxModelSize {
+		for oy := 0; oy < size[1]; oy += maxModelSize {
+			for oz := 0; oz < size[2]; oz += maxModelSize {
+				m := model{size: [3]int{
+					min(maxModelSize, size[0]-ox),
+					min(maxModelSize, size[1]-oy),
+					min(maxModelSize, size[2]-oz),
+				}}
+				for vz := range m.size[2] {
+					for vy := range m.size[1] {
+						for vx := range m.size[0] {
+							x, y, z := ox+vx, oz+vz, length-1-(oy+vy)
+							if x >= width || y >= height || z < 0 {
+								continue
+							}
+							state := s.Block(x, y, z)
+							if state == nil {
+								continue
+							}
+							if idx := indices[state.String()]; idx != 0 {
+								m.voxels = append(m.voxels, byte(vx), byte(vy), byte(vz), idx)
+							}
+						}
+					}
+				}
+				if len(m.voxels) == 0 {
+					continue
+				}
+				models = append(models, m)
+				origins = append(origins, [3]int{ox, oy, oz})
+			}
+		}
+	}
+	if len(models) == 0 {
+		// The scene must hold at least one model.
+		models, origins = []model{{size: [3]int{1, 1, 1}}}, [][3]int{{}}
+	}
+
+	var children bytes.Buffer
+	for _, m := range models {
+		var sizeChunk, xyzi writer
+		for _, v := range m.size {
+			sizeChunk.int32(int32(v))
+		}
+		writeChunk(&children, "SIZE", sizeChunk.Bytes(), nil)
+		xyzi.int32(int32(len(m.voxels) / 4))
+		xyzi.Write(m.voxels)
+		writeChunk(&children, "XYZI", xyzi.Bytes(), nil)
+	}
+	writeScene(&children, models, origins)
+
+	rgba := make([]byte, 256*4)
+	for i := 1; i < 256; i++ {
+		c := palette[i]
+		copy(rgba[(i-1)*4:], []byte{c.R, c.G, c.B, c.A})
+	}
+	writeChunk(&children, "RGBA", rgba, nil)
+
+	var out bytes.Buffer
+	out.WriteString(magic)
+	var header writer
+	header.int32(version)
+	out.Write(header.Bytes())
+	writeChunk(&out, "MAIN", nil, children.Bytes())
+	if _, err := w.Write(out.Bytes()); err != nil {
+		return fmt.Errorf("write data: %w", err)
+	}
+	return nil
+}
+
+// writeScene writes a scene graph placing every model at its origin: a root
+// transform holding a group, which holds a transform and a shape per model.
+func writeScene(buf *bytes.Buffer, models []model, origins [][3]int) {
+	var root writer
+	root.int32(0)
+	root.dict()
+	root.int32(1)
+	root.int32(-1)
+	root.int32(-1)
+	root.int32(1)
+	root.dict()
+	writeChunk(buf, "nTRN", root.Bytes(), nil)
+
+	var group writer
+	group.int32(1)
+	group.dict()
+	group.int32(int32(len(models)))
+	for i := range models {
+		group.int32(int32(2 + 2*i))
+	}
+	writeChunk(buf, "nGRP", group.Bytes(), nil)
+
+	for i, m := range models {
+		// Models are centred on their translation.
+		var t [3]int
+		for axis := range 3 {
+			t[axis] = origins[i][axis] + m.size[axis]/2
+		}
+		var trn writer
+		trn.int32(int32(2 + 2*i))
+		trn.dict()
+		trn.int32(int32(3 + 2*i))
+		trn.int32(-1)
+		trn.int32(0)
+		trn.int32(1)
+		trn.dict("_t", strconv.Itoa(t[0])+" "+strconv.Itoa(t[1])+" "+strconv.Itoa(t[2]))
+		writeChunk(buf, "nTRN", trn.Bytes(), nil)
+
+		var shp writer
+		shp.int32(int32(3 + 2*i))
+		shp.dict()
+		shp.int32(1)
+		shp.int32(int32(i))
+		shp.dict()
+		writeChunk(buf, "nSHP", shp.Bytes(), nil)
+	}
+}
+
+// readColors returns the palette colours a schematic was read with, by block
+// state string.
+func readColors(s base.Schematic) map[string][4]uint8 {
+	read, _ := s.Metadata()[colorsKey].(map[string][4]uint8)
+	return read
+}
+
+// buildPalette assigns a colour index to every block of the schematic. Air,
+// structure voids and missing blocks get index 0, which holds no voxel.
+// Blocks are written in the colour they were read with, if any, or in their
+// colour in the colour table. If the blocks have more than 255 distinct
+// colours, colours are reduced in precision until they fit.
+func buildPalette(s base.Schematic, colors base.ColorTable, read map[string][4]uint8) ([256]color.RGBA, map[string]byte) {
+	width, height, length := s.Dimensions()
+	blockColors := make(map[string]color.RGBA)
+	var order []string
+	for y := range height {
+		for z := range length {
+			for x := range width {
+				state := s.Block(x, y, z)
+				if state == nil || base.IsEmpty(state.Name) {
+					continue
+				}
+				key := state.String()
+				if _, ok := blockColors[key]; ok {
+					continue
+				}
+				c, ok := colors.Color(state)
+				if !ok {
+					c = base.UnknownColor
+				}
+				if rc, ok := read[key]; ok {
+					c = color.RGBA{R: rc[0], G: rc[1], B: rc[2], A: rc[3]}
+				}
+				blockColors[key] = c
+				order = append(order, key)
+			}
+		}
+	}
+
+	// Quantizing by maxShift bits leaves a single colour, which always fits.
+	for shift := uint(0); shift < maxShift; shift++ {
+		if palette, indices, ok := fitPalette(order, blockColors, shift); ok {
+			return palette, indices
+		}
+	}
+	palette, indices, _ := fitPalette(order, blockColors, maxShift)
+	return palette, indices
+}
+
+// fitPalette assigns a palette index to the colours of the blocks passed,
+// quantized by shift bits. It returns false if they have more than 255
+// distinct colours.
+func fitPalette(order []string, blockColors map[string]color.RGBA, shift uint) ([256]color.RGBA, map[string]byte, bool) {
+	var palette [256]color.RGBA
+	indices := make(map[string]byte, len(order))
+	colorIndices := make(map[color.RGBA]byte)
+	for _, key := range order {
+		c := quantize(blockColors[key], shift)
+		idx, ok := colorIndices[c]
+		if !ok {
+			if len(colorIndices) == 255 {
+				return palette, nil, false
+			}
+			idx = byte(len(colorIndices) + 1)
+			colorIndices[c] = idx
+			palette[idx] = c
+		}
+		indices[key] = idx
+	}
+	return palette, indices, true
+}
+
+// quantize drops the lowest bits of a colour, rounding to the centre of the
+// range they cover. The first 8 bits are dropped from the colour channels,
+// the bits above those from alpha.
+func quantize(c color.RGBA, shift uint) color.RGBA {
+	q := func(v uint8, shift uint) uint8 {
+		if shift == 0 {
+			return v
+		}
+		return v>>shift<<shift | 1<<(shift-1)
+	}
+	rgb := min(shift, 8)
+	alpha := min(shift-rgb, 8)
+	return color.RGBA{R: q(c.R, rgb), G: q(c.G, rgb), B: q(c.B, rgb), A: q(c.A, alpha)}
+}
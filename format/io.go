@@ -7,9 +7,14 @@ import (
 	"sort"
 
 	"github.com/oriumgames/schem/format/internal/axiom"
+	"github.com/oriumgames/schem/format/internal/blueprint"
+	"github.com/oriumgames/schem/format/internal/gadgets"
 	"github.com/oriumgames/schem/format/internal/litematica"
 	"github.com/oriumgames/schem/format/internal/mcedit"
 	"github.com/oriumgames/schem/format/internal/sponge"
+	"github.com/oriumgames/schem/format/internal/structure"
+	"github.com/oriumgames/schem/format/internal/text"
+	"github.com/oriumgames/schem/format/internal/vox"
 )
 
 // FormatReader is a function that reads a schematic from an io.Reader.
@@ -19,23 +24,37 @@ type FormatReader func(io.Reader) (Schematic, error)
 type FormatWriter func(io.Writer, Schematic) error
 
 var formatReaders = map[string]FormatReader{
-	"axiom":         axiom.Read,
-	"mcedit":        mcedit.Read,
-	"sponge_v1":     sponge.ReadV1,
-	"sponge_v2":     sponge.ReadV2,
-	"sponge_v3":     sponge.ReadV3,
-	"litematica_v6": litematica.ReadV6,
-	"litematica_v7": litematica.ReadV7,
+	"axiom":            axiom.Read,
+	"mcedit":           mcedit.Read,
+	"sponge_v1":        sponge.ReadV1,
+	"sponge_v2":        sponge.ReadV2,
+	"sponge_v3":        sponge.ReadV3,
+	"litematica_v4":    litematica.ReadV4,
+	"litematica_v5":    litematica.ReadV5,
+	"litematica_v6":    litematica.ReadV6,
+	"litematica_v7":    litematica.ReadV7,
+	"structure":        structure.Read,
+	"vox":              vox.Read,
+	"json":             text.Read,
+	"blueprint":        blueprint.Read,
+	"create":           structure.ReadCreate,
+	"building_gadgets": gadgets.Read,
 }
 
 var formatWriters = map[string]FormatWriter{
-	"axiom":         axiom.Write,
-	"mcedit":        mcedit.Write,
-	"sponge_v1":     sponge.WriteV1,
-	"sponge_v2":     sponge.WriteV2,
-	"sponge_v3":     sponge.WriteV3,
-	"litematica_v6": litematica.WriteV6,
-	"litematica_v7": litematica.WriteV7,
+	"axiom":            axiom.Write,
+	"mcedit":           mcedit.Write,
+	"sponge_v1":        sponge.WriteV1,
+	"sponge_v2":        sponge.WriteV2,
+	"sponge_v3":        sponge.WriteV3,
+	"litematica_v6":    litematica.WriteV6,
+	"litematica_v7":    litematica.WriteV7,
+	"structure":        structure.Write,
+	"vox":              vox.Write,
+	"json":             text.Write,
+	"blueprint":        blueprint.Write,
+	"create":           structure.WriteCreate,
+	"building_gadgets": gadgets.Write,
 }
 
 // Read reads data from r, detects the schematic format, and returns the parsed schematic.
@@ -68,6 +87,9 @@ func ReadFormat(r io.Reader, formatID string) (Schematic, error) {
 	if err != nil {
 		return nil, fmt.Errorf("read %s: %w", formatID, err)
 	}
+	if r, ok := schem.(interface{ ResetChanged() }); ok {
+		r.ResetChanged()
+	}
 	return schem, nil
 }
 
@@ -0,0 +1,10 @@
+package format
+
+import "github.com/oriumgames/schem/format/internal/mcedit"
+
+// UnmappedStates returns the block states of a schematic that have no legacy
+// ID and are written as air in MCEdit format, sorted. Analyze reports them
+// too, and WriteStrict refuses to write them.
+func UnmappedStates(schem Schematic) []string {
+	return mcedit.UnmappedStates(schem)
+}
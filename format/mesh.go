@@ -0,0 +1,38 @@
+package format
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/oriumgames/schem/format/internal/mesh"
+)
+
+// MeshOptions configures how WriteOBJ and WriteGLB turn a schematic into a
+// mesh.
+type MeshOptions = mesh.Options
+
+// Atlas maps blocks to regions of a texture image, for textured meshes.
+type Atlas = mesh.Atlas
+
+// UVRect is a region of a texture atlas, in texture coordinates ranging from
+// 0 to 1 with the origin at the top left of the image.
+type UVRect = mesh.UVRect
+
+// WriteOBJ writes a mesh of the schematic as a Wavefront OBJ file to obj and
+// its materials to mtl, which the OBJ file references as mtlName. The mesh
+// has a corner of the schematic at the origin and is one unit per block.
+func WriteOBJ(obj, mtl io.Writer, mtlName string, schem Schematic, opts MeshOptions) error {
+	if err := mesh.WriteOBJ(obj, mtl, mtlName, schem, opts); err != nil {
+		return fmt.Errorf("write obj: %w", err)
+	}
+	return nil
+}
+
+// WriteGLB writes a mesh of the schematic as a binary glTF 2.0 file. The
+// mesh has a corner of the schematic at the origin and is one unit per block.
+func WriteGLB(w io.Writer, schem Schematic, opts MeshOptions) error {
+	if err := mesh.WriteGLB(w, schem, opts); err != nil {
+		return fmt.Errorf("write glb: %w", err)
+	}
+	return nil
+}
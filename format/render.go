@@ -0,0 +1,34 @@
+package format
+
+import (
+	"fmt"
+	"image"
+	"io"
+
+	"github.com/oriumgames/schem/format/internal/render"
+)
+
+// RenderOptions configures how Render and WritePNG draw a schematic.
+type RenderOptions = render.Options
+
+// View selects how a schematic is rendered.
+type View = render.View
+
+const (
+	ViewTopDown   = render.TopDown
+	ViewIsometric = render.Isometric
+)
+
+// Render draws a preview image of the schematic on the CPU, either as a
+// top-down map or as an isometric view.
+func Render(schem Schematic, opts RenderOptions) *image.RGBA {
+	return render.Render(schem, opts)
+}
+
+// WritePNG draws a preview image of the schematic and writes it as a PNG.
+func WritePNG(w io.Writer, schem Schematic, opts RenderOptions) error {
+	if err := render.WritePNG(w, schem, opts); err != nil {
+		return fmt.Errorf("write png: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package format
+
+import "testing"
+
+// TestRenderSize checks that the longest side of rendered images never
+// exceeds RenderOptions.Size.
+func TestRenderSize(t *testing.T) {
+	tests := []struct {
+		width, length int
+		size          int
+		wantW, wantH  int
+	}{
+		{5, 3, 0, 255, 153},
+		{2000, 3, 0, 256, 1},
+		{300, 150, 256, 256, 128},
+		{40, 1000, 100, 4, 100},
+	}
+	for _, tt := range tests {
+		s := New(tt.width, 1, tt.length, "")
+		for z := range tt.length {
+			for x := range tt.width {
+				s.SetBlock(x, 0, z, &BlockState{Name: "minecraft:stone"})
+			}
+		}
+		for _, view := range []View{ViewTopDown, ViewIsometric} {
+			img := Render(s, RenderOptions{View: view, Size: tt.size})
+			b := img.Bounds()
+			size := tt.size
+			if size == 0 {
+				size = 256
+			}
+			if max(b.Dx(), b.Dy()) > size {
+				t.Errorf("%dx%d, view %v: image is %dx%d, larger than %d", tt.width, tt.length, view, b.Dx(), b.Dy(), size)
+			}
+			if view == ViewTopDown && (b.Dx() != tt.wantW || b.Dy() != tt.wantH) {
+				t.Errorf("%dx%d top-down: image is %dx%d, want %dx%d", tt.width, tt.length, b.Dx(), b.Dy(), tt.wantW, tt.wantH)
+			}
+		}
+	}
+}
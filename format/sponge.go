@@ -0,0 +1,43 @@
+package format
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/oriumgames/schem/format/internal/sponge"
+)
+
+// SpongeOptions configures how WriteSponge writes a schematic.
+type SpongeOptions = sponge.Options
+
+// BiomeLayout selects how biomes are stored in a Sponge schematic.
+type BiomeLayout = sponge.BiomeLayout
+
+// BiomeSampling selects the biome of a column when writing 2D biomes.
+type BiomeSampling = sponge.BiomeSampling
+
+// PaletteOrder selects the order of the block and biome palettes.
+type PaletteOrder = sponge.PaletteOrder
+
+const (
+	BiomesDefault = sponge.BiomesDefault
+	Biomes3D      = sponge.Biomes3D
+	Biomes2D      = sponge.Biomes2D
+	BiomesNone    = sponge.BiomesNone
+
+	SampleBottom     = sponge.SampleBottom
+	SampleTop        = sponge.SampleTop
+	SampleMostCommon = sponge.SampleMostCommon
+
+	PaletteFirstAppearance = sponge.PaletteFirstAppearance
+	PaletteSorted          = sponge.PaletteSorted
+)
+
+// WriteSponge writes the schematic as a Sponge schematic according to the
+// options passed.
+func WriteSponge(w io.Writer, schem Schematic, opts SpongeOptions) error {
+	if err := sponge.Write(w, schem, opts); err != nil {
+		return fmt.Errorf("write sponge: %w", err)
+	}
+	return nil
+}
@@ -5,4 +5,16 @@ import "github.com/oriumgames/schem/format/internal/base"
 type BlockState = base.BlockState
 type BlockEntity = base.BlockEntity
 type Entity = base.Entity
+type Info = base.Info
 type Schematic = base.Schematic
+
+// New creates an empty schematic with the given dimensions and format identifier.
+func New(width, height, length int, formatID string) Schematic {
+	return base.New(width, height, length, formatID)
+}
+
+// ParseBlockState parses a block state string such as
+// "minecraft:oak_stairs[facing=north,half=bottom]" into a BlockState.
+func ParseBlockState(s string) *BlockState {
+	return base.ParseBlockState(s)
+}
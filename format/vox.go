@@ -0,0 +1,32 @@
+package format
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/oriumgames/schem/format/internal/vox"
+)
+
+// VoxOptions configures how ReadVox and WriteVox map blocks to and from the
+// colours of a MagicaVoxel model.
+type VoxOptions = vox.Options
+
+// ReadVox reads a MagicaVoxel .vox file according to the options passed.
+// Every colour of the palette becomes the block of the nearest colour in the
+// colour table.
+func ReadVox(r io.Reader, opts VoxOptions) (Schematic, error) {
+	schem, err := vox.ReadWithOptions(r, opts)
+	if err != nil {
+		return nil, fmt.Errorf("read vox: %w", err)
+	}
+	return schem, nil
+}
+
+// WriteVox writes the schematic as a MagicaVoxel .vox file according to the
+// options passed. Every block is written in its colour in the colour table.
+func WriteVox(w io.Writer, schem Schematic, opts VoxOptions) error {
+	if err := vox.WriteWithOptions(w, schem, opts); err != nil {
+		return fmt.Errorf("write vox: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package format
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+	"testing"
+)
+
+// TestWriteVoxAlphaColors checks that blocks whose colours differ only in
+// alpha are reduced to 255 colours.
+func TestWriteVoxAlphaColors(t *testing.T) {
+	colors := ColorTable{}
+	s := New(256, 1, 1, "")
+	for x := range 256 {
+		name := fmt.Sprintf("mod:glass_%03d", x)
+		colors[name] = color.RGBA{R: 200, G: 100, B: 50, A: uint8(x)}
+		s.SetBlock(x, 0, 0, &BlockState{Name: name})
+	}
+	var buf bytes.Buffer
+	if err := WriteVox(&buf, s, VoxOptions{Colors: colors}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if _, err := ReadVox(bytes.NewReader(buf.Bytes()), VoxOptions{Colors: colors}); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+}
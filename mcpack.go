@@ -0,0 +1,343 @@
+package schem
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/df-mc/dragonfly/server/world/chunk"
+	"github.com/oriumgames/nbt"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+)
+
+// maxMCStructureSize is the largest size of a Bedrock structure along each
+// axis, as saved by structure blocks.
+var maxMCStructureSize = [3]int{64, 384, 64}
+
+// mcstructure is the NBT layout of a .mcstructure file.
+type mcstructure struct {
+	FormatVersion int32          `nbt:"format_version"`
+	Size          []int32        `nbt:"size"`
+	Structure     mcstructureNBT `nbt:"structure"`
+	Origin        []int32        `nbt:"structure_world_origin"`
+}
+
+type mcstructureNBT struct {
+	// BlockIndices holds the palette indices of the block layer and of the
+	// liquid layer, with -1 for positions left untouched.
+	BlockIndices [][]int32                     `nbt:"block_indices"`
+	Entities     []map[string]any              `nbt:"entities"`
+	Palette      map[string]mcstructurePalette `nbt:"palette"`
+}
+
+type mcstructurePalette struct {
+	BlockPalette      []map[string]any `nbt:"block_palette"`
+	BlockPositionData map[string]any   `nbt:"block_position_data"`
+}
+
+// BehaviourPackOptions configures a behaviour pack written by
+// WriteBehaviourPack.
+type BehaviourPackOptions struct {
+	// Namespace is the namespace of the structures of the pack, which are
+	// loaded in game with /structure load <namespace>:<name>. It is required.
+	Namespace string
+	// Name and Description are shown in the pack list of the game. If Name
+	// is empty, the namespace is used.
+	Name, Description string
+	// Version is the version of the pack. If zero, 1.0.0 is used. The UUIDs
+	// of the pack are derived from the namespace, so a newer export of the
+	// same pack needs a higher version for clients to download it again.
+	Version [3]int
+}
+
+// PackStructure is a structure of a behaviour pack.
+type PackStructure struct {
+	// Name is the name of the structure within the namespace of the pack.
+	Name string
+	// Structure is converted to Bedrock, honouring its PlacementOptions.
+	Structure *Structure
+}
+
+// WriteMCStructure writes the structure as a Bedrock .mcstructure file, as
+// saved by structure blocks. Blocks, block entities and entities are
+// converted to Bedrock using crocon, like when the structure is placed.
+// Positions without a block, or skipped by the PlacementOptions of the
+// Structure, are left untouched when the structure is loaded. Structures
+// larger than 64x384x64 blocks are rejected, as the game cannot load them;
+// WriteBehaviourPack splits them instead.
+func WriteMCStructure(w io.Writer, s *Structure) error {
+	size := s.Dimensions()
+	for i, n := range size {
+		if n > maxMCStructureSize[i] {
+			return fmt.Errorf("structure of %dx%dx%d exceeds the maximum of %dx%dx%d", size[0], size[1], size[2], maxMCStructureSize[0], maxMCStructureSize[1], maxMCStructureSize[2])
+		}
+	}
+	return s.writeMCStructure(w, [3]int{}, size)
+}
+
+// WriteBehaviourPack writes a Bedrock behaviour pack holding the structures
+// passed as an .mcpack archive, with a manifest.json and the structures in its
+// structures directory. Structures larger than 64x384x64 blocks are split into
+// pieces named <name>_<x>_<y>_<z> after their position in the grid of pieces.
+// Structures with a width, height or length of zero are rejected.
+func WriteBehaviourPack(w io.Writer, structures []PackStructure, opts BehaviourPackOptions) error {
+	if !validPackName(opts.Namespace) {
+		return fmt.Errorf("invalid namespace %q", opts.Namespace)
+	}
+	zw := zip.NewWriter(w)
+	add := func(name string, data []byte) error {
+		f, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate})
+		if err != nil {
+			return fmt.Errorf("create %s: %w", name, err)
+		}
+		if _, err := f.Write(data); err != nil {
+			return fmt.Errorf("write %s: %w", name, err)
+		}
+		return nil
+	}
+
+	manifest, err := json.MarshalIndent(packManifest(opts), "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode manifest: %w", err)
+	}
+	if err := add("manifest.json", append(manifest, '\n')); err != nil {
+		return err
+	}
+
+	for _, ps := range structures {
+		if !validPackName(ps.Name) {
+			return fmt.Errorf("invalid structure name %q", ps.Name)
+		}
+		if ps.Structure == nil {
+			return fmt.Errorf("structure %s: no structure", ps.Name)
+		}
+		size := ps.Structure.Dimensions()
+		if min(size[0], size[1], size[2]) <= 0 {
+			return fmt.Errorf("structure %s: empty dimensions %dx%dx%d", ps.Name, size[0], size[1], size[2])
+		}
+		var bounds [3][]int
+		for i := range bounds {
+			n := (size[i] + maxMCStructureSize[i] - 1) / maxMCStructureSize[i]
+			for j := range n + 1 {
+				bounds[i] = append(bounds[i], j*size[i]/n)
+			}
+		}
+		split := (len(bounds[0])-1)*(len(bounds[1])-1)*(len(bounds[2])-1) > 1
+		for i := range len(bounds[0]) - 1 {
+			for j := range len(bounds[1]) - 1 {
+				for k := range len(bounds[2]) - 1 {
+					name := ps.Name
+					if split {
+						name += fmt.Sprintf("_%d_%d_%d", i, j, k)
+					}
+					from := [3]int{bounds[0][i], bounds[1][j], bounds[2][k]}
+					to := [3]int{bounds[0][i+1], bounds[1][j+1], bounds[2][k+1]}
+					var buf bytes.Buffer
+					if err := ps.Structure.writeMCStructure(&buf, from, [3]int{to[0] - from[0], to[1] - from[1], to[2] - from[2]}); err != nil {
+						return fmt.Errorf("structure %s: %w", name, err)
+					}
+					if err := add("structures/"+opts.Namespace+"/"+name+".mcstructure", buf.Bytes()); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("close zip: %w", err)
+	}
+	return nil
+}
+
+// writeMCStructure writes the part of the structure of the size passed,
+// starting at from, as a .mcstructure file.
+func (s *Structure) writeMCStructure(w io.Writer, from, size [3]int) error {
+	count := size[0] * size[1] * size[2]
+	blocks, liquids := make([]int32, count), make([]int32, count)
+	palette := mcstructurePalette{BlockPalette: []map[string]any{}, BlockPositionData: map[string]any{}}
+	indices := make(map[string]int32)
+	// index returns the palette index of a block, adding it if needed.
+	index := func(b world.Block) int32 {
+		name, props := b.EncodeBlock()
+		key := name + fmt.Sprint(props)
+		idx, ok := indices[key]
+		if !ok {
+			idx = int32(len(palette.BlockPalette))
+			indices[key] = idx
+			states := maps.Clone(props)
+			if states == nil {
+				states = map[string]any{}
+			}
+			palette.BlockPalette = append(palette.BlockPalette, map[string]any{
+				"name":    name,
+				"states":  states,
+				"version": chunk.CurrentBlockVersion,
+			})
+		}
+		return idx
+	}
+
+	// Blocks are ordered by X, then Y, then Z.
+	i := 0
+	for x := range size[0] {
+		for y := range size[1] {
+			for z := range size[2] {
+				blocks[i], liquids[i] = -1, -1
+				sx, sy, sz := from[0]+x, from[1]+y, from[2]+z
+				if s.schematic.Block(sx, sy, sz) != nil {
+					if b, liq := s.At(sx, sy, sz, nil); b != nil {
+						blocks[i] = index(b)
+						if liq != nil {
+							liquids[i] = index(liq)
+						}
+						if nbter, ok := b.(world.NBTer); ok && s.schematic.BlockEntity(sx, sy, sz) != nil && !s.opts.SkipBlockEntities {
+							data := nbter.EncodeNBT()
+							data["x"], data["y"], data["z"] = int32(x), int32(y), int32(z)
+							palette.BlockPositionData[strconv.Itoa(i)] = map[string]any{"block_entity_data": data}
+						}
+					}
+				}
+				i++
+			}
+		}
+	}
+
+	data := mcstructure{
+		FormatVersion: 1,
+		Size:          []int32{int32(size[0]), int32(size[1]), int32(size[2])},
+		Structure: mcstructureNBT{
+			BlockIndices: [][]int32{blocks, liquids},
+			Entities:     s.mcstructureEntities(from, size),
+			Palette:      map[string]mcstructurePalette{"default": palette},
+		},
+		Origin: []int32{0, 0, 0},
+	}
+	if err := nbt.NewEncoderWithEncoding(w, nbt.LittleEndian).Encode(data); err != nil {
+		return fmt.Errorf("encode nbt: %w", err)
+	}
+	return nil
+}
+
+// mcstructureEntities converts the entities within the part of the structure
+// of the size passed, starting at from, to Bedrock. Entities that cannot be
+// converted are left out.
+func (s *Structure) mcstructureEntities(from, size [3]int) []map[string]any {
+	entities := []map[string]any{}
+	if s.opts.SkipEntities {
+		return entities
+	}
+	for _, ent := range s.schematic.Entities() {
+		var block [3]int
+		inside := true
+		for i := range 3 {
+			block[i] = int(math.Floor(ent.Pos[i]))
+			inside = inside && block[i] >= from[i] && block[i] < from[i]+size[i]
+		}
+		if !inside || (s.opts.Mask != nil && !s.opts.Mask(block[0], block[1], block[2])) {
+			continue
+		}
+		id, data, err := s.bedrockEntity(ent)
+		if err != nil || id == "" {
+			continue
+		}
+		data = maps.Clone(data)
+		data["identifier"] = id
+		data["Pos"] = []float32{float32(ent.Pos[0] - float64(from[0])), float32(ent.Pos[1] - float64(from[1])), float32(ent.Pos[2] - float64(from[2]))}
+		data["Rotation"] = []float32{ent.Rotation[0], ent.Rotation[1]}
+		data["Motion"] = []float32{float32(ent.Motion[0]), float32(ent.Motion[1]), float32(ent.Motion[2])}
+		data["UniqueID"] = int64(len(entities) + 1)
+		entities = append(entities, data)
+	}
+	return entities
+}
+
+type manifest struct {
+	FormatVersion int              `json:"format_version"`
+	Header        manifestHeader   `json:"header"`
+	Modules       []manifestModule `json:"modules"`
+}
+
+type manifestHeader struct {
+	Name             string `json:"name"`
+	Description      string `json:"description"`
+	UUID             string `json:"uuid"`
+	Version          [3]int `json:"version"`
+	MinEngineVersion [3]int `json:"min_engine_version"`
+}
+
+type manifestModule struct {
+	Type    string `json:"type"`
+	UUID    string `json:"uuid"`
+	Version [3]int `json:"version"`
+}
+
+// packManifest returns the manifest of a behaviour pack. Its UUIDs are
+// derived from the namespace, so that exporting the same pack again updates
+// it instead of adding a second pack.
+func packManifest(opts BehaviourPackOptions) manifest {
+	version := opts.Version
+	if version == [3]int{} {
+		version = [3]int{1, 0, 0}
+	}
+	name := opts.Name
+	if name == "" {
+		name = opts.Namespace
+	}
+	return manifest{
+		FormatVersion: 2,
+		Header: manifestHeader{
+			Name:             name,
+			Description:      opts.Description,
+			UUID:             nameUUID("header:" + opts.Namespace),
+			Version:          version,
+			MinEngineVersion: engineVersion(),
+		},
+		Modules: []manifestModule{{Type: "data", UUID: nameUUID("data:" + opts.Namespace), Version: version}},
+	}
+}
+
+// nameUUID returns a UUID derived from the SHA-1 hash of the name passed, with
+// the version and variant bits of name based UUIDs.
+func nameUUID(name string) string {
+	sum := sha1.Sum([]byte("schem:" + name))
+	sum[6] = sum[6]&0x0F | 0x50
+	sum[8] = sum[8]&0x3F | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+// engineVersion returns the major and minor version of the game supported by
+// gophertunnel as a minimum engine version.
+func engineVersion() [3]int {
+	v := [3]int{1, 21, 0}
+	parts := strings.Split(protocol.CurrentVersion, ".")
+	for i := 0; i < 2 && i < len(parts); i++ {
+		if n, err := strconv.Atoi(parts[i]); err == nil {
+			v[i] = n
+		}
+	}
+	return v
+}
+
+// validPackName reports whether a namespace or structure name only holds
+// characters the game accepts in structure names.
+func validPackName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '_', r == '-', r == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,64 @@
+package schem
+
+import (
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/oriumgames/schem/format"
+)
+
+// PlacementOptions controls which parts of a schematic a Structure places. The
+// zero value places every position of the schematic, clearing the world where
+// the schematic holds air.
+//
+// Positions left untouched keep their block, but Dragonfly still clears their
+// liquid layer, such as the water of waterlogged blocks.
+type PlacementOptions struct {
+	// IgnoreAir leaves the world untouched where the schematic holds air or
+	// no block at all, so that the schematic can be overlaid onto existing
+	// terrain.
+	IgnoreAir bool
+	// HonourStructureVoid leaves the world untouched where the schematic
+	// holds minecraft:structure_void, as Axiom and structure blocks do.
+	HonourStructureVoid bool
+	// SkipBlockEntities places blocks without the data of their block
+	// entities, such as the contents of chests or the text of signs.
+	SkipBlockEntities bool
+	// SkipEntities prevents the entities of the schematic, such as armor
+	// stands and mobs, from being spawned.
+	SkipEntities bool
+	// Biomes applies the biomes stored in the schematic to the world, so
+	// that grass, foliage and water are coloured as in the source world.
+	Biomes bool
+	// DefaultBiome is used for biomes of the schematic that have no
+	// equivalent in Dragonfly. If nil, such positions keep their biome.
+	DefaultBiome world.Biome
+	// Filter is called for every block of the schematic if non-nil. Positions
+	// for which it returns false are left untouched.
+	Filter func(x, y, z int, state *format.BlockState) bool
+	// Mask limits placement to the positions for which it returns true if
+	// non-nil. Positions are relative to the origin of the schematic.
+	Mask func(x, y, z int) bool
+}
+
+// skip reports whether the position passed, holding state, is left untouched.
+func (opts PlacementOptions) skip(x, y, z int, state *format.BlockState) bool {
+	if opts.Mask != nil && !opts.Mask(x, y, z) {
+		return true
+	}
+	if state == nil || isAir(state.Name) {
+		return opts.IgnoreAir
+	}
+	if opts.HonourStructureVoid && state.Name == "minecraft:structure_void" {
+		return true
+	}
+	return opts.Filter != nil && !opts.Filter(x, y, z, state)
+}
+
+// isAir checks if a block name is an air variant.
+func isAir(name string) bool {
+	switch name {
+	case "air", "minecraft:air", "minecraft:void_air", "minecraft:cave_air":
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,223 @@
+package schem
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/oriumgames/schem/format"
+)
+
+// Progress describes how far a Scheduler has come placing its Structure.
+type Progress struct {
+	// Placed is the number of chunk columns placed so far.
+	Placed int
+	// Total is the number of chunk columns covered by the Structure.
+	Total int
+}
+
+// Done reports whether every chunk column has been placed.
+func (p Progress) Done() bool {
+	return p.Placed >= p.Total
+}
+
+// SchedulerConfig holds the settings of a Scheduler.
+type SchedulerConfig struct {
+	// ChunksPerTick is the maximum number of chunk columns placed in a single
+	// transaction. Values of 0 or below default to 4.
+	ChunksPerTick int
+	// Interval is the time waited between two transactions. Zero defaults to
+	// a single tick (50ms).
+	Interval time.Duration
+	// Progress is called after every transaction if non-nil. It is called
+	// from the goroutine running Scheduler.Run, outside the transaction.
+	Progress func(Progress)
+	// Undo makes the Scheduler snapshot every piece before building it. The
+	// snapshot is available through Scheduler.Undo.
+	Undo bool
+}
+
+// Scheduler places a Structure in a world incrementally. The Structure is
+// split into chunk-aligned pieces, of which a limited number is built per
+// transaction, so that large schematics do not stall the world tick.
+type Scheduler struct {
+	conf   SchedulerConfig
+	w      *world.World
+	pos    cube.Pos
+	pieces []piece
+	undo   format.Schematic
+
+	running atomic.Bool
+
+	mu   sync.Mutex
+	next int
+	errs []error
+}
+
+// NewScheduler creates a Scheduler that builds s in w with its origin at pos.
+// Nothing is placed until Run is called.
+func NewScheduler(w *world.World, pos cube.Pos, s *Structure, conf SchedulerConfig) *Scheduler {
+	if conf.ChunksPerTick <= 0 {
+		conf.ChunksPerTick = 4
+	}
+	if conf.Interval <= 0 {
+		conf.Interval = time.Second / 20
+	}
+	sched := &Scheduler{
+		conf:   conf,
+		w:      w,
+		pos:    pos,
+		pieces: s.pieces(pos),
+	}
+	if conf.Undo {
+		sched.undo = newSnapshot(s.schematic.Dimensions())
+	}
+	return sched
+}
+
+// Undo returns a Structure that restores the area covered by the pieces
+// placed so far when built at the position of the Scheduler. The area of
+// pieces not placed yet is left untouched. Undo may be called while Run is
+// running, in which case it covers the pieces placed when it is called. It
+// returns nil if SchedulerConfig.Undo was not set.
+func (s *Scheduler) Undo() *Structure {
+	if s.undo == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return NewStructure(copySnapshot(s.undo))
+}
+
+// Progress returns the current progress of the Scheduler.
+func (s *Scheduler) Progress() Progress {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return Progress{Placed: s.next, Total: len(s.pieces)}
+}
+
+// Run places the remaining pieces of the Structure and their entities,
+// blocking until all of them are placed or ctx is cancelled. A transaction
+// that is already running when ctx is cancelled is always completed, so
+// calling Run again after a cancellation resumes placement exactly where it
+// stopped. Once every piece is placed, Run returns the errors of entities that
+// could not be placed, if any.
+func (s *Scheduler) Run(ctx context.Context) error {
+	if !s.running.CompareAndSwap(false, true) {
+		return fmt.Errorf("scheduler is already running")
+	}
+	defer s.running.Store(false)
+
+	ticker := time.NewTicker(s.conf.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		s.mu.Lock()
+		start := s.next
+		s.mu.Unlock()
+		if start >= len(s.pieces) {
+			return s.err()
+		}
+		end := min(start+s.conf.ChunksPerTick, len(s.pieces))
+
+		batch := s.pieces[start:end]
+		var errs []error
+		<-s.w.Exec(func(tx *world.Tx) {
+			for _, p := range batch {
+				if s.undo != nil {
+					s.mu.Lock()
+					capture(tx, s.pos, s.undo, p.x, p.z, p.width, p.length)
+					s.mu.Unlock()
+				}
+				if err := p.place(tx, s.pos); err != nil {
+					errs = append(errs, err)
+				}
+			}
+		})
+
+		s.mu.Lock()
+		s.next = end
+		s.errs = append(s.errs, errs...)
+		s.mu.Unlock()
+		if s.conf.Progress != nil {
+			s.conf.Progress(Progress{Placed: end, Total: len(s.pieces)})
+		}
+		if end >= len(s.pieces) {
+			return s.err()
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// err returns the errors collected while placing entities.
+func (s *Scheduler) err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return errors.Join(s.errs...)
+}
+
+// piece is a chunk-aligned column of a Structure. It implements
+// world.Structure so that it can be built on its own.
+type piece struct {
+	s *Structure
+	// x and z are the position of the piece within the Structure.
+	x, z int
+	// width and length are the size of the piece on the x and z axis.
+	width, length int
+}
+
+// pieces splits s into chunk-aligned columns for placement at pos.
+func (s *Structure) pieces(pos cube.Pos) []piece {
+	width, _, length := s.schematic.Dimensions()
+
+	var pieces []piece
+	for x := 0; x < width; {
+		w := min(16-((pos[0]+x)&15), width-x)
+		for z := 0; z < length; {
+			l := min(16-((pos[2]+z)&15), length-z)
+			pieces = append(pieces, piece{s: s, x: x, z: z, width: w, length: l})
+			z += l
+		}
+		x += w
+	}
+	return pieces
+}
+
+// place builds the piece and spawns the entities within it in the
+// transaction passed, assuming the Structure it belongs to has its origin at
+// pos. Biomes are applied first if PlacementOptions.Biomes is set, so that
+// viewers receive them along with the blocks.
+func (p piece) place(tx *world.Tx, pos cube.Pos) error {
+	if p.s.opts.Biomes {
+		p.s.placeBiomes(tx, pos, p.x, p.z, p.width, p.length)
+	}
+	tx.BuildStructure(pos.Add(cube.Pos{p.x, 0, p.z}), p)
+	return p.s.placeEntities(tx, pos, p.x, p.z, p.width, p.length)
+}
+
+// Dimensions implements world.Structure.
+func (p piece) Dimensions() [3]int {
+	_, height, _ := p.s.schematic.Dimensions()
+	return [3]int{p.width, height, p.length}
+}
+
+// At implements world.Structure.
+func (p piece) At(x, y, z int, blockAt func(x, y, z int) world.Block) (world.Block, world.Liquid) {
+	return p.s.At(x+p.x, y, z+p.z, func(x, y, z int) world.Block {
+		return blockAt(x-p.x, y, z-p.z)
+	})
+}
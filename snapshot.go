@@ -0,0 +1,142 @@
+package schem
+
+import (
+	"maps"
+
+	"github.com/df-mc/dragonfly/server/block"
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/oriumgames/schem/format"
+)
+
+const (
+	// editionKey is the metadata key marking the block states of a schematic
+	// as Bedrock states, which are placed without conversion.
+	editionKey = "Edition"
+	// bedrockEdition is the editionKey value of snapshots.
+	bedrockEdition = "bedrock"
+	// liquidProperty is the block state property holding the liquid found in
+	// the same position as a block, encoded as a block state string.
+	liquidProperty = "schem:liquid"
+)
+
+// Snapshot captures the blocks, liquids and block entities of the cuboid that
+// s occupies when built at pos. The snapshot holds Bedrock block states and
+// restores the captured area exactly when built at pos with NewStructure.
+func (s *Structure) Snapshot(tx *world.Tx, pos cube.Pos) format.Schematic {
+	width, height, length := s.schematic.Dimensions()
+	snapshot := newSnapshot(width, height, length)
+	capture(tx, pos, snapshot, 0, 0, width, length)
+	return snapshot
+}
+
+// BuildWithUndo snapshots the area s occupies at pos and places s there like
+// Place. The returned Structure undoes the placement of the blocks when built
+// at pos. Entities that could not be placed are reported in the returned
+// error, along with the undo Structure.
+func (s *Structure) BuildWithUndo(tx *world.Tx, pos cube.Pos) (*Structure, error) {
+	undo := NewStructure(s.Snapshot(tx, pos))
+	return undo, s.Place(tx, pos)
+}
+
+// newSnapshot creates an empty snapshot schematic of the size passed.
+func newSnapshot(width, height, length int) format.Schematic {
+	snapshot := format.New(width, height, length, "")
+	snapshot.SetMetadata(editionKey, bedrockEdition)
+	return snapshot
+}
+
+// capture stores the world contents of the columns in the area passed into
+// snapshot. The snapshot has its origin at pos, x and z are the position of
+// the area within the snapshot.
+func capture(tx *world.Tx, pos cube.Pos, snapshot format.Schematic, x, z, width, length int) {
+	_, height, _ := snapshot.Dimensions()
+	for lx := x; lx < x+width; lx++ {
+		for lz := z; lz < z+length; lz++ {
+			for y := range height {
+				p := pos.Add(cube.Pos{lx, y, lz})
+				if p.OutOfBounds(tx.Range()) {
+					continue
+				}
+				b := tx.Block(p)
+				name, props := b.EncodeBlock()
+				state := &format.BlockState{Name: name, Properties: maps.Clone(props)}
+				if state.Properties == nil {
+					state.Properties = make(map[string]any)
+				}
+
+				// Liquids on the second layer, such as water in waterlogged blocks.
+				if _, isLiquid := b.(world.Liquid); !isLiquid {
+					if liq, ok := tx.Liquid(p); ok {
+						liqName, liqProps := liq.EncodeBlock()
+						state.Properties[liquidProperty] = (&format.BlockState{Name: liqName, Properties: liqProps}).String()
+					}
+				}
+				snapshot.SetBlock(lx, y, lz, state)
+
+				if nbter, ok := b.(world.NBTer); ok {
+					data := nbter.EncodeNBT()
+					id, _ := data["id"].(string)
+					delete(data, "id")
+					snapshot.SetBlockEntity(lx, y, lz, &format.BlockEntity{ID: id, Data: data})
+				}
+			}
+		}
+	}
+}
+
+// copySnapshot returns a copy of a snapshot, sharing its block states and
+// block entities, which are never modified once captured.
+func copySnapshot(snapshot format.Schematic) format.Schematic {
+	width, height, length := snapshot.Dimensions()
+	c := newSnapshot(width, height, length)
+	for x := range width {
+		for y := range height {
+			for z := range length {
+				if state := snapshot.Block(x, y, z); state != nil {
+					c.SetBlock(x, y, z, state)
+				}
+				if be := snapshot.BlockEntity(x, y, z); be != nil {
+					c.SetBlockEntity(x, y, z, be)
+				}
+			}
+		}
+	}
+	return c
+}
+
+// bedrockAt returns the block and liquid at a position of a schematic holding
+// Bedrock block states, such as a snapshot. Positions that were not captured
+// hold no block and are left untouched.
+func (s *Structure) bedrockAt(x, y, z int) (world.Block, world.Liquid) {
+	state := s.schematic.Block(x, y, z)
+	if state == nil {
+		return nil, nil
+	}
+
+	props := maps.Clone(state.Properties)
+	liquidState, _ := props[liquidProperty].(string)
+	delete(props, liquidProperty)
+
+	ret, ok := world.BlockByName(state.Name, props)
+	if !ok {
+		return block.Air{}, nil
+	}
+	if nbter, ok := ret.(world.NBTer); ok {
+		data := map[string]any{}
+		if ent := s.schematic.BlockEntity(x, y, z); ent != nil && !s.opts.SkipBlockEntities {
+			data = maps.Clone(ent.Data)
+			data["id"] = ent.ID
+		}
+		ret = nbter.DecodeNBT(data).(world.Block)
+	}
+
+	var liquid world.Liquid
+	if liquidState != "" {
+		liq := format.ParseBlockState(liquidState)
+		if b, ok := world.BlockByName(liq.Name, liq.Properties); ok {
+			liquid, _ = b.(world.Liquid)
+		}
+	}
+	return ret, liquid
+}
@@ -1,6 +1,7 @@
 package schem
 
 import (
+	"sync"
 	_ "unsafe"
 
 	"github.com/df-mc/dragonfly/server/block"
@@ -15,14 +16,29 @@ import (
 type Structure struct {
 	schematic format.Schematic
 	converter *crocon.Converter
+	opts      PlacementOptions
+	// bedrock is true if the schematic holds Bedrock block states, which is
+	// the case for snapshots.
+	bedrock bool
+
+	biomeMu sync.Mutex
+	biomes  map[string]world.Biome
 }
 
 // NewStructure creates a new Structure from a format.Schematic.
 func NewStructure(s format.Schematic) *Structure {
+	return NewStructureWithOptions(s, PlacementOptions{})
+}
+
+// NewStructureWithOptions creates a new Structure from a format.Schematic that
+// is placed according to the options passed.
+func NewStructureWithOptions(s format.Schematic, opts PlacementOptions) *Structure {
 	c, _ := crocon.NewConverter()
 	return &Structure{
 		schematic: s,
 		converter: c,
+		opts:      opts,
+		bedrock:   s.Metadata()[editionKey] == bedrockEdition,
 	}
 }
 
@@ -34,15 +50,24 @@ func (s *Structure) Dimensions() [3]int {
 
 // At implements world.Structure.
 // It converts format.BlockState to world.Block using the crocon conversion system.
+// Positions skipped by the PlacementOptions of the Structure return nil.
 func (s *Structure) At(x, y, z int, _ func(x, y, z int) world.Block) (world.Block, world.Liquid) {
 	state := s.schematic.Block(x, y, z)
+	if s.opts.skip(x, y, z, state) {
+		return nil, nil
+	}
+
+	if s.bedrock {
+		return s.bedrockAt(x, y, z)
+	}
+
 	if state == nil {
 		// Return air for nil blocks
 		return block.Air{}, nil
 	}
 
 	// Special case: air should explicitly set air
-	if state.Name == "minecraft:air" || state.Name == "air" {
+	if isAir(state.Name) {
 		return block.Air{}, nil
 	}
 
@@ -89,7 +114,7 @@ func (s *Structure) At(x, y, z int, _ func(x, y, z int) world.Block) (world.Bloc
 	if nbter, ok := ret.(world.NBTer); ok {
 		ent := s.schematic.BlockEntity(x, y, z)
 
-		if ent != nil {
+		if ent != nil && !s.opts.SkipBlockEntities {
 			from := crocon.BlockEntity(ent.Data)
 			from["id"] = ent.ID
 
@@ -107,12 +132,11 @@ func (s *Structure) At(x, y, z int, _ func(x, y, z int) world.Block) (world.Bloc
 				return block.Air{}, nil
 			}
 
-			m, ok := any(be).(*map[string]any)
-			if !ok || m == nil {
+			if be == nil {
 				return block.Air{}, nil
 			}
 
-			tag, ok := (*m)["tag"].(map[string]any)
+			tag, ok := (*be)["tag"].(map[string]any)
 			if !ok {
 				return block.Air{}, nil
 			}